@@ -0,0 +1,138 @@
+package mailform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Address is a mailing address, the recipient or sender of an order input.
+type Address struct {
+	Name         string `json:"name"`
+	Organization string `json:"organization,omitempty"`
+	Address1     string `json:"address1"`
+	Address2     string `json:"address2,omitempty"`
+	City         string `json:"city"`
+	State        string `json:"state"`
+	Postcode     string `json:"postcode"`
+	Country      string `json:"country"`
+}
+
+// addressFields are the names of the Address fields, in order. OrderInput fields are the same prefixed with To or From.
+var addressFields = []string{"Name", "Organization", "Address1", "Address2", "City", "State", "Postcode", "Country"}
+
+// fields returns pointers to the address fields in the same order as addressFields.
+func (a *Address) fields() []*string {
+	return []*string{&a.Name, &a.Organization, &a.Address1, &a.Address2, &a.City, &a.State, &a.Postcode, &a.Country}
+}
+
+// Lines returns the lines of the address as printed on mail, skipping empty ones.
+func (a Address) Lines() []string {
+	lastLine := strings.TrimSpace(a.City)
+	if lastLine != "" && strings.TrimSpace(a.State) != "" {
+		lastLine += ","
+	}
+	lastLine = strings.TrimSpace(lastLine + " " + strings.TrimSpace(a.State))
+	lastLine = strings.TrimSpace(lastLine + " " + strings.TrimSpace(a.Postcode))
+
+	lines := []string{}
+	for _, line := range []string{a.Name, a.Organization, a.Address1, a.Address2, lastLine, a.Country} {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// To returns the recipient address of the order input.
+func (o *OrderInput) To() Address {
+	return Address{
+		Name:         o.ToName,
+		Organization: o.ToOrganization,
+		Address1:     o.ToAddress1,
+		Address2:     o.ToAddress2,
+		City:         o.ToCity,
+		State:        o.ToState,
+		Postcode:     o.ToPostcode,
+		Country:      o.ToCountry,
+	}
+}
+
+// SetTo sets the recipient address of the order input.
+func (o *OrderInput) SetTo(a Address) {
+	o.ToName = a.Name
+	o.ToOrganization = a.Organization
+	o.ToAddress1 = a.Address1
+	o.ToAddress2 = a.Address2
+	o.ToCity = a.City
+	o.ToState = a.State
+	o.ToPostcode = a.Postcode
+	o.ToCountry = a.Country
+}
+
+// From returns the sender address of the order input.
+func (o *OrderInput) From() Address {
+	return Address{
+		Name:         o.FromName,
+		Organization: o.FromOrganization,
+		Address1:     o.FromAddress1,
+		Address2:     o.FromAddress2,
+		City:         o.FromCity,
+		State:        o.FromState,
+		Postcode:     o.FromPostcode,
+		Country:      o.FromCountry,
+	}
+}
+
+// SetFrom sets the sender address of the order input.
+func (o *OrderInput) SetFrom(a Address) {
+	o.FromName = a.Name
+	o.FromOrganization = a.Organization
+	o.FromAddress1 = a.Address1
+	o.FromAddress2 = a.Address2
+	o.FromCity = a.City
+	o.FromState = a.State
+	o.FromPostcode = a.Postcode
+	o.FromCountry = a.Country
+}
+
+// addressWithoutName returns the address with the name and organization cleared.
+func addressWithoutName(a Address) Address {
+	a.Name = ""
+	a.Organization = ""
+
+	return a
+}
+
+// addressKey returns a key identifying the normalized name and address, the same for an address however it's written.
+// The organization is left out since it's often missing or written differently.
+func addressKey(a Address) string {
+	a, _ = NormalizeAddress(a)
+
+	country := a.Country
+	if c, err := ParseCountry(country); err == nil {
+		country = c.Alpha2
+	}
+	if strings.TrimSpace(country) == "" {
+		country = "US"
+	}
+
+	postcode := a.Postcode
+	if country == "US" {
+		// ZIP+4 is optional, so only the ZIP code is compared
+		postcode = strings.SplitN(postcode, "-", 2)[0]
+		if state, ok := usStateCode(a.State); ok {
+			a.State = state
+		}
+	}
+
+	parts := []string{a.Name, a.Address1, a.Address2, a.City, a.State, postcode, country}
+	for i, part := range parts {
+		parts[i] = similarityKey(part)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+
+	return hex.EncodeToString(sum[:])
+}
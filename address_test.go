@@ -0,0 +1,70 @@
+package mailform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    Address
+		expected []string
+	}{
+		{
+			name: "full",
+			input: Address{
+				Name:         "Jane Doe",
+				Organization: "Acme",
+				Address1:     "1 Main St",
+				Address2:     "Ste 100",
+				City:         "Springfield",
+				State:        "IL",
+				Postcode:     "62701",
+				Country:      "US",
+			},
+			expected: []string{"Jane Doe", "Acme", "1 Main St", "Ste 100", "Springfield, IL 62701", "US"},
+		},
+		{
+			name:     "no state",
+			input:    Address{Name: "Jane Doe", Address1: "1 Main St", City: "London", Postcode: "SW1A 2AA"},
+			expected: []string{"Jane Doe", "1 Main St", "London SW1A 2AA"},
+		},
+		{
+			name:     "empty",
+			input:    Address{},
+			expected: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.input.Lines())
+		})
+	}
+}
+
+func TestOrderInputAddresses(t *testing.T) {
+	input := testOrderInput()
+	assert.Equal(t, Address{
+		Name:     "some_name",
+		Address1: "some_address1",
+		City:     "some_city",
+		State:    "some_state",
+		Postcode: "some_postcode",
+		Country:  "some_country",
+	}, input.To())
+	assert.Equal(t, "some_fromcity", input.From().City)
+
+	to := Address{Name: "someone", Organization: "some_org", Address2: "some_address2"}
+	input.SetTo(to)
+	assert.Equal(t, to, input.To())
+	assert.Equal(t, "some_org", input.ToOrganization)
+
+	from := Address{Name: "someone_else", Country: "US"}
+	input.SetFrom(from)
+	assert.Equal(t, from, input.From())
+	assert.Equal(t, "US", input.FromCountry)
+	assert.Empty(t, input.FromCity)
+}
@@ -0,0 +1,271 @@
+package mailform
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMinMatchScore is the lowest score address book search results have by default
+	DefaultMinMatchScore = 0.75
+)
+
+var (
+	// ErrContactNotFound is returned when a contact ID doesn't exist in the address book.
+	ErrContactNotFound = errors.New("contact not found")
+	// ErrContactNameRequired is returned when saving a contact without a name or address name.
+	ErrContactNameRequired = errors.New("contact name is required")
+	// ErrNotSenderProfile is returned when a contact is used as a sender but isn't a sender profile.
+	ErrNotSenderProfile = errors.New("contact is not a sender profile")
+)
+
+// Contact is a saved address, either a recipient or a sender profile.
+type Contact struct {
+	ID string `json:"id"`
+	// Name is what the contact is called in the address book, defaults to the address name
+	Name    string  `json:"name"`
+	Address Address `json:"address"`
+	// Sender marks the contact as a sender profile, used for the return address of orders
+	Sender bool `json:"sender,omitempty"`
+	// Company is the Company set on orders sent from a sender profile
+	Company string    `json:"company,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// ContactMatch is an address book search result.
+type ContactMatch struct {
+	Contact Contact `json:"contact"`
+	// Score is how well the contact matched the query from 0 to 1
+	Score float64 `json:"score"`
+}
+
+// AddressBook stores contacts and sender profiles.
+// Contacts are kept in memory and persisted to a local JSON file after every change.
+type AddressBook struct {
+	path  string
+	clock Clock
+	// MinMatchScore is the lowest score search results have, defaults to DefaultMinMatchScore
+	MinMatchScore float64
+
+	mu       sync.Mutex
+	contacts map[string]*Contact
+}
+
+// NewAddressBook returns an address book that persists contacts to the file at path, loading any that already exist.
+// If clock is nil, the system clock is used.
+func NewAddressBook(path string, clock Clock) (*AddressBook, error) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	b := &AddressBook{
+		path:     path,
+		clock:    clock,
+		contacts: map[string]*Contact{},
+	}
+
+	contacts := []*Contact{}
+	err := readJSONFile(path, &contacts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, contact := range contacts {
+		b.contacts[contact.ID] = contact
+	}
+
+	return b, nil
+}
+
+// save persists all contacts. Callers must hold the lock.
+func (b *AddressBook) save() error {
+	return writeJSONFile(b.path, b.sorted(func(*Contact) bool { return true }))
+}
+
+// sorted returns copies of the contacts that match filter sorted by name then ID. Callers must hold the lock.
+func (b *AddressBook) sorted(filter func(*Contact) bool) []Contact {
+	contacts := []Contact{}
+	for _, contact := range b.contacts {
+		if filter(contact) {
+			contacts = append(contacts, *contact)
+		}
+	}
+	sort.Slice(contacts, func(i, j int) bool {
+		if contacts[i].Name != contacts[j].Name {
+			return contacts[i].Name < contacts[j].Name
+		}
+		return contacts[i].ID < contacts[j].ID
+	})
+
+	return contacts
+}
+
+// Save adds a contact, or replaces the contact with the same ID. Contacts without an ID are given one.
+// It returns the contact as saved.
+func (b *AddressBook) Save(c Contact) (Contact, error) {
+	if strings.TrimSpace(c.Name) == "" {
+		c.Name = strings.TrimSpace(c.Address.Name)
+	}
+	if c.Name == "" {
+		return c, ErrContactNameRequired
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	existing, ok := b.contacts[c.ID]
+	if ok {
+		c.Created = existing.Created
+	} else {
+		if c.ID == "" {
+			id, err := newID()
+			if err != nil {
+				return c, err
+			}
+			c.ID = id
+		}
+		c.Created = now
+	}
+	c.Updated = now
+
+	b.contacts[c.ID] = &c
+	err := b.save()
+	if err != nil {
+		// Keep memory consistent with what's on disk
+		if ok {
+			b.contacts[c.ID] = existing
+		} else {
+			delete(b.contacts, c.ID)
+		}
+		return c, err
+	}
+
+	return c, nil
+}
+
+// Get returns the contact with the ID.
+func (b *AddressBook) Get(id string) (Contact, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	contact, ok := b.contacts[id]
+	if !ok {
+		return Contact{}, ErrContactNotFound
+	}
+
+	return *contact, nil
+}
+
+// Delete removes the contact with the ID.
+func (b *AddressBook) Delete(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	contact, ok := b.contacts[id]
+	if !ok {
+		return ErrContactNotFound
+	}
+
+	delete(b.contacts, id)
+	err := b.save()
+	if err != nil {
+		b.contacts[id] = contact
+		return err
+	}
+
+	return nil
+}
+
+// Contacts returns the recipient contacts sorted by name.
+func (b *AddressBook) Contacts() []Contact {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.sorted(func(c *Contact) bool { return !c.Sender })
+}
+
+// Senders returns the sender profiles sorted by name.
+func (b *AddressBook) Senders() []Contact {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.sorted(func(c *Contact) bool { return c.Sender })
+}
+
+// Search finds contacts and sender profiles by name, address name, organization or company, forgiving typos.
+// Results are sorted best match first.
+func (b *AddressBook) Search(query string) []ContactMatch {
+	minScore := b.MinMatchScore
+	if minScore <= 0 {
+		minScore = DefaultMinMatchScore
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	matches := []ContactMatch{}
+	for _, contact := range b.sorted(func(*Contact) bool { return true }) {
+		best := 0.0
+		for _, text := range []string{contact.Name, contact.Address.Name, contact.Address.Organization, contact.Company} {
+			score := matchScore(query, text)
+			if score > best {
+				best = score
+			}
+		}
+		if best >= minScore {
+			matches = append(matches, ContactMatch{Contact: contact, Score: best})
+		}
+	}
+	// Stable so equal scores stay sorted by name
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+
+	return matches
+}
+
+// Fill sets the recipient of an order input from the contact with toID and the sender from the sender profile
+// with fromID. Either ID can be empty to leave that side of the order input alone.
+func (b *AddressBook) Fill(o *OrderInput, toID, fromID string) error {
+	if toID != "" {
+		to, err := b.Get(toID)
+		if err != nil {
+			return err
+		}
+		o.SetRecipient(to)
+	}
+
+	if fromID != "" {
+		from, err := b.Get(fromID)
+		if err != nil {
+			return err
+		}
+		return o.SetSender(from)
+	}
+
+	return nil
+}
+
+// SetRecipient sets the recipient address of the order input to the contact's address.
+func (o *OrderInput) SetRecipient(c Contact) {
+	o.SetTo(c.Address)
+}
+
+// SetSender sets the sender address of the order input to the sender profile's address, and the Company if it has one.
+func (o *OrderInput) SetSender(c Contact) error {
+	if !c.Sender {
+		return ErrNotSenderProfile
+	}
+
+	o.SetFrom(c.Address)
+	if c.Company != "" {
+		o.Company = c.Company
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package mailform
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressBook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "addressbook.json")
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	book, err := NewAddressBook(path, clock)
+	assert.NoError(t, err)
+
+	jane, err := book.Save(Contact{Address: Address{Name: "Jane Doe", Address1: "1 Main St", City: "Austin", State: "TX", Postcode: "78701", Country: "US"}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, jane.ID)
+	assert.Equal(t, "Jane Doe", jane.Name)
+	assert.Equal(t, clock.now, jane.Created)
+
+	_, err = book.Save(Contact{Name: "John Smith", Address: Address{Name: "John Smith", Organization: "Acme"}})
+	assert.NoError(t, err)
+
+	hq, err := book.Save(Contact{ID: "hq", Name: "Headquarters", Sender: true, Company: "Acme", Address: Address{Name: "Acme Returns", Address1: "2 Main St"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "hq", hq.ID)
+
+	_, err = book.Save(Contact{})
+	assert.ErrorIs(t, err, ErrContactNameRequired)
+
+	// Updating keeps the created time
+	clock.Add(time.Hour)
+	jane.Address.Address2 = "Apt 4"
+	jane, err = book.Save(jane)
+	assert.NoError(t, err)
+	assert.Equal(t, clock.now.Add(-time.Hour), jane.Created)
+	assert.Equal(t, clock.now, jane.Updated)
+
+	contacts := book.Contacts()
+	assert.Len(t, contacts, 2)
+	assert.Equal(t, "Jane Doe", contacts[0].Name)
+	assert.Equal(t, "John Smith", contacts[1].Name)
+	assert.Equal(t, []Contact{hq}, book.Senders())
+
+	// Contacts are persisted
+	reloaded, err := NewAddressBook(path, clock)
+	assert.NoError(t, err)
+	got, err := reloaded.Get(jane.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Apt 4", got.Address.Address2)
+	assert.Len(t, reloaded.Contacts(), 2)
+
+	assert.NoError(t, reloaded.Delete(jane.ID))
+	_, err = reloaded.Get(jane.ID)
+	assert.ErrorIs(t, err, ErrContactNotFound)
+	assert.ErrorIs(t, reloaded.Delete(jane.ID), ErrContactNotFound)
+
+	reloaded, err = NewAddressBook(path, clock)
+	assert.NoError(t, err)
+	assert.Len(t, reloaded.Contacts(), 1)
+}
+
+func TestAddressBookSearch(t *testing.T) {
+	book, err := NewAddressBook(filepath.Join(t.TempDir(), "addressbook.json"), nil)
+	assert.NoError(t, err)
+
+	for _, contact := range []Contact{
+		{Name: "Jane Doe"},
+		{Name: "Jane Smith"},
+		{Name: "Bob", Address: Address{Organization: "Smyth Holdings"}},
+		{Name: "Returns", Sender: true, Company: "Acme"},
+	} {
+		_, err := book.Save(contact)
+		assert.NoError(t, err)
+	}
+
+	names := func(matches []ContactMatch) []string {
+		out := []string{}
+		for _, match := range matches {
+			out = append(out, match.Contact.Name)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"Jane Doe", "Jane Smith"}, names(book.Search("jane")))
+	assert.Equal(t, []string{"Jane Smith", "Bob"}, names(book.Search("smith")))
+	assert.Equal(t, []string{"Jane Doe"}, names(book.Search("jnae doe")))
+	assert.Equal(t, []string{"Returns"}, names(book.Search("acme")))
+	assert.Empty(t, book.Search("nobody"))
+
+	book.MinMatchScore = 0.5
+	assert.Equal(t, []string{"Jane Doe", "Jane Smith"}, names(book.Search("jnae")))
+}
+
+func TestAddressBookFill(t *testing.T) {
+	book, err := NewAddressBook(filepath.Join(t.TempDir(), "addressbook.json"), nil)
+	assert.NoError(t, err)
+
+	to, err := book.Save(Contact{Address: Address{Name: "Jane Doe", Address1: "1 Main St", City: "Austin", State: "TX", Postcode: "78701", Country: "US"}})
+	assert.NoError(t, err)
+	from, err := book.Save(Contact{Name: "Returns", Sender: true, Company: "Acme", Address: Address{Name: "Acme", Address1: "2 Main St", City: "Austin", State: "TX", Postcode: "78701", Country: "US"}})
+	assert.NoError(t, err)
+
+	input := OrderInput{Service: "USPS_STANDARD"}
+	assert.NoError(t, book.Fill(&input, to.ID, from.ID))
+	assert.Equal(t, to.Address, input.To())
+	assert.Equal(t, from.Address, input.From())
+	assert.Equal(t, "Acme", input.Company)
+	assert.NoError(t, input.Validate())
+
+	// Empty IDs leave that side alone
+	other := testOrderInput()
+	assert.NoError(t, book.Fill(&other, "", from.ID))
+	assert.Equal(t, "some_name", other.ToName)
+
+	assert.ErrorIs(t, book.Fill(&other, "missing", ""), ErrContactNotFound)
+	assert.ErrorIs(t, book.Fill(&other, "", to.ID), ErrNotSenderProfile)
+}
@@ -0,0 +1,461 @@
+package mailform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultApprovalExpiry is how long approval requests wait for a decision by default
+	DefaultApprovalExpiry = time.Hour * 72
+	// Approval statuses
+	ApprovalStatusPending   = "pending"
+	ApprovalStatusApproved  = "approved"
+	ApprovalStatusRejected  = "rejected"
+	ApprovalStatusExpired   = "expired"
+	ApprovalStatusSubmitted = "submitted"
+)
+
+var (
+	// ErrApprovalNotFound is returned when an approval ID doesn't exist.
+	ErrApprovalNotFound = errors.New("approval not found")
+	// ErrApprovalNotPending is returned when deciding on an approval that has already been decided or has expired.
+	ErrApprovalNotPending = errors.New("approval is not pending")
+	// ErrApprovalNotApproved is returned when submitting an approval that hasn't been approved.
+	ErrApprovalNotApproved = errors.New("approval has not been approved")
+	// ErrApproverRequired is returned when approving or rejecting without saying who the approver is.
+	ErrApproverRequired = errors.New("approver is required")
+	// ErrRequesterRequired is returned when requesting approval without saying who the requester is with WithAuditActor,
+	// since the requester couldn't be stopped from approving their own order.
+	ErrRequesterRequired = errors.New("requester is required, set one with WithAuditActor")
+	// ErrSelfApproval is returned when the approver is the same person who requested the order,
+	// or when who requested the order isn't known.
+	ErrSelfApproval = errors.New("orders can't be approved by the person who requested them")
+	// ErrNoApprovalGate is returned when submitting an approval with a client without an approval gate.
+	ErrNoApprovalGate = errors.New("client does not have an approval gate")
+)
+
+// ErrApprovalRequired is returned when an order needs approval before it's sent.
+// The order input has been saved as a pending approval unless the client is in dry run mode.
+// Order inputs with a File can't be saved, so they return an error wrapping ErrFileNotPersisted instead.
+type ErrApprovalRequired struct {
+	Approval Approval
+}
+
+func (e *ErrApprovalRequired) Error() string {
+	if e.Approval.ID == "" {
+		return fmt.Sprintf("order requires approval: %s", strings.Join(e.Approval.Reasons, ", "))
+	}
+
+	return fmt.Sprintf("order requires approval %s: %s", e.Approval.ID, strings.Join(e.Approval.Reasons, ", "))
+}
+
+// ApprovalRule decides which orders need approval. Orders match a rule if they match every criteria that is set,
+// so a rule with no criteria matches every order.
+type ApprovalRule struct {
+	// Name explains why matching orders need approval, such as "checks over $5,000"
+	Name string `json:"name"`
+	// CheckAmount matches orders with a check of more than this amount
+	CheckAmount Cents `json:"check_amount,omitempty"`
+	// Services matches orders sent with any of these service codes
+	Services []string `json:"services,omitempty"`
+	// Countries matches orders to recipients in any of these countries, by ISO 3166-1 code or name
+	Countries []string `json:"countries,omitempty"`
+	// International matches orders to recipients outside of the United States
+	International bool `json:"international,omitempty"`
+}
+
+// Match checks if the order input matches the rule.
+func (r ApprovalRule) Match(o OrderInput) bool {
+	if r.CheckAmount > 0 {
+		check, ok := o.Check()
+		if !ok || check.Amount <= r.CheckAmount {
+			return false
+		}
+	}
+
+	if len(r.Services) > 0 && !containsString(r.Services, o.Service) {
+		return false
+	}
+
+	if len(r.Countries) > 0 && !countryIn(o.ToCountry, r.Countries) {
+		return false
+	}
+
+	// Blank countries are sent as US
+	if r.International && (strings.TrimSpace(o.ToCountry) == "" || isUnitedStates(o.ToCountry)) {
+		return false
+	}
+
+	return true
+}
+
+// countryIn checks if a country is one of countries, comparing ISO 3166-1 countries by code.
+func countryIn(country string, countries []string) bool {
+	parsed, err := ParseCountry(country)
+	for _, c := range countries {
+		other, otherErr := ParseCountry(c)
+		if err == nil && otherErr == nil && parsed.Alpha2 == other.Alpha2 {
+			return true
+		}
+		if strings.EqualFold(strings.TrimSpace(country), strings.TrimSpace(c)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Approval is an order input waiting on, or given, approval to be sent.
+type Approval struct {
+	ID    string     `json:"id"`
+	Input OrderInput `json:"input"`
+	// Reasons are the names of the rules the order input matched
+	Reasons []string `json:"reasons"`
+	Status  string   `json:"status"`
+	// Requester is who created the order, from WithAuditActor
+	Requester string    `json:"requester,omitempty"`
+	Requested time.Time `json:"requested"`
+	// Expires is when a pending approval expires
+	Expires time.Time `json:"expires"`
+	// Approver is who approved or rejected the order
+	Approver string    `json:"approver,omitempty"`
+	Decided  time.Time `json:"decided,omitempty"`
+	// Comment is why the order was approved or rejected
+	Comment string `json:"comment,omitempty"`
+	// OrderID is the mailform order ID once submitted
+	OrderID string `json:"order_id,omitempty"`
+	// Err is the last error returned when submitting
+	Err string `json:"error,omitempty"`
+}
+
+// ApprovalGate holds orders that match its rules until someone approves them.
+// Approvals are persisted to a local JSON file after every change.
+type ApprovalGate struct {
+	rules []ApprovalRule
+	path  string
+	clock Clock
+	// Expiry is how long approval requests wait for a decision, defaults to DefaultApprovalExpiry
+	Expiry time.Duration
+
+	mu        sync.Mutex
+	approvals map[string]*Approval
+	// submitMu stops concurrent submits from sending the same approval twice
+	submitMu sync.Mutex
+}
+
+// NewApprovalGate returns an approval gate for the rules that persists approvals to the file at path,
+// loading any that already exist. If clock is nil, the system clock is used.
+func NewApprovalGate(rules []ApprovalRule, path string, clock Clock) (*ApprovalGate, error) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	g := &ApprovalGate{
+		rules:     rules,
+		path:      path,
+		clock:     clock,
+		approvals: map[string]*Approval{},
+	}
+
+	approvals := []*Approval{}
+	err := readJSONFile(path, &approvals)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, approval := range approvals {
+		g.approvals[approval.ID] = approval
+	}
+
+	return g, nil
+}
+
+// save persists all approvals. Callers must hold the lock.
+func (g *ApprovalGate) save() error {
+	return writeJSONFile(g.path, g.sorted(func(*Approval) bool { return true }))
+}
+
+// sorted returns copies of the approvals that match filter sorted by when they were requested. Callers must hold the lock.
+func (g *ApprovalGate) sorted(filter func(*Approval) bool) []Approval {
+	approvals := []Approval{}
+	for _, approval := range g.approvals {
+		if filter(approval) {
+			approvals = append(approvals, *approval)
+		}
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		if !approvals[i].Requested.Equal(approvals[j].Requested) {
+			return approvals[i].Requested.Before(approvals[j].Requested)
+		}
+		return approvals[i].ID < approvals[j].ID
+	})
+
+	return approvals
+}
+
+// expire marks pending approvals past their expiry as expired. Callers must hold the lock.
+func (g *ApprovalGate) expire() {
+	now := g.clock.Now()
+	for _, approval := range g.approvals {
+		if approval.Status == ApprovalStatusPending && !now.Before(approval.Expires) {
+			approval.Status = ApprovalStatusExpired
+		}
+	}
+}
+
+// Reasons returns the names of the rules the order input matches, empty if it doesn't need approval.
+func (g *ApprovalGate) Reasons(o OrderInput) []string {
+	reasons := []string{}
+	for _, rule := range g.rules {
+		if rule.Match(o) {
+			reasons = append(reasons, rule.Name)
+		}
+	}
+
+	return reasons
+}
+
+// Request saves an order input as a pending approval, with the requester from WithAuditActor, which is required.
+// The client calls this for orders that match a rule, so it only needs calling directly to ask for approval up front.
+func (g *ApprovalGate) Request(ctx context.Context, o OrderInput) (Approval, error) {
+	if o.File != nil {
+		return Approval{}, ErrFileNotPersisted
+	}
+
+	requester, _ := ctx.Value(auditActorKey{}).(string)
+	requester = strings.TrimSpace(requester)
+	if requester == "" {
+		return Approval{}, ErrRequesterRequired
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Approval{}, err
+	}
+
+	expiry := g.Expiry
+	if expiry <= 0 {
+		expiry = DefaultApprovalExpiry
+	}
+
+	now := g.clock.Now()
+	approval := &Approval{
+		ID:        id,
+		Input:     o,
+		Reasons:   g.Reasons(o),
+		Status:    ApprovalStatusPending,
+		Requester: requester,
+		Requested: now,
+		Expires:   now.Add(expiry),
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.approvals[id] = approval
+	err = g.save()
+	if err != nil {
+		delete(g.approvals, id)
+		return Approval{}, err
+	}
+
+	return *approval, nil
+}
+
+// update expires stale approvals and applies fn to an approval, persisting the result.
+// fn must leave the approval alone if it returns an error.
+func (g *ApprovalGate) update(id string, fn func(*Approval) error) (Approval, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	approval, ok := g.approvals[id]
+	if !ok {
+		return Approval{}, ErrApprovalNotFound
+	}
+
+	previous := map[string]Approval{}
+	for id, approval := range g.approvals {
+		previous[id] = *approval
+	}
+
+	g.expire()
+	err := fn(approval)
+
+	// Expiring is saved even if fn fails
+	saveErr := g.save()
+	if saveErr != nil {
+		// Keep memory consistent with what's on disk
+		for id, approval := range previous {
+			*g.approvals[id] = approval
+		}
+		return Approval{}, saveErr
+	}
+	if err != nil {
+		return Approval{}, err
+	}
+
+	return *approval, nil
+}
+
+// decide approves or rejects a pending approval.
+func (g *ApprovalGate) decide(id, approver, comment, status string) (Approval, error) {
+	approver = strings.TrimSpace(approver)
+	if approver == "" {
+		return Approval{}, ErrApproverRequired
+	}
+
+	return g.update(id, func(approval *Approval) error {
+		if approval.Status != ApprovalStatusPending {
+			return fmt.Errorf("%w: approval %s is %s", ErrApprovalNotPending, id, approval.Status)
+		}
+		// Approvals saved without a requester could have been requested by the approver
+		if status == ApprovalStatusApproved && (approval.Requester == "" || strings.EqualFold(approver, approval.Requester)) {
+			return ErrSelfApproval
+		}
+
+		approval.Status = status
+		approval.Approver = approver
+		approval.Comment = comment
+		approval.Decided = g.clock.Now()
+		return nil
+	})
+}
+
+// Approve approves a pending approval so it can be submitted with Client.SubmitApproved.
+// The approver can't be the requester, and approvals without a requester can only be rejected.
+func (g *ApprovalGate) Approve(id, approver, comment string) (Approval, error) {
+	return g.decide(id, approver, comment, ApprovalStatusApproved)
+}
+
+// Reject rejects a pending approval so it's never sent.
+func (g *ApprovalGate) Reject(id, approver, reason string) (Approval, error) {
+	return g.decide(id, approver, reason, ApprovalStatusRejected)
+}
+
+// Get returns the approval with the ID.
+func (g *ApprovalGate) Get(id string) (Approval, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	approval, ok := g.approvals[id]
+	if !ok {
+		return Approval{}, ErrApprovalNotFound
+	}
+	if approval.Status == ApprovalStatusPending && !g.clock.Now().Before(approval.Expires) {
+		expired := *approval
+		expired.Status = ApprovalStatusExpired
+		return expired, nil
+	}
+
+	return *approval, nil
+}
+
+// Pending returns the approvals waiting on a decision, oldest first.
+func (g *ApprovalGate) Pending() []Approval {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now()
+	return g.sorted(func(a *Approval) bool {
+		return a.Status == ApprovalStatusPending && now.Before(a.Expires)
+	})
+}
+
+// List returns every approval, oldest first.
+func (g *ApprovalGate) List() []Approval {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	approvals := g.sorted(func(*Approval) bool { return true })
+	now := g.clock.Now()
+	for i := range approvals {
+		if approvals[i].Status == ApprovalStatusPending && !now.Before(approvals[i].Expires) {
+			approvals[i].Status = ApprovalStatusExpired
+		}
+	}
+
+	return approvals
+}
+
+// approvedKey is the context key marking an order input as approved so the approval gate lets it through.
+type approvedKey struct{}
+
+// SubmitApproved creates the order of an approved approval and marks it submitted.
+// Orders that fail to be created stay approved so they can be submitted again.
+func (c *Client) SubmitApproved(ctx context.Context, id string) (*Order, error) {
+	if c.approvals == nil {
+		return &Order{}, ErrNoApprovalGate
+	}
+	g := c.approvals
+
+	g.submitMu.Lock()
+	defer g.submitMu.Unlock()
+
+	approval, err := g.Get(id)
+	if err != nil {
+		return &Order{}, err
+	}
+	if approval.Status != ApprovalStatusApproved {
+		return &Order{}, fmt.Errorf("%w: approval %s is %s", ErrApprovalNotApproved, id, approval.Status)
+	}
+
+	order, err := c.CreateOrderWithContext(context.WithValue(ctx, approvedKey{}, id), approval.Input)
+	if c.dryRun {
+		return order, err
+	}
+
+	created := err == nil || isOrderCreated(order)
+	_, updateErr := g.update(id, func(approval *Approval) error {
+		approval.Err = ""
+		if err != nil {
+			approval.Err = err.Error()
+		}
+		// The order exists even if something went wrong afterwards
+		if created {
+			approval.Status = ApprovalStatusSubmitted
+			approval.OrderID = order.Data.ID
+		}
+		return nil
+	})
+	if updateErr != nil && err == nil {
+		return order, fmt.Errorf("order %s created but approval could not be updated: %w", order.Data.ID, updateErr)
+	}
+
+	return order, err
+}
+
+// requireApproval returns an *ErrApprovalRequired if the order input needs approval and wasn't submitted by SubmitApproved,
+// saving it as a pending approval unless it's a dry run.
+func (c *Client) requireApproval(ctx context.Context, o OrderInput) error {
+	if _, ok := ctx.Value(approvedKey{}).(string); ok {
+		return nil
+	}
+
+	reasons := c.approvals.Reasons(o)
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	// The document couldn't be sent once approved, so the order is refused rather than held
+	if o.File != nil {
+		return fmt.Errorf("order requires approval: %s: %w", strings.Join(reasons, ", "), ErrFileNotPersisted)
+	}
+
+	// Nothing is saved on dry runs
+	if c.dryRun {
+		return &ErrApprovalRequired{Approval: Approval{Input: o, Reasons: reasons, Status: ApprovalStatusPending}}
+	}
+
+	approval, err := c.approvals.Request(ctx, o)
+	if err != nil {
+		return err
+	}
+
+	return &ErrApprovalRequired{Approval: approval}
+}
@@ -0,0 +1,318 @@
+package mailform
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func testApprovalRules() []ApprovalRule {
+	return []ApprovalRule{
+		{Name: "checks over $5,000", CheckAmount: 500000},
+		{Name: "overnight", Services: []string{"FEDEX_OVERNIGHT"}},
+		{Name: "international", International: true},
+		{Name: "canada overnight", Services: []string{"FEDEX_OVERNIGHT"}, Countries: []string{"Canada"}},
+	}
+}
+
+func TestApprovalRuleMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(o *OrderInput)
+		expected []string
+	}{
+		{
+			name:     "no rules",
+			modify:   func(o *OrderInput) {},
+			expected: []string{},
+		},
+		{
+			name: "small check",
+			modify: func(o *OrderInput) {
+				check := testCheck()
+				check.Amount = 500000
+				_ = o.SetCheck(check)
+			},
+			expected: []string{},
+		},
+		{
+			name: "large check",
+			modify: func(o *OrderInput) {
+				check := testCheck()
+				check.Amount = 500001
+				_ = o.SetCheck(check)
+			},
+			expected: []string{"checks over $5,000"},
+		},
+		{
+			name:     "overnight",
+			modify:   func(o *OrderInput) { o.Service = "FEDEX_OVERNIGHT" },
+			expected: []string{"overnight"},
+		},
+		{
+			name: "international overnight",
+			modify: func(o *OrderInput) {
+				o.Service = "FEDEX_OVERNIGHT"
+				o.ToCountry = "CA"
+			},
+			expected: []string{"overnight", "international", "canada overnight"},
+		},
+		{
+			name:     "international",
+			modify:   func(o *OrderInput) { o.ToCountry = "United Kingdom" },
+			expected: []string{"international"},
+		},
+		{
+			name:     "united states",
+			modify:   func(o *OrderInput) { o.ToCountry = "USA" },
+			expected: []string{},
+		},
+		{
+			name:     "blank country",
+			modify:   func(o *OrderInput) { o.ToCountry = "" },
+			expected: []string{},
+		},
+	}
+
+	gate, err := NewApprovalGate(testApprovalRules(), filepath.Join(t.TempDir(), "approvals.json"), nil)
+	assert.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := testOrderInput()
+			input.ToCountry = "US"
+			test.modify(&input)
+			assert.Equal(t, test.expected, gate.Reasons(input))
+		})
+	}
+}
+
+func TestApprovalGate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approvals.json")
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	gate, err := NewApprovalGate(testApprovalRules(), path, clock)
+	assert.NoError(t, err)
+	gate.Expiry = time.Hour
+
+	input := testOrderInput()
+	input.Service = "FEDEX_OVERNIGHT"
+	ctx := WithAuditActor(context.Background(), "alice")
+
+	// The requester has to be known to stop them approving their own order
+	_, err = gate.Request(context.Background(), input)
+	assert.ErrorIs(t, err, ErrRequesterRequired)
+	_, err = gate.Request(WithAuditActor(context.Background(), " "), input)
+	assert.ErrorIs(t, err, ErrRequesterRequired)
+	assert.Empty(t, gate.List())
+
+	approval, err := gate.Request(ctx, input)
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStatusPending, approval.Status)
+	assert.Equal(t, []string{"overnight", "international"}, approval.Reasons)
+	assert.Equal(t, "alice", approval.Requester)
+	assert.Equal(t, clock.now.Add(time.Hour), approval.Expires)
+	assert.Equal(t, []Approval{approval}, gate.Pending())
+
+	_, err = gate.Approve(approval.ID, " ", "")
+	assert.ErrorIs(t, err, ErrApproverRequired)
+	_, err = gate.Approve(approval.ID, "Alice", "")
+	assert.ErrorIs(t, err, ErrSelfApproval)
+	_, err = gate.Approve("missing", "bob", "")
+	assert.ErrorIs(t, err, ErrApprovalNotFound)
+
+	clock.Add(time.Minute)
+	approved, err := gate.Approve(approval.ID, "bob", "client paid for overnight")
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStatusApproved, approved.Status)
+	assert.Equal(t, "bob", approved.Approver)
+	assert.Equal(t, "client paid for overnight", approved.Comment)
+	assert.Equal(t, clock.now, approved.Decided)
+	assert.Empty(t, gate.Pending())
+
+	_, err = gate.Reject(approval.ID, "carol", "too late")
+	assert.ErrorIs(t, err, ErrApprovalNotPending)
+
+	// Rejected
+	rejected, err := gate.Request(ctx, input)
+	assert.NoError(t, err)
+	rejected, err = gate.Reject(rejected.ID, "bob", "use USPS")
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStatusRejected, rejected.Status)
+
+	// Expired
+	clock.Add(time.Minute)
+	expired, err := gate.Request(ctx, input)
+	assert.NoError(t, err)
+	clock.Add(time.Hour)
+	assert.Empty(t, gate.Pending())
+	got, err := gate.Get(expired.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStatusExpired, got.Status)
+	_, err = gate.Approve(expired.ID, "bob", "")
+	assert.ErrorIs(t, err, ErrApprovalNotPending)
+
+	// Files can't be saved
+	input.File = strings.NewReader("")
+	_, err = gate.Request(ctx, input)
+	assert.ErrorIs(t, err, ErrFileNotPersisted)
+
+	// Approvals are persisted
+	reloaded, err := NewApprovalGate(testApprovalRules(), path, clock)
+	assert.NoError(t, err)
+	assert.Equal(t, gate.List(), reloaded.List())
+	assert.Equal(t, []string{ApprovalStatusApproved, ApprovalStatusRejected, ApprovalStatusExpired},
+		[]string{reloaded.List()[0].Status, reloaded.List()[1].Status, reloaded.List()[2].Status})
+}
+
+func TestApprovalGateUnknownRequester(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approvals.json")
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	// Saved before requesters were required
+	input := testOrderInput()
+	input.Service = "FEDEX_OVERNIGHT"
+	assert.NoError(t, writeJSONFile(path, []*Approval{
+		{ID: "some_id", Input: input, Status: ApprovalStatusPending, Requested: clock.now, Expires: clock.now.Add(time.Hour)},
+		{ID: "other_id", Input: input, Status: ApprovalStatusPending, Requested: clock.now, Expires: clock.now.Add(time.Hour)},
+	}))
+
+	gate, err := NewApprovalGate(testApprovalRules(), path, clock)
+	assert.NoError(t, err)
+
+	_, err = gate.Approve("some_id", "bob", "")
+	assert.ErrorIs(t, err, ErrSelfApproval)
+	approval, err := gate.Get("some_id")
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStatusPending, approval.Status)
+
+	rejected, err := gate.Reject("other_id", "bob", "who asked for this")
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStatusRejected, rejected.Status)
+}
+
+func TestCreateOrderApproval(t *testing.T) {
+	// The test order input isn't in the US
+	gate, err := NewApprovalGate(testApprovalRules()[:2], filepath.Join(t.TempDir(), "approvals.json"), nil)
+	assert.NoError(t, err)
+
+	mailformClient, err := New(&Config{
+		Token:     "someToken",
+		Approvals: gate,
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", DefaultBaseURL+ordersEndpoint,
+		jsonResponder(http.StatusOK, `{"success": true, "data": {"id": "some_id"}}`))
+
+	// Orders that don't match a rule are sent
+	_, err = mailformClient.CreateOrder(testOrderInput())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+
+	input := testOrderInput()
+	input.Service = "FEDEX_OVERNIGHT"
+	_, err = mailformClient.CreateOrder(input)
+	assert.ErrorIs(t, err, ErrRequesterRequired)
+	assert.True(t, isOrderRejected(err))
+	assert.Empty(t, gate.List())
+
+	ctx := WithAuditActor(context.Background(), "alice")
+	_, err = mailformClient.CreateOrderWithContext(ctx, input)
+	approvalErr := &ErrApprovalRequired{}
+	assert.True(t, errors.As(err, &approvalErr))
+	assert.Equal(t, "alice", approvalErr.Approval.Requester)
+	assert.Equal(t, "order requires approval "+approvalErr.Approval.ID+": overnight", err.Error())
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+
+	id := approvalErr.Approval.ID
+	_, err = mailformClient.SubmitApproved(ctx, id)
+	assert.ErrorIs(t, err, ErrApprovalNotApproved)
+
+	_, err = gate.Approve(id, "bob", "")
+	assert.NoError(t, err)
+	order, err := mailformClient.SubmitApproved(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "some_id", order.Data.ID)
+	assert.Equal(t, 2, httpmock.GetTotalCallCount())
+
+	approval, err := gate.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStatusSubmitted, approval.Status)
+	assert.Equal(t, "some_id", approval.OrderID)
+
+	// Submitted once
+	_, err = mailformClient.SubmitApproved(ctx, id)
+	assert.ErrorIs(t, err, ErrApprovalNotApproved)
+	assert.Equal(t, 2, httpmock.GetTotalCallCount())
+
+	_, err = (&Client{}).SubmitApproved(ctx, id)
+	assert.ErrorIs(t, err, ErrNoApprovalGate)
+}
+
+func TestCreateOrderApprovalFile(t *testing.T) {
+	// The test order input isn't in the US
+	gate, err := NewApprovalGate(testApprovalRules()[:2], filepath.Join(t.TempDir(), "approvals.json"), nil)
+	assert.NoError(t, err)
+
+	mailformClient, err := New(&Config{
+		Token:     "someToken",
+		Approvals: gate,
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", DefaultBaseURL+ordersEndpoint,
+		jsonResponder(http.StatusOK, `{"success": true, "data": {"id": "some_id"}}`))
+
+	ctx := WithAuditActor(context.Background(), "alice")
+	input := testOrderInput()
+	input.File = strings.NewReader("some_pdf")
+
+	// Files that don't need approval are sent
+	_, err = mailformClient.CreateOrderWithContext(ctx, input)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+
+	// Files that need approval can't be held, so they're refused
+	input.File = strings.NewReader("some_pdf")
+	input.Service = "FEDEX_OVERNIGHT"
+	_, err = mailformClient.CreateOrderWithContext(ctx, input)
+	assert.ErrorIs(t, err, ErrFileNotPersisted)
+	assert.EqualError(t, err, "order requires approval: overnight: "+ErrFileNotPersisted.Error())
+	approvalErr := &ErrApprovalRequired{}
+	assert.False(t, errors.As(err, &approvalErr))
+	assert.Empty(t, gate.List())
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
+
+func TestCreateOrderApprovalDryRun(t *testing.T) {
+	// The test order input isn't in the US
+	gate, err := NewApprovalGate(testApprovalRules()[:2], filepath.Join(t.TempDir(), "approvals.json"), nil)
+	assert.NoError(t, err)
+
+	mailformClient, err := New(&Config{
+		Token:     "someToken",
+		DryRun:    true,
+		Approvals: gate,
+	})
+	assert.NoError(t, err)
+
+	input := testOrderInput()
+	input.Service = "FEDEX_OVERNIGHT"
+	_, err = mailformClient.CreateOrder(input)
+	approvalErr := &ErrApprovalRequired{}
+	assert.True(t, errors.As(err, &approvalErr))
+	assert.Equal(t, "order requires approval: overnight", err.Error())
+	assert.Empty(t, gate.List())
+}
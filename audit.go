@@ -0,0 +1,308 @@
+package mailform
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Audit actions
+	AuditActionCreateOrder = "create_order"
+	AuditActionCancelOrder = "cancel_order"
+)
+
+var (
+	// ErrAuditLogClosed is returned when appending to an audit log that has been closed.
+	ErrAuditLogClosed = errors.New("audit log is closed")
+)
+
+// auditActorKey is the context key for the actor recorded in the audit log.
+type auditActorKey struct{}
+
+// WithAuditActor returns a context that records actor in the audit log instead of the audit log's default actor.
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditRecord is a single entry in the audit log.
+// Every record includes the hash of the record before it, so editing or removing a record breaks the chain.
+type AuditRecord struct {
+	Seq               int64     `json:"seq"`
+	Time              time.Time `json:"time"`
+	Actor             string    `json:"actor"`
+	Action            string    `json:"action"`
+	OrderID           string    `json:"order_id,omitempty"`
+	CustomerReference string    `json:"customer_reference,omitempty"`
+	Service           string    `json:"service,omitempty"`
+	// RecipientHash is the SHA-256 of the recipient address so recipients can be matched without storing them
+	RecipientHash string `json:"recipient_hash,omitempty"`
+	// DocumentSHA256 is the SHA-256 of the uploaded document, empty if it was sent by URL
+	DocumentSHA256 string `json:"document_sha256,omitempty"`
+	DocumentURL    string `json:"document_url,omitempty"`
+	// Cost is the order total in cents
+	Cost   int    `json:"cost"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// PrevHash is the hash of the previous record, empty for the first record
+	PrevHash string `json:"prev_hash"`
+	// Hash is the SHA-256 of this record with Hash left empty
+	Hash string `json:"hash"`
+}
+
+// hash computes the hash of the record.
+func (r AuditRecord) hash() (string, error) {
+	r.Hash = ""
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditLog is an append-only, hash chained JSON lines log of mail activity.
+type AuditLog struct {
+	actor string
+	clock Clock
+
+	mu       sync.Mutex
+	file     *os.File
+	seq      int64
+	lastHash string
+}
+
+// OpenAuditLog opens the audit log at path, creating it if it doesn't exist.
+// actor is recorded for every entry unless overridden with WithAuditActor. If clock is nil, the system clock is used.
+func OpenAuditLog(path string, actor string, clock Clock) (*AuditLog, error) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &AuditLog{
+		actor: actor,
+		clock: clock,
+		file:  file,
+	}
+
+	// Pick up the chain where it left off
+	err = replayJournal(file, func(line int, data []byte) error {
+		record := &AuditRecord{}
+		err := json.Unmarshal(data, record)
+		if err != nil {
+			return fmt.Errorf("audit log line %d: %w", line, err)
+		}
+
+		l.seq = record.Seq
+		l.lastHash = record.Hash
+		return nil
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Append adds a record to the audit log, filling in the sequence number, time, actor and hashes.
+func (l *AuditLog) Append(ctx context.Context, r AuditRecord) (AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return r, ErrAuditLogClosed
+	}
+
+	r.Seq = l.seq + 1
+	r.Time = l.clock.Now().UTC()
+	r.Actor = l.actor
+	if actor, ok := ctx.Value(auditActorKey{}).(string); ok {
+		r.Actor = actor
+	}
+	r.PrevHash = l.lastHash
+
+	hash, err := r.hash()
+	if err != nil {
+		return r, err
+	}
+	r.Hash = hash
+
+	err = appendJournal(l.file, r)
+	if err != nil {
+		return r, err
+	}
+
+	l.seq = r.Seq
+	l.lastHash = r.Hash
+
+	return r, nil
+}
+
+// RecordCancel records the cancellation of an order.
+// The client calls this for every order it cancels, so it only needs calling directly for cancellations made elsewhere,
+// such as the mailform dashboard.
+func (l *AuditLog) RecordCancel(ctx context.Context, orderID string, reason string) error {
+	_, err := l.Append(ctx, AuditRecord{
+		Action:  AuditActionCancelOrder,
+		OrderID: orderID,
+		Reason:  reason,
+	})
+
+	return err
+}
+
+// recordCreateOrder records a CreateOrder call and its outcome.
+func (l *AuditLog) recordCreateOrder(ctx context.Context, o OrderInput, order *Order, orderErr error) error {
+	record := AuditRecord{
+		Action:            AuditActionCreateOrder,
+		CustomerReference: o.CustomerReference,
+		Service:           o.Service,
+		RecipientHash:     recipientHash(o),
+		DocumentURL:       o.URL,
+	}
+
+	if o.File != nil || o.FilePath != "" {
+		record.DocumentURL = ""
+		info := &RequestInfo{}
+		err := info.fileInfo(o)
+		if err == nil {
+			record.DocumentSHA256 = info.FileSHA256
+		}
+	}
+
+	if order != nil {
+		record.OrderID = order.Data.ID
+		record.Cost = orderCost(order)
+	}
+
+	if orderErr != nil {
+		record.Error = orderErr.Error()
+	}
+
+	_, err := l.Append(ctx, record)
+
+	return err
+}
+
+// Close closes the audit log.
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+
+	return err
+}
+
+// recipientHash hashes the recipient of an order input.
+// Fields are trimmed and lower cased so trivial formatting differences hash the same.
+func recipientHash(o OrderInput) string {
+	fields := []string{o.ToName, o.ToOrganization, o.ToAddress1, o.ToAddress2, o.ToCity, o.ToState, o.ToPostcode, o.ToCountry}
+	for i, field := range fields {
+		fields[i] = strings.ToLower(strings.TrimSpace(field))
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\n")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditProblem is something wrong found while verifying an audit log.
+type AuditProblem struct {
+	// Line is the line number in the audit log
+	Line    int
+	Seq     int64
+	Problem string
+}
+
+// AuditVerification is the result of verifying an audit log.
+type AuditVerification struct {
+	Records int
+	// LastHash is the hash of the last record.
+	// Records removed from the end of the log can only be detected by comparing it to a copy kept somewhere else.
+	LastHash string
+	Problems []AuditProblem
+}
+
+// Valid returns true if no problems were found.
+func (v *AuditVerification) Valid() bool {
+	return len(v.Problems) == 0
+}
+
+// VerifyAuditLog reads an audit log and checks that every record's hash is correct,
+// every record links to the one before it and there are no gaps in the sequence numbers.
+func VerifyAuditLog(r io.Reader) (*AuditVerification, error) {
+	verification := &AuditVerification{
+		Problems: []AuditProblem{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var seq int64
+	lastHash := ""
+	line := 0
+	for scanner.Scan() {
+		line++
+		problem := func(seq int64, format string, a ...interface{}) {
+			verification.Problems = append(verification.Problems, AuditProblem{
+				Line:    line,
+				Seq:     seq,
+				Problem: fmt.Sprintf(format, a...),
+			})
+		}
+
+		record := AuditRecord{}
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			problem(0, "invalid record: %s", err)
+			continue
+		}
+		verification.Records++
+
+		if record.Seq != seq+1 {
+			problem(record.Seq, "expected sequence number %d, got %d", seq+1, record.Seq)
+		}
+
+		if record.PrevHash != lastHash {
+			problem(record.Seq, "previous hash does not match the record before it")
+		}
+
+		hash, err := record.hash()
+		if err != nil {
+			return verification, err
+		}
+		if hash != record.Hash {
+			problem(record.Seq, "record hash does not match its contents")
+		}
+
+		seq = record.Seq
+		lastHash = record.Hash
+	}
+	verification.LastHash = lastHash
+
+	return verification, scanner.Err()
+}
@@ -0,0 +1,140 @@
+package mailform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLogCreateOrder(t *testing.T) {
+	fakeEndpoint := fmt.Sprintf("%s%s", DefaultBaseURL, ordersEndpoint)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	auditLog, err := OpenAuditLog(path, "some_service", clock)
+	assert.NoError(t, err)
+	mailformClient, err := New(&Config{
+		Audit: auditLog,
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder(http.MethodPost, fakeEndpoint,
+		jsonResponder(200, `{"success":true,"data":{"id":"someID","total":123}}`))
+
+	input := testOrderInput()
+	input.CustomerReference = "some_customer_reference"
+	input.URL = "https://example.com/some.pdf"
+	_, err = mailformClient.CreateOrderWithContext(WithAuditActor(context.Background(), "some_user"), input)
+	assert.NoError(t, err)
+
+	// Failures are recorded too
+	_, err = mailformClient.CreateOrder(OrderInput{})
+	assert.Error(t, err)
+	assert.NoError(t, auditLog.Close())
+
+	// Ensure the chain continues after reopening
+	auditLog, err = OpenAuditLog(path, "some_service", clock)
+	assert.NoError(t, err)
+	assert.NoError(t, auditLog.RecordCancel(context.Background(), "someID", "customer request"))
+	assert.NoError(t, auditLog.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	verification, err := VerifyAuditLog(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.True(t, verification.Valid())
+	assert.Equal(t, 3, verification.Records)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Contains(t, lines[0], `"actor":"some_user"`)
+	assert.Contains(t, lines[0], `"order_id":"someID"`)
+	assert.Contains(t, lines[0], `"cost":123`)
+	assert.Contains(t, lines[0], `"recipient_hash":"`+recipientHash(input)+`"`)
+	assert.Contains(t, lines[1], `"actor":"some_service"`)
+	assert.Contains(t, lines[1], `"error":"service code`)
+	assert.Contains(t, lines[2], `"action":"cancel_order"`)
+}
+
+func TestAuditLogSkipsDryRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := OpenAuditLog(path, "some_service", nil)
+	assert.NoError(t, err)
+	mailformClient, err := New(&Config{
+		DryRun: true,
+		Audit:  auditLog,
+	})
+	assert.NoError(t, err)
+
+	_, err = mailformClient.CreateOrder(testOrderInput())
+	assert.NoError(t, err)
+	assert.NoError(t, auditLog.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := OpenAuditLog(path, "some_service", nil)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = auditLog.Append(context.Background(), AuditRecord{
+			Action:  AuditActionCreateOrder,
+			OrderID: fmt.Sprintf("order%d", i),
+			Cost:    100,
+		})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, auditLog.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	tests := []struct {
+		name             string
+		input            []string
+		expectedProblems int
+	}{
+		{
+			name:  "EnsureUntouchedLogIsValid",
+			input: lines,
+		},
+		{
+			name:             "EnsureEditIsDetected",
+			input:            []string{lines[0], strings.Replace(lines[1], `"cost":100`, `"cost":1`, 1), lines[2]},
+			expectedProblems: 1,
+		},
+		{
+			name:             "EnsureGapIsDetected",
+			input:            []string{lines[0], lines[2]},
+			expectedProblems: 2,
+		},
+		{
+			name:             "EnsureGarbageIsDetected",
+			input:            append([]string{"garbage"}, lines...),
+			expectedProblems: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verification, err := VerifyAuditLog(strings.NewReader(strings.Join(test.input, "\n")))
+			assert.NoError(t, err)
+			assert.Len(t, verification.Problems, test.expectedProblems)
+		})
+	}
+}
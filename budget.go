@@ -0,0 +1,272 @@
+package mailform
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	// Budget limit names used in ErrBudgetExceeded
+	BudgetLimitDailySpend    = "daily spend"
+	BudgetLimitMonthlySpend  = "monthly spend"
+	BudgetLimitDailyOrders   = "daily orders"
+	BudgetLimitMonthlyOrders = "monthly orders"
+)
+
+// BudgetLimits are the caps enforced by a BudgetGuard.
+// Spend is in cents, the same as Order totals. A zero value means there is no cap.
+type BudgetLimits struct {
+	DailySpend    int
+	MonthlySpend  int
+	DailyOrders   int
+	MonthlyOrders int
+}
+
+// ErrBudgetExceeded is returned when submitting an order would cross a budget cap.
+type ErrBudgetExceeded struct {
+	// Limit is the name of the cap that would be crossed
+	Limit string
+	// Cap is the configured value of the cap
+	Cap int
+	// Current is what has already been used in the current period
+	Current int
+	// Requested is what the order would add
+	Requested int
+}
+
+func (e *ErrBudgetExceeded) Error() string {
+	return fmt.Sprintf("%s budget exceeded: %d used + %d requested > %d cap", e.Limit, e.Current, e.Requested, e.Cap)
+}
+
+// BudgetUsage is the spend and order count used in the current day and month.
+type BudgetUsage struct {
+	Day         string `json:"day"`
+	DailySpend  int    `json:"daily_spend"`
+	DailyOrders int    `json:"daily_orders"`
+
+	Month         string `json:"month"`
+	MonthlySpend  int    `json:"monthly_spend"`
+	MonthlyOrders int    `json:"monthly_orders"`
+}
+
+// budgetReservation is the estimated cost held against the budget while an order is being sent.
+type budgetReservation struct {
+	spend int
+}
+
+// BudgetGuard stops the client from spending more than the configured limits.
+// Usage is tracked from the totals of orders that were created successfully,
+// and orders are refused before being sent if their estimated cost would cross a cap.
+type BudgetGuard struct {
+	limits BudgetLimits
+	// estimate returns the estimated cost of an order input in cents
+	estimate func(OrderInput) int
+	path     string
+	clock    Clock
+
+	mu    sync.Mutex
+	usage BudgetUsage
+	// reserved is held for orders that are in flight so concurrent sends can't sneak past a cap
+	reservedSpend  int
+	reservedOrders int
+}
+
+// NewBudgetGuard returns a budget guard that persists usage to the file at path, loading it if it already exists.
+// If path is empty, usage is only kept in memory. If clock is nil, the system clock is used.
+func NewBudgetGuard(limits BudgetLimits, path string, clock Clock) (*BudgetGuard, error) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	g := &BudgetGuard{
+		limits:   limits,
+		estimate: estimateMinimumPrice,
+		path:     path,
+		clock:    clock,
+	}
+
+	if path != "" {
+		err := readJSONFile(path, &g.usage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
+
+// SetEstimator sets the function used to estimate the cost of an order before it's sent.
+// By default, orders are estimated as a single page using the DefaultPriceTable since the page count isn't known.
+// Setting nil estimates orders at 0 so they are only refused once a spend cap has already been reached.
+func (g *BudgetGuard) SetEstimator(estimate func(OrderInput) int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.estimate = estimate
+}
+
+// rollover resets usage when a new day or month starts. Callers must hold the lock.
+func (g *BudgetGuard) rollover() {
+	now := g.clock.Now()
+
+	day := now.Format("2006-01-02")
+	if g.usage.Day != day {
+		g.usage.Day = day
+		g.usage.DailySpend = 0
+		g.usage.DailyOrders = 0
+	}
+
+	month := now.Format("2006-01")
+	if g.usage.Month != month {
+		g.usage.Month = month
+		g.usage.MonthlySpend = 0
+		g.usage.MonthlyOrders = 0
+	}
+}
+
+// Usage returns the usage for the current day and month.
+func (g *BudgetGuard) Usage() BudgetUsage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.rollover()
+
+	return g.usage
+}
+
+// Check returns an ErrBudgetExceeded if sending the order input would cross a cap.
+func (g *BudgetGuard) Check(o OrderInput) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.rollover()
+
+	return g.check(g.estimateCost(o))
+}
+
+// estimateCost estimates the cost of an order input. Callers must hold the lock.
+func (g *BudgetGuard) estimateCost(o OrderInput) int {
+	if g.estimate == nil {
+		return 0
+	}
+
+	return g.estimate(o)
+}
+
+// check checks an order costing spend against every cap. Callers must hold the lock.
+func (g *BudgetGuard) check(spend int) error {
+	checks := []struct {
+		limit     string
+		cap       int
+		current   int
+		requested int
+	}{
+		{BudgetLimitDailyOrders, g.limits.DailyOrders, g.usage.DailyOrders + g.reservedOrders, 1},
+		{BudgetLimitMonthlyOrders, g.limits.MonthlyOrders, g.usage.MonthlyOrders + g.reservedOrders, 1},
+		{BudgetLimitDailySpend, g.limits.DailySpend, g.usage.DailySpend + g.reservedSpend, spend},
+		{BudgetLimitMonthlySpend, g.limits.MonthlySpend, g.usage.MonthlySpend + g.reservedSpend, spend},
+	}
+
+	for _, c := range checks {
+		if c.cap == 0 {
+			continue
+		}
+		// Spend caps that are already used up refuse orders even if they're estimated to be free
+		if c.current+c.requested > c.cap || c.current >= c.cap {
+			return &ErrBudgetExceeded{
+				Limit:     c.limit,
+				Cap:       c.cap,
+				Current:   c.current,
+				Requested: c.requested,
+			}
+		}
+	}
+
+	return nil
+}
+
+// reserve checks an order input against the budget and holds its estimated cost until it is committed or released.
+func (g *BudgetGuard) reserve(o OrderInput) (*budgetReservation, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.rollover()
+
+	spend := g.estimateCost(o)
+	err := g.check(spend)
+	if err != nil {
+		return nil, err
+	}
+
+	g.reservedSpend += spend
+	g.reservedOrders++
+
+	return &budgetReservation{spend: spend}, nil
+}
+
+// release gives back a reservation for an order that wasn't created.
+func (g *BudgetGuard) release(r *budgetReservation) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.reservedSpend -= r.spend
+	g.reservedOrders--
+}
+
+// commit swaps a reservation for the actual cost of the order that was created.
+func (g *BudgetGuard) commit(r *budgetReservation, order *Order) error {
+	g.mu.Lock()
+	g.reservedSpend -= r.spend
+	g.reservedOrders--
+	g.mu.Unlock()
+
+	return g.Record(order)
+}
+
+// Record adds a created order to the budget usage and persists it.
+// The client calls this for every order it creates, so it only needs calling directly for orders created elsewhere.
+func (g *BudgetGuard) Record(order *Order) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.rollover()
+
+	cost := orderCost(order)
+	g.usage.DailySpend += cost
+	g.usage.MonthlySpend += cost
+	g.usage.DailyOrders++
+	g.usage.MonthlyOrders++
+
+	if g.path == "" {
+		return nil
+	}
+
+	return writeJSONFile(g.path, g.usage)
+}
+
+// estimateMinimumPrice estimates an order input as a single page with the DefaultPriceTable.
+func estimateMinimumPrice(o OrderInput) int {
+	estimate, err := EstimatePrice(o, 1)
+	if err != nil {
+		return 0
+	}
+
+	return estimate.Total
+}
+
+// orderCost returns the cost of an order in cents.
+// The order total is used if mailform sent one, otherwise the line item pricing is added up.
+func orderCost(order *Order) int {
+	if order.Data.Total != 0 {
+		return order.Data.Total
+	}
+
+	total := 0
+	for _, lineitem := range order.Data.Lineitems {
+		for _, price := range lineitem.Pricing {
+			total += price.Value
+		}
+	}
+
+	return total
+}
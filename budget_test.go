@@ -0,0 +1,212 @@
+package mailform
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetGuardCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		limits        BudgetLimits
+		usage         BudgetUsage
+		estimate      int
+		expectErr     bool
+		expectedLimit string
+	}{
+		{
+			name:   "EnsureUnlimitedByDefault",
+			limits: BudgetLimits{},
+			usage: BudgetUsage{
+				DailySpend:  1000000,
+				DailyOrders: 1000000,
+			},
+			estimate: 1000,
+		},
+		{
+			name:   "EnsureDailyOrdersCapped",
+			limits: BudgetLimits{DailyOrders: 2},
+			usage: BudgetUsage{
+				DailyOrders:   2,
+				MonthlyOrders: 2,
+			},
+			expectErr:     true,
+			expectedLimit: BudgetLimitDailyOrders,
+		},
+		{
+			name:   "EnsureMonthlyOrdersCapped",
+			limits: BudgetLimits{DailyOrders: 10, MonthlyOrders: 20},
+			usage: BudgetUsage{
+				DailyOrders:   1,
+				MonthlyOrders: 20,
+			},
+			expectErr:     true,
+			expectedLimit: BudgetLimitMonthlyOrders,
+		},
+		{
+			name:   "EnsureEstimateCanCrossDailySpend",
+			limits: BudgetLimits{DailySpend: 1000},
+			usage: BudgetUsage{
+				DailySpend: 500,
+			},
+			estimate:      600,
+			expectErr:     true,
+			expectedLimit: BudgetLimitDailySpend,
+		},
+		{
+			name:   "EnsureUsedUpSpendCapRefusesFreeEstimate",
+			limits: BudgetLimits{MonthlySpend: 1000},
+			usage: BudgetUsage{
+				MonthlySpend: 1000,
+			},
+			expectErr:     true,
+			expectedLimit: BudgetLimitMonthlySpend,
+		},
+		{
+			name:   "EnsureUnderCapAllowed",
+			limits: BudgetLimits{DailySpend: 1000, DailyOrders: 5},
+			usage: BudgetUsage{
+				DailySpend:  500,
+				DailyOrders: 4,
+			},
+			estimate: 500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2022, 1, 15, 0, 0, 0, 0, time.UTC)}
+			guard, err := NewBudgetGuard(test.limits, "", clock)
+			assert.NoError(t, err)
+			test.usage.Day = "2022-01-15"
+			test.usage.Month = "2022-01"
+			guard.usage = test.usage
+			guard.SetEstimator(func(OrderInput) int { return test.estimate })
+
+			err = guard.Check(testOrderInput())
+			if test.expectErr {
+				budgetErr := &ErrBudgetExceeded{}
+				assert.ErrorAs(t, err, &budgetErr)
+				assert.Equal(t, test.expectedLimit, budgetErr.Limit)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestBudgetGuardRollover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.json")
+	clock := &fakeClock{now: time.Date(2022, 1, 30, 12, 0, 0, 0, time.UTC)}
+	guard, err := NewBudgetGuard(BudgetLimits{}, path, clock)
+	assert.NoError(t, err)
+
+	order := &Order{}
+	order.Data.Total = 250
+	assert.NoError(t, guard.Record(order))
+	assert.NoError(t, guard.Record(order))
+
+	// Ensure usage survives a restart
+	guard, err = NewBudgetGuard(BudgetLimits{}, path, clock)
+	assert.NoError(t, err)
+	usage := guard.Usage()
+	assert.Equal(t, 500, usage.DailySpend)
+	assert.Equal(t, 2, usage.MonthlyOrders)
+
+	// Next day resets daily usage only
+	clock.Add(12 * time.Hour)
+	usage = guard.Usage()
+	assert.Equal(t, 0, usage.DailySpend)
+	assert.Equal(t, 0, usage.DailyOrders)
+	assert.Equal(t, 500, usage.MonthlySpend)
+
+	// Next month resets monthly usage
+	clock.Add(24 * time.Hour)
+	usage = guard.Usage()
+	assert.Equal(t, "2022-02", usage.Month)
+	assert.Equal(t, 0, usage.MonthlySpend)
+}
+
+func TestOrderCost(t *testing.T) {
+	order := &Order{}
+	order.Data.Lineitems = make([]struct {
+		ID        string "json:\"id\""
+		Pagecount int    "json:\"pagecount\""
+		To        struct {
+			Name         string "json:\"name\""
+			Address1     string "json:\"address1\""
+			Address2     string "json:\"address2\""
+			City         string "json:\"city\""
+			State        string "json:\"state\""
+			Postcode     string "json:\"postcode\""
+			Country      string "json:\"country\""
+			Formatted    string "json:\"formatted\""
+			Organization string "json:\"organization\""
+		} "json:\"to\""
+		From struct {
+			Name         string "json:\"name\""
+			Address1     string "json:\"address1\""
+			Address2     string "json:\"address2\""
+			City         string "json:\"city\""
+			State        string "json:\"state\""
+			Postcode     string "json:\"postcode\""
+			Country      string "json:\"country\""
+			Formatted    string "json:\"formatted\""
+			Organization string "json:\"organization\""
+		} "json:\"from\""
+		Simplex bool   "json:\"simplex\""
+		Color   bool   "json:\"color\""
+		Service string "json:\"service\""
+		Pricing []struct {
+			Type  string "json:\"type\""
+			Value int    "json:\"value\""
+		} "json:\"pricing\""
+	}, 1)
+	order.Data.Lineitems[0].Pricing = []struct {
+		Type  string "json:\"type\""
+		Value int    "json:\"value\""
+	}{
+		{Type: "postage", Value: 60},
+		{Type: "printing", Value: 40},
+	}
+
+	// Pricing is added up without a total
+	assert.Equal(t, 100, orderCost(order))
+
+	// Total takes precedence
+	order.Data.Total = 150
+	assert.Equal(t, 150, orderCost(order))
+}
+
+func TestCreateOrderBudget(t *testing.T) {
+	fakeEndpoint := fmt.Sprintf("%s%s", DefaultBaseURL, ordersEndpoint)
+	guard, err := NewBudgetGuard(BudgetLimits{DailySpend: 1000}, "", nil)
+	assert.NoError(t, err)
+	mailformClient, err := New(&Config{
+		Budget: guard,
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder(http.MethodPost, fakeEndpoint,
+		jsonResponder(200, `{"success":true,"data":{"id":"someID","total":1000}}`))
+
+	_, err = mailformClient.CreateOrder(testOrderInput())
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, guard.Usage().DailySpend)
+
+	// Budget is used up so the next order is never sent
+	_, err = mailformClient.CreateOrder(testOrderInput())
+	budgetErr := &ErrBudgetExceeded{}
+	assert.ErrorAs(t, err, &budgetErr)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	assert.Equal(t, 0, guard.reservedOrders)
+}
@@ -0,0 +1,283 @@
+package mailform
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// MinCheckAmount is the smallest amount a check can be written for
+	MinCheckAmount Cents = 1
+	// MaxCheckAmount is the largest amount a check can be written for, $999,999.99
+	MaxCheckAmount Cents = 99999999
+	// MaxCheckMemoLength is the most characters that fit on the memo line
+	MaxCheckMemoLength = 40
+)
+
+var (
+	// ErrCheckNumberUsed is returned when a check number has already been used on the bank account.
+	ErrCheckNumberUsed = errors.New("check number has already been used on this bank account")
+	// ErrInvalidAmount is returned when parsing an amount of money that isn't dollars and cents.
+	ErrInvalidAmount = errors.New("invalid amount")
+)
+
+// Cents is an amount of money in cents.
+type Cents int
+
+// String formats the amount as dollars, such as $1,234.56.
+func (c Cents) String() string {
+	return formatMoney(int(c))
+}
+
+// ParseCents parses dollars, such as 1234.56, $1,234.56 or 12, into cents.
+func ParseCents(s string) (Cents, error) {
+	value := strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(s), "$"), ",", "")
+	if value == "" || value == "." {
+		return 0, fmt.Errorf("%w: '%s'", ErrInvalidAmount, s)
+	}
+	dollars, cents := value, "00"
+	if i := strings.Index(value, "."); i >= 0 {
+		dollars, cents = value[:i], value[i+1:]
+		if len(cents) == 1 {
+			cents += "0"
+		}
+	}
+	if dollars == "" {
+		dollars = "0"
+	}
+
+	d, err := strconv.ParseUint(dollars, 10, 31)
+	if err != nil || len(cents) != 2 {
+		return 0, fmt.Errorf("%w: '%s'", ErrInvalidAmount, s)
+	}
+	c, err := strconv.ParseUint(cents, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%w: '%s'", ErrInvalidAmount, s)
+	}
+
+	return Cents(d*100 + c), nil
+}
+
+var (
+	// numberWords are the words of the numbers below 20
+	numberWords = []string{
+		"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten",
+		"eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen",
+	}
+	// tensWords are the words of the multiples of ten, from twenty
+	tensWords = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	// scaleWords are the words of the powers of a thousand
+	scaleWords = []string{"", "thousand", "million", "billion"}
+)
+
+// Words writes the amount out the way it's written on the second line of a check,
+// such as "One thousand two hundred thirty-four and 56/100 dollars".
+func (c Cents) Words() string {
+	sign := ""
+	if c < 0 {
+		sign = "minus "
+		c = -c
+	}
+
+	words := sign + numberInWords(int(c)/100) + fmt.Sprintf(" and %02d/100 dollars", int(c)%100)
+
+	return strings.ToUpper(words[:1]) + words[1:]
+}
+
+// numberInWords writes out a whole number, such as one thousand two hundred thirty-four.
+func numberInWords(n int) string {
+	if n == 0 {
+		return numberWords[0]
+	}
+
+	groups := []string{}
+	for scale := 0; n > 0; scale++ {
+		group := n % 1000
+		n /= 1000
+		if group == 0 {
+			continue
+		}
+
+		words := hundredsInWords(group)
+		if scaleWords[scale] != "" {
+			words += " " + scaleWords[scale]
+		}
+		groups = append([]string{words}, groups...)
+	}
+
+	return strings.Join(groups, " ")
+}
+
+// hundredsInWords writes out a number from 1 to 999.
+func hundredsInWords(n int) string {
+	words := []string{}
+	if n >= 100 {
+		words = append(words, numberWords[n/100], "hundred")
+		n %= 100
+	}
+
+	switch {
+	case n == 0:
+	case n < 20:
+		words = append(words, numberWords[n])
+	case n%10 == 0:
+		words = append(words, tensWords[n/10])
+	default:
+		words = append(words, tensWords[n/10]+"-"+numberWords[n%10])
+	}
+
+	return strings.Join(words, " ")
+}
+
+// Check is a check to be printed and mailed with an order.
+type Check struct {
+	// BankAccount is the identifier of the mailform bank account the check is drawn on
+	BankAccount string `json:"bank_account"`
+	Amount      Cents  `json:"amount"`
+	// Payee is the name the check is made out to
+	Payee  string `json:"payee"`
+	Number int    `json:"number"`
+	Memo   string `json:"memo,omitempty"`
+}
+
+// Validate checks every required check field is set, the amount is between MinCheckAmount and MaxCheckAmount
+// and the memo fits on the memo line. Errors are *ErrOrderInvalid for the matching OrderInput field.
+func (c Check) Validate() error {
+	genericRejectionStr := "%s not provided, but is required for checks"
+
+	if strings.TrimSpace(c.BankAccount) == "" {
+		return &ErrOrderInvalid{
+			field:   "BankAccount",
+			message: fmt.Sprintf(genericRejectionStr, "BankAccount"),
+		}
+	}
+
+	if c.Amount < MinCheckAmount || c.Amount > MaxCheckAmount {
+		return &ErrOrderInvalid{
+			field:   "Amount",
+			message: fmt.Sprintf("amount: %s must be between %s and %s", c.Amount, MinCheckAmount, MaxCheckAmount),
+		}
+	}
+
+	if strings.TrimSpace(c.Payee) == "" {
+		return &ErrOrderInvalid{
+			field:   "CheckName",
+			message: fmt.Sprintf(genericRejectionStr, "CheckName"),
+		}
+	}
+
+	if c.Number <= 0 {
+		return &ErrOrderInvalid{
+			field:   "CheckNumber",
+			message: fmt.Sprintf(genericRejectionStr, "CheckNumber"),
+		}
+	}
+
+	if utf8.RuneCountInString(c.Memo) > MaxCheckMemoLength {
+		return &ErrOrderInvalid{
+			field:   "CheckMemo",
+			message: fmt.Sprintf("check memo: must be at most %d characters, not %d", MaxCheckMemoLength, utf8.RuneCountInString(c.Memo)),
+		}
+	}
+
+	return nil
+}
+
+// Check returns the check included in the order input, false if it doesn't include one.
+func (o *OrderInput) Check() (Check, bool) {
+	if !o.isCheck() && o.CheckMemo == "" {
+		return Check{}, false
+	}
+
+	return Check{
+		BankAccount: o.BankAccount,
+		Amount:      Cents(o.Amount),
+		Payee:       o.CheckName,
+		Number:      o.CheckNumber,
+		Memo:        o.CheckMemo,
+	}, true
+}
+
+// SetCheck validates a check and includes it in the order input.
+func (o *OrderInput) SetCheck(c Check) error {
+	err := c.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.BankAccount = c.BankAccount
+	o.Amount = int(c.Amount)
+	o.CheckName = c.Payee
+	o.CheckNumber = c.Number
+	o.CheckMemo = c.Memo
+
+	return nil
+}
+
+// CheckNumbers remembers the check numbers used on each bank account so none is used twice.
+// It is safe for concurrent use.
+type CheckNumbers struct {
+	mu   sync.Mutex
+	used map[string]map[int]bool
+}
+
+// NewCheckNumbers returns an empty set of used check numbers.
+func NewCheckNumbers() *CheckNumbers {
+	return &CheckNumbers{
+		used: map[string]map[int]bool{},
+	}
+}
+
+// Used returns true if the check number has been used on the bank account.
+func (n *CheckNumbers) Used(bankAccount string, number int) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.used[bankAccount][number]
+}
+
+// Reserve marks the check's number as used on its bank account, or returns ErrCheckNumberUsed if it already is.
+func (n *CheckNumbers) Reserve(c Check) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.used[c.BankAccount][c.Number] {
+		return fmt.Errorf("%w: check number %d on bank account %s", ErrCheckNumberUsed, c.Number, c.BankAccount)
+	}
+	if n.used[c.BankAccount] == nil {
+		n.used[c.BankAccount] = map[int]bool{}
+	}
+	n.used[c.BankAccount][c.Number] = true
+
+	return nil
+}
+
+// Release marks the check's number as unused, such as when the order it was reserved for was never created.
+func (n *CheckNumbers) Release(c Check) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.used[c.BankAccount], c.Number)
+}
+
+// ValidateChecks validates every check and that no check number is used twice on the same bank account.
+func ValidateChecks(checks []Check) error {
+	numbers := NewCheckNumbers()
+	for _, c := range checks {
+		err := c.Validate()
+		if err != nil {
+			return err
+		}
+
+		err = numbers.Reserve(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
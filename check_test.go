@@ -0,0 +1,238 @@
+package mailform
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCents(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Cents
+		err      bool
+	}{
+		{input: "1234.56", expected: 123456},
+		{input: "$1,234.56", expected: 123456},
+		{input: "12", expected: 1200},
+		{input: "0.5", expected: 50},
+		{input: ".05", expected: 5},
+		{input: " 7.00 ", expected: 700},
+		{input: "1.234", err: true},
+		{input: "-1.00", err: true},
+		{input: "abc", err: true},
+		{input: "", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			cents, err := ParseCents(test.input)
+			if test.err {
+				assert.ErrorIs(t, err, ErrInvalidAmount)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, cents)
+		})
+	}
+}
+
+func TestCentsString(t *testing.T) {
+	assert.Equal(t, "$1,234.56", Cents(123456).String())
+	assert.Equal(t, "$0.01", Cents(1).String())
+}
+
+func TestCentsWords(t *testing.T) {
+	tests := []struct {
+		input    Cents
+		expected string
+	}{
+		{input: 0, expected: "Zero and 00/100 dollars"},
+		{input: 1, expected: "Zero and 01/100 dollars"},
+		{input: 1500, expected: "Fifteen and 00/100 dollars"},
+		{input: 4299, expected: "Forty-two and 99/100 dollars"},
+		{input: 10000, expected: "One hundred and 00/100 dollars"},
+		{input: 123456, expected: "One thousand two hundred thirty-four and 56/100 dollars"},
+		{input: 100000000, expected: "One million and 00/100 dollars"},
+		{input: 99999999, expected: "Nine hundred ninety-nine thousand nine hundred ninety-nine and 99/100 dollars"},
+		{input: 200030000, expected: "Two million three hundred and 00/100 dollars"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.input.Words())
+		})
+	}
+}
+
+func testCheck() Check {
+	return Check{
+		BankAccount: "acct_1",
+		Amount:      123456,
+		Payee:       "Jane Doe",
+		Number:      1001,
+		Memo:        "Invoice 42",
+	}
+}
+
+func TestCheckValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		modify        func(c *Check)
+		expectedField string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *Check) {},
+		},
+		{
+			name:          "no bank account",
+			modify:        func(c *Check) { c.BankAccount = " " },
+			expectedField: "BankAccount",
+		},
+		{
+			name:          "zero amount",
+			modify:        func(c *Check) { c.Amount = 0 },
+			expectedField: "Amount",
+		},
+		{
+			name:          "too large",
+			modify:        func(c *Check) { c.Amount = MaxCheckAmount + 1 },
+			expectedField: "Amount",
+		},
+		{
+			name:          "no payee",
+			modify:        func(c *Check) { c.Payee = "" },
+			expectedField: "CheckName",
+		},
+		{
+			name:          "no number",
+			modify:        func(c *Check) { c.Number = 0 },
+			expectedField: "CheckNumber",
+		},
+		{
+			name:          "long memo",
+			modify:        func(c *Check) { c.Memo = strings.Repeat("é", MaxCheckMemoLength+1) },
+			expectedField: "CheckMemo",
+		},
+		{
+			name:   "longest memo",
+			modify: func(c *Check) { c.Memo = strings.Repeat("é", MaxCheckMemoLength) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := testCheck()
+			test.modify(&check)
+			err := check.Validate()
+			if test.expectedField == "" {
+				assert.NoError(t, err)
+				return
+			}
+			invalidErr := &ErrOrderInvalid{}
+			assert.True(t, errors.As(err, &invalidErr))
+			assert.Equal(t, test.expectedField, invalidErr.Field())
+		})
+	}
+}
+
+func TestOrderInputCheck(t *testing.T) {
+	input := testOrderInput()
+	_, ok := input.Check()
+	assert.False(t, ok)
+	assert.NoError(t, input.Validate())
+
+	assert.NoError(t, input.SetCheck(testCheck()))
+	check, ok := input.Check()
+	assert.True(t, ok)
+	assert.Equal(t, testCheck(), check)
+	assert.Equal(t, 123456, input.Amount)
+	assert.Equal(t, "Jane Doe", input.CheckName)
+	assert.NoError(t, input.Validate())
+
+	// Invalid checks aren't set
+	err := input.SetCheck(Check{BankAccount: "acct_2"})
+	assert.Error(t, err)
+	assert.Equal(t, "acct_1", input.BankAccount)
+
+	// Partial checks are invalid
+	input = testOrderInput()
+	input.BankAccount = "acct_1"
+	err = input.Validate()
+	invalidErr := &ErrOrderInvalid{}
+	assert.True(t, errors.As(err, &invalidErr))
+	assert.Equal(t, "Amount", invalidErr.Field())
+
+	input = testOrderInput()
+	input.CheckMemo = "memo"
+	assert.True(t, errors.As(input.Validate(), &invalidErr))
+	assert.Equal(t, "BankAccount", invalidErr.Field())
+}
+
+func TestCheckNumbers(t *testing.T) {
+	numbers := NewCheckNumbers()
+	check := testCheck()
+
+	assert.NoError(t, numbers.Reserve(check))
+	assert.True(t, numbers.Used("acct_1", 1001))
+	assert.ErrorIs(t, numbers.Reserve(check), ErrCheckNumberUsed)
+
+	// Numbers are per bank account
+	other := testCheck()
+	other.BankAccount = "acct_2"
+	assert.NoError(t, numbers.Reserve(other))
+
+	numbers.Release(check)
+	assert.False(t, numbers.Used("acct_1", 1001))
+	assert.NoError(t, numbers.Reserve(check))
+}
+
+func TestValidateChecks(t *testing.T) {
+	second := testCheck()
+	second.Number = 1002
+	otherAccount := testCheck()
+	otherAccount.BankAccount = "acct_2"
+	assert.NoError(t, ValidateChecks([]Check{testCheck(), second, otherAccount}))
+
+	assert.ErrorIs(t, ValidateChecks([]Check{testCheck(), second, testCheck()}), ErrCheckNumberUsed)
+
+	invalid := testCheck()
+	invalid.Payee = ""
+	invalidErr := &ErrOrderInvalid{}
+	assert.True(t, errors.As(ValidateChecks([]Check{testCheck(), invalid}), &invalidErr))
+}
+
+func TestCreateOrderCheckNumbers(t *testing.T) {
+	mailformClient, err := New(&Config{
+		Token:        "someToken",
+		CheckNumbers: NewCheckNumbers(),
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", DefaultBaseURL+ordersEndpoint,
+		jsonResponder(http.StatusInternalServerError, `{"error": {"code": "internal", "message": "try again"}}`))
+
+	input := testOrderInput()
+	assert.NoError(t, input.SetCheck(testCheck()))
+
+	// Failed orders give their check number back
+	_, err = mailformClient.CreateOrder(input)
+	assert.Error(t, err)
+	assert.False(t, mailformClient.checkNumbers.Used("acct_1", 1001))
+
+	httpmock.RegisterResponder("POST", DefaultBaseURL+ordersEndpoint,
+		jsonResponder(http.StatusOK, `{"success": true, "data": {"id": "some_id"}}`))
+	_, err = mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+
+	_, err = mailformClient.CreateOrder(input)
+	assert.ErrorIs(t, err, ErrCheckNumberUsed)
+	assert.Equal(t, 2, httpmock.GetTotalCallCount())
+}
@@ -0,0 +1,431 @@
+package mailform
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// CheckNumberReused is a check number used on more than one order
+	CheckNumberReused = "reused"
+	// CheckNumberSkipped is a run of check numbers between the first and last check of a bank account that were never used
+	CheckNumberSkipped = "skipped"
+
+	// positivePayAccountWidth is the width of the bank account column of a positive pay file
+	positivePayAccountWidth = 20
+	// positivePayNumberWidth is the width of the check number column of a positive pay file
+	positivePayNumberWidth = 10
+	// positivePayAmountWidth is the width of the amount column of a positive pay file
+	positivePayAmountWidth = 12
+	// positivePayPayeeWidth is the width of the payee column of a positive pay file
+	positivePayPayeeWidth = 49
+)
+
+var (
+	// ErrCheckEntryNotFound is returned when an order isn't in the check ledger.
+	ErrCheckEntryNotFound = errors.New("check ledger entry not found")
+	// ErrNoCheck is returned when recording an order input without a check in the check ledger.
+	ErrNoCheck = errors.New("order input does not include a check")
+
+	// positivePayLetters are the accented capital letters and typographic punctuation common in payee names
+	// and their ASCII spellings, since positive pay files are fixed width ASCII
+	positivePayLetters = strings.NewReplacer(
+		"À", "A", "Á", "A", "Â", "A", "Ã", "A", "Ä", "A", "Å", "A", "Ą", "A", "Æ", "AE",
+		"Ç", "C", "Ć", "C", "Č", "C", "Ð", "D", "Ď", "D",
+		"È", "E", "É", "E", "Ê", "E", "Ë", "E", "Ę", "E", "Ě", "E", "Ğ", "G",
+		"Ì", "I", "Í", "I", "Î", "I", "Ï", "I", "İ", "I", "Ł", "L",
+		"Ñ", "N", "Ń", "N", "Ň", "N", "Ò", "O", "Ó", "O", "Ô", "O", "Õ", "O", "Ö", "O", "Ø", "O", "Ő", "O", "Œ", "OE",
+		"Ř", "R", "Ś", "S", "Š", "S", "Ş", "S", "ß", "SS", "Ť", "T", "Þ", "TH",
+		"Ù", "U", "Ú", "U", "Û", "U", "Ü", "U", "Ů", "U", "Ű", "U", "Ý", "Y", "Ÿ", "Y", "Ź", "Z", "Ż", "Z", "Ž", "Z",
+		"‘", "'", "’", "'", "“", "\"", "”", "\"", "–", "-", "—", "-",
+	)
+)
+
+// CheckEntry is a check that was mailed with an order.
+type CheckEntry struct {
+	Check
+	OrderID string `json:"order_id"`
+	// State is the state of the order, as of Updated
+	State string `json:"state"`
+	// Issued is when the order was created
+	Issued  time.Time `json:"issued"`
+	Updated time.Time `json:"updated"`
+}
+
+// Void checks if the check's order was cancelled, so the check should never be presented.
+func (e CheckEntry) Void() bool {
+	return e.State == StatusCancelled
+}
+
+// CheckNumberIssue is a problem with the check numbers used on a bank account.
+type CheckNumberIssue struct {
+	BankAccount string `json:"bank_account"`
+	// Kind is CheckNumberReused or CheckNumberSkipped
+	Kind string `json:"kind"`
+	// Number is the reused check number, or the first of the skipped check numbers
+	Number int `json:"number"`
+	// Through is the last of the skipped check numbers, the same as Number for reused check numbers
+	Through int `json:"through"`
+	// OrderIDs are the orders that used a reused check number
+	OrderIDs []string `json:"order_ids,omitempty"`
+}
+
+func (i CheckNumberIssue) String() string {
+	switch {
+	case i.Kind == CheckNumberReused:
+		return fmt.Sprintf("check number %d reused on bank account %s by orders %s", i.Number, i.BankAccount, strings.Join(i.OrderIDs, ", "))
+	case i.Number == i.Through:
+		return fmt.Sprintf("check number %d skipped on bank account %s", i.Number, i.BankAccount)
+	default:
+		return fmt.Sprintf("check numbers %d to %d skipped on bank account %s", i.Number, i.Through, i.BankAccount)
+	}
+}
+
+// CheckLedger is a register of every check mailed with an order.
+// The client records orders created with a check when Config.CheckLedger is set.
+// Entries are kept in memory and persisted to a local JSON file after every change.
+type CheckLedger struct {
+	path  string
+	clock Clock
+
+	mu      sync.Mutex
+	entries map[string]*CheckEntry
+}
+
+// NewCheckLedger returns a check ledger that persists to the file at path, loading any entries that already exist.
+// If clock is nil, the system clock is used.
+func NewCheckLedger(path string, clock Clock) (*CheckLedger, error) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	l := &CheckLedger{
+		path:    path,
+		clock:   clock,
+		entries: map[string]*CheckEntry{},
+	}
+
+	entries := []*CheckEntry{}
+	err := readJSONFile(path, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		l.entries[entry.OrderID] = entry
+	}
+
+	return l, nil
+}
+
+// save persists all entries. Callers must hold the lock.
+func (l *CheckLedger) save() error {
+	return writeJSONFile(l.path, l.sorted())
+}
+
+// sorted returns copies of the entries sorted by bank account, check number then when they were issued.
+// Callers must hold the lock.
+func (l *CheckLedger) sorted() []CheckEntry {
+	entries := make([]CheckEntry, 0, len(l.entries))
+	for _, entry := range l.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].BankAccount != entries[j].BankAccount {
+			return entries[i].BankAccount < entries[j].BankAccount
+		}
+		if entries[i].Number != entries[j].Number {
+			return entries[i].Number < entries[j].Number
+		}
+		if !entries[i].Issued.Equal(entries[j].Issued) {
+			return entries[i].Issued.Before(entries[j].Issued)
+		}
+		return entries[i].OrderID < entries[j].OrderID
+	})
+
+	return entries
+}
+
+// Record adds the check of an order input to the ledger with the order created for it.
+// The client calls this for every order it creates with a check, so it only needs calling directly for orders created elsewhere.
+// Recording the same order again replaces its entry.
+func (l *CheckLedger) Record(o OrderInput, order *Order) (CheckEntry, error) {
+	check, ok := o.Check()
+	if !ok {
+		return CheckEntry{}, ErrNoCheck
+	}
+
+	now := l.clock.Now()
+	entry := CheckEntry{
+		Check:   check,
+		OrderID: order.Data.ID,
+		State:   order.Data.State,
+		Issued:  order.Data.Created,
+		Updated: now,
+	}
+	if entry.Issued.IsZero() {
+		entry.Issued = now
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	existing, ok := l.entries[entry.OrderID]
+	l.entries[entry.OrderID] = &entry
+	err := l.save()
+	if err != nil {
+		// Keep memory consistent with what's on disk
+		if ok {
+			l.entries[entry.OrderID] = existing
+		} else {
+			delete(l.entries, entry.OrderID)
+		}
+		return CheckEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// Get returns the entry of the order with the ID.
+func (l *CheckLedger) Get(orderID string) (CheckEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[orderID]
+	if !ok {
+		return CheckEntry{}, ErrCheckEntryNotFound
+	}
+
+	return *entry, nil
+}
+
+// Entries returns every entry sorted by bank account then check number.
+func (l *CheckLedger) Entries() []CheckEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.sorted()
+}
+
+// Refresh updates the state of every entry whose order isn't fulfilled or cancelled yet via GetOrder.
+// Entries updated before an error are still saved.
+func (l *CheckLedger) Refresh(ctx context.Context, c *Client) error {
+	l.mu.Lock()
+	pending := []string{}
+	for id, entry := range l.entries {
+		if entry.State != StatusFulfilled && entry.State != StatusCancelled {
+			pending = append(pending, id)
+		}
+	}
+	l.mu.Unlock()
+	sort.Strings(pending)
+
+	// Don't hold the lock while waiting on mailform
+	states := map[string]string{}
+	var err error
+	for _, id := range pending {
+		var order *Order
+		order, err = c.GetOrderWithContext(ctx, id)
+		if err != nil {
+			err = fmt.Errorf("could not refresh order %s: %w", id, err)
+			break
+		}
+		states[id] = order.Data.State
+	}
+	if len(states) == 0 {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	previous := map[string]CheckEntry{}
+	now := l.clock.Now()
+	for id, state := range states {
+		entry, ok := l.entries[id]
+		if !ok {
+			continue
+		}
+		previous[id] = *entry
+		entry.State = state
+		entry.Updated = now
+	}
+
+	saveErr := l.save()
+	if saveErr != nil {
+		for id, entry := range previous {
+			*l.entries[id] = entry
+		}
+		return saveErr
+	}
+
+	return err
+}
+
+// Issues finds check numbers used on more than one order, and runs of check numbers that were skipped between
+// the lowest and highest check number of each bank account. Issues are sorted by bank account then check number.
+func (l *CheckLedger) Issues() []CheckNumberIssue {
+	entries := l.Entries()
+
+	issues := []CheckNumberIssue{}
+	for start := 0; start < len(entries); {
+		// Entries are sorted, so each bank account is a run of entries
+		end := start
+		for end < len(entries) && entries[end].BankAccount == entries[start].BankAccount {
+			end++
+		}
+		issues = append(issues, checkNumberIssues(entries[start:end])...)
+		start = end
+	}
+
+	return issues
+}
+
+// checkNumberIssues finds the issues with the entries of one bank account, sorted by check number.
+func checkNumberIssues(entries []CheckEntry) []CheckNumberIssue {
+	issues := []CheckNumberIssue{}
+	for i := 0; i < len(entries); {
+		number := entries[i].Number
+		orderIDs := []string{}
+		for i < len(entries) && entries[i].Number == number {
+			orderIDs = append(orderIDs, entries[i].OrderID)
+			i++
+		}
+
+		if len(orderIDs) > 1 {
+			issues = append(issues, CheckNumberIssue{
+				BankAccount: entries[0].BankAccount,
+				Kind:        CheckNumberReused,
+				Number:      number,
+				Through:     number,
+				OrderIDs:    orderIDs,
+			})
+		}
+
+		if i < len(entries) && entries[i].Number > number+1 {
+			issues = append(issues, CheckNumberIssue{
+				BankAccount: entries[0].BankAccount,
+				Kind:        CheckNumberSkipped,
+				Number:      number + 1,
+				Through:     entries[i].Number - 1,
+			})
+		}
+	}
+
+	return issues
+}
+
+// CheckNumbers returns the check numbers used in the ledger, so they can't be used again by the client.
+func (l *CheckLedger) CheckNumbers() *CheckNumbers {
+	numbers := NewCheckNumbers()
+	for _, entry := range l.Entries() {
+		// Reused numbers are already in the ledger, so only the first reservation matters
+		_ = numbers.Reserve(entry.Check)
+	}
+
+	return numbers
+}
+
+// ExportCSV writes every entry as CSV with a header row, sorted by bank account then check number.
+// Amounts are in dollars, and times are RFC 3339.
+func (l *CheckLedger) ExportCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"bank_account", "check_number", "payee", "amount", "memo", "order_id", "state", "issued", "updated"})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range l.Entries() {
+		err := writer.Write([]string{
+			entry.BankAccount,
+			strconv.Itoa(entry.Number),
+			entry.Payee,
+			fmt.Sprintf("%d.%02d", entry.Amount/100, entry.Amount%100),
+			entry.Memo,
+			entry.OrderID,
+			entry.State,
+			entry.Issued.Format(time.RFC3339),
+			entry.Updated.Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportPositivePay writes every entry as a fixed width positive pay file for upload to the bank, one 100 character
+// line per check sorted by bank account then check number:
+//
+//	Columns  Field         Format
+//	1-20     Bank account  Left aligned, space padded
+//	21-30    Check number  Zero padded
+//	31-42    Amount        Cents, zero padded
+//	43-50    Issue date    YYYYMMDD
+//	51       Void          V if the order was cancelled, otherwise a space
+//	52-100   Payee         Left aligned, space padded, truncated
+//
+// Payees are written in ASCII, with accented letters spelled without their accents.
+// Bank accounts longer than 20 characters or that aren't ASCII are an error since changing them would send checks
+// to the wrong account, as are check numbers and amounts too long for their columns.
+func (l *CheckLedger) ExportPositivePay(w io.Writer) error {
+	for _, entry := range l.Entries() {
+		if len(entry.BankAccount) > positivePayAccountWidth {
+			return fmt.Errorf("bank account %s is longer than %d characters", entry.BankAccount, positivePayAccountWidth)
+		}
+		if positivePayText(entry.BankAccount) != entry.BankAccount {
+			return fmt.Errorf("bank account %s has characters that aren't printable ASCII", entry.BankAccount)
+		}
+		if len(strconv.Itoa(entry.Number)) > positivePayNumberWidth {
+			return fmt.Errorf("check number %d on bank account %s is longer than %d digits", entry.Number, entry.BankAccount, positivePayNumberWidth)
+		}
+		if len(strconv.Itoa(int(entry.Amount))) > positivePayAmountWidth {
+			return fmt.Errorf("check number %d on bank account %s has an amount longer than %d digits", entry.Number, entry.BankAccount, positivePayAmountWidth)
+		}
+
+		void := " "
+		if entry.Void() {
+			void = "V"
+		}
+
+		payee := positivePayText(strings.ToUpper(strings.TrimSpace(entry.Payee)))
+		if len(payee) > positivePayPayeeWidth {
+			payee = payee[:positivePayPayeeWidth]
+		}
+
+		_, err := fmt.Fprintf(w, "%-*s%0*d%0*d%s%s%-*s\n", positivePayAccountWidth, entry.BankAccount,
+			positivePayNumberWidth, entry.Number, positivePayAmountWidth, int(entry.Amount), entry.Issued.Format("20060102"),
+			void, positivePayPayeeWidth, payee)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// positivePayText spells accented letters in ASCII, turns whitespace into spaces and drops anything else that isn't
+// printable ASCII, so every character is a single byte.
+func positivePayText(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= ' ' && r <= '~':
+			return r
+		case unicode.IsSpace(r):
+			return ' '
+		default:
+			return -1
+		}
+	}, positivePayLetters.Replace(s))
+}
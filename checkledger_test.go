@@ -0,0 +1,255 @@
+package mailform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCheckOrder returns an order input with a check and the order created for it.
+func testCheckOrder(t *testing.T, bankAccount string, number int, orderID string) (OrderInput, *Order) {
+	input := testOrderInput()
+	check := testCheck()
+	check.BankAccount = bankAccount
+	check.Number = number
+	assert.NoError(t, input.SetCheck(check))
+
+	order := &Order{}
+	order.Data.ID = orderID
+	order.Data.State = StatusQueued
+	order.Data.Created = time.Date(2022, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	return input, order
+}
+
+func TestCheckLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checks.json")
+	clock := &fakeClock{now: time.Date(2022, 3, 4, 13, 0, 0, 0, time.UTC)}
+
+	ledger, err := NewCheckLedger(path, clock)
+	assert.NoError(t, err)
+
+	for _, check := range []struct {
+		bankAccount string
+		number      int
+		orderID     string
+	}{
+		{bankAccount: "acct_1", number: 1001, orderID: "order_1"},
+		{bankAccount: "acct_1", number: 1002, orderID: "order_2"},
+		{bankAccount: "acct_1", number: 1002, orderID: "order_3"},
+		{bankAccount: "acct_1", number: 1006, orderID: "order_4"},
+		{bankAccount: "acct_1", number: 1008, orderID: "order_5"},
+		{bankAccount: "acct_2", number: 1, orderID: "order_6"},
+	} {
+		input, order := testCheckOrder(t, check.bankAccount, check.number, check.orderID)
+		_, err := ledger.Record(input, order)
+		assert.NoError(t, err)
+	}
+
+	_, err = ledger.Record(testOrderInput(), &Order{})
+	assert.ErrorIs(t, err, ErrNoCheck)
+
+	entry, err := ledger.Get("order_3")
+	assert.NoError(t, err)
+	assert.Equal(t, "acct_1", entry.BankAccount)
+	assert.Equal(t, 1002, entry.Number)
+	assert.Equal(t, Cents(123456), entry.Amount)
+	assert.Equal(t, "Jane Doe", entry.Payee)
+	assert.Equal(t, StatusQueued, entry.State)
+	assert.Equal(t, time.Date(2022, 3, 4, 12, 0, 0, 0, time.UTC), entry.Issued)
+	assert.Equal(t, clock.now, entry.Updated)
+
+	_, err = ledger.Get("missing")
+	assert.ErrorIs(t, err, ErrCheckEntryNotFound)
+
+	issues := ledger.Issues()
+	assert.Equal(t, []CheckNumberIssue{
+		{BankAccount: "acct_1", Kind: CheckNumberReused, Number: 1002, Through: 1002, OrderIDs: []string{"order_2", "order_3"}},
+		{BankAccount: "acct_1", Kind: CheckNumberSkipped, Number: 1003, Through: 1005},
+		{BankAccount: "acct_1", Kind: CheckNumberSkipped, Number: 1007, Through: 1007},
+	}, issues)
+	assert.Equal(t, "check number 1002 reused on bank account acct_1 by orders order_2, order_3", issues[0].String())
+	assert.Equal(t, "check numbers 1003 to 1005 skipped on bank account acct_1", issues[1].String())
+	assert.Equal(t, "check number 1007 skipped on bank account acct_1", issues[2].String())
+
+	numbers := ledger.CheckNumbers()
+	assert.True(t, numbers.Used("acct_1", 1008))
+	assert.False(t, numbers.Used("acct_1", 1007))
+
+	// Entries are persisted
+	reloaded, err := NewCheckLedger(path, clock)
+	assert.NoError(t, err)
+	assert.Equal(t, ledger.Entries(), reloaded.Entries())
+}
+
+func TestCheckLedgerRefresh(t *testing.T) {
+	mailformClient, err := New(&Config{Token: "someToken"})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", DefaultBaseURL+ordersEndpoint+"/order_1",
+		jsonResponder(http.StatusOK, `{"success": true, "data": {"id": "order_1", "state": "fulfilled"}}`))
+	httpmock.RegisterResponder("GET", DefaultBaseURL+ordersEndpoint+"/order_2",
+		jsonResponder(http.StatusOK, `{"success": true, "data": {"id": "order_2", "state": "cancelled"}}`))
+
+	clock := &fakeClock{now: time.Date(2022, 3, 4, 13, 0, 0, 0, time.UTC)}
+	ledger, err := NewCheckLedger(filepath.Join(t.TempDir(), "checks.json"), clock)
+	assert.NoError(t, err)
+	for i, id := range []string{"order_1", "order_2"} {
+		input, order := testCheckOrder(t, "acct_1", 1001+i, id)
+		_, err := ledger.Record(input, order)
+		assert.NoError(t, err)
+	}
+
+	clock.Add(time.Hour)
+	assert.NoError(t, ledger.Refresh(context.Background(), mailformClient))
+	entries := ledger.Entries()
+	assert.Equal(t, StatusFulfilled, entries[0].State)
+	assert.Equal(t, clock.now, entries[0].Updated)
+	assert.False(t, entries[0].Void())
+	assert.Equal(t, StatusCancelled, entries[1].State)
+	assert.True(t, entries[1].Void())
+
+	// Finished orders aren't fetched again
+	assert.NoError(t, ledger.Refresh(context.Background(), mailformClient))
+	assert.Equal(t, 2, httpmock.GetTotalCallCount())
+
+	// Errors still save what was refreshed
+	input, order := testCheckOrder(t, "acct_1", 1003, "order_3")
+	_, err = ledger.Record(input, order)
+	assert.NoError(t, err)
+	httpmock.RegisterResponder("GET", DefaultBaseURL+ordersEndpoint+"/order_3",
+		jsonResponder(http.StatusNotFound, `{"error": {"code": "not_found", "message": "order not found"}}`))
+	err = ledger.Refresh(context.Background(), mailformClient)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "order_3")
+}
+
+func TestCheckLedgerExport(t *testing.T) {
+	ledger, err := NewCheckLedger(filepath.Join(t.TempDir(), "checks.json"), &fakeClock{now: time.Date(2022, 3, 4, 13, 0, 0, 0, time.UTC)})
+	assert.NoError(t, err)
+
+	input, order := testCheckOrder(t, "acct_1", 1001, "order_1")
+	_, err = ledger.Record(input, order)
+	assert.NoError(t, err)
+	input, order = testCheckOrder(t, "acct_1", 1002, "order_2")
+	input.CheckName = strings.Repeat("Jane Doe ", 10)
+	order.Data.State = StatusCancelled
+	_, err = ledger.Record(input, order)
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ledger.ExportCSV(buf))
+	assert.Equal(t, "bank_account,check_number,payee,amount,memo,order_id,state,issued,updated\n"+
+		"acct_1,1001,Jane Doe,1234.56,Invoice 42,order_1,queued,2022-03-04T12:00:00Z,2022-03-04T13:00:00Z\n"+
+		"acct_1,1002,"+strings.Repeat("Jane Doe ", 10)+",1234.56,Invoice 42,order_2,cancelled,2022-03-04T12:00:00Z,2022-03-04T13:00:00Z\n",
+		buf.String())
+
+	buf.Reset()
+	assert.NoError(t, ledger.ExportPositivePay(buf))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "acct_1              000000100100000012345620220304 JANE DOE"+strings.Repeat(" ", 41), lines[0])
+	assert.Len(t, lines[1], 100)
+	assert.Equal(t, "V", lines[1][50:51])
+	assert.Equal(t, strings.Repeat("JANE DOE ", 10)[:49], lines[1][51:])
+
+	input, order = testCheckOrder(t, strings.Repeat("a", 21), 1, "order_3")
+	_, err = ledger.Record(input, order)
+	assert.NoError(t, err)
+	assert.Error(t, ledger.ExportPositivePay(&bytes.Buffer{}))
+}
+
+func TestCheckLedgerExportPositivePayASCII(t *testing.T) {
+	ledger, err := NewCheckLedger(filepath.Join(t.TempDir(), "checks.json"), &fakeClock{now: time.Date(2022, 3, 4, 13, 0, 0, 0, time.UTC)})
+	assert.NoError(t, err)
+
+	for i, payee := range []string{"José Müller-Łukasz", "Zoë O’Brien\tCafé 東京", strings.Repeat("é", 60), "Straße"} {
+		input, order := testCheckOrder(t, "acct_1", 1001+i, fmt.Sprintf("order_%d", i))
+		input.CheckName = payee
+		_, err = ledger.Record(input, order)
+		assert.NoError(t, err)
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ledger.ExportPositivePay(buf))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 4)
+	for _, line := range lines {
+		assert.Len(t, line, 100)
+	}
+	assert.Equal(t, "JOSE MULLER-LUKASZ", strings.TrimSpace(lines[0][51:]))
+	assert.Equal(t, "ZOE O'BRIEN CAFE", strings.TrimSpace(lines[1][51:]))
+	assert.Equal(t, strings.Repeat("E", 49), lines[2][51:])
+	assert.Equal(t, "STRASSE", strings.TrimSpace(lines[3][51:]))
+
+	// Columns that would overflow are an error rather than shifting the rest of the line
+	wide, err := NewCheckLedger(filepath.Join(t.TempDir(), "checks.json"), nil)
+	assert.NoError(t, err)
+	input, order := testCheckOrder(t, "acct_1", 12345678901, "order_wide")
+	_, err = wide.Record(input, order)
+	assert.NoError(t, err)
+	assert.EqualError(t, wide.ExportPositivePay(&bytes.Buffer{}), "check number 12345678901 on bank account acct_1 is longer than 10 digits")
+
+	wide, err = NewCheckLedger(filepath.Join(t.TempDir(), "checks.json"), nil)
+	assert.NoError(t, err)
+	input, order = testCheckOrder(t, "acct_1", 1, "order_amount")
+	input.Amount = 1234567890123
+	_, err = wide.Record(input, order)
+	assert.NoError(t, err)
+	assert.EqualError(t, wide.ExportPositivePay(&bytes.Buffer{}), "check number 1 on bank account acct_1 has an amount longer than 12 digits")
+
+	wide, err = NewCheckLedger(filepath.Join(t.TempDir(), "checks.json"), nil)
+	assert.NoError(t, err)
+	input, order = testCheckOrder(t, "acct_1", 1234567890, "order_max")
+	_, err = wide.Record(input, order)
+	assert.NoError(t, err)
+	buf.Reset()
+	assert.NoError(t, wide.ExportPositivePay(buf))
+	assert.Len(t, strings.TrimSuffix(buf.String(), "\n"), 100)
+
+	input, order = testCheckOrder(t, "äcct_1", 1, "order_5")
+	_, err = ledger.Record(input, order)
+	assert.NoError(t, err)
+	assert.EqualError(t, ledger.ExportPositivePay(&bytes.Buffer{}), "bank account äcct_1 has characters that aren't printable ASCII")
+}
+
+func TestCreateOrderCheckLedger(t *testing.T) {
+	ledger, err := NewCheckLedger(filepath.Join(t.TempDir(), "checks.json"), nil)
+	assert.NoError(t, err)
+
+	mailformClient, err := New(&Config{
+		Token:       "someToken",
+		CheckLedger: ledger,
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", DefaultBaseURL+ordersEndpoint,
+		jsonResponder(http.StatusOK, `{"success": true, "data": {"id": "some_id", "state": "queued"}}`))
+
+	// Orders without a check aren't recorded
+	_, err = mailformClient.CreateOrder(testOrderInput())
+	assert.NoError(t, err)
+	assert.Empty(t, ledger.Entries())
+
+	input := testOrderInput()
+	assert.NoError(t, input.SetCheck(testCheck()))
+	_, err = mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+
+	entry, err := ledger.Get("some_id")
+	assert.NoError(t, err)
+	assert.Equal(t, testCheck(), entry.Check)
+	assert.Equal(t, StatusQueued, entry.State)
+}
@@ -0,0 +1,17 @@
+package mailform
+
+import "time"
+
+// Clock tells the time.
+// Features that depend on the time accept a Clock so they can be tested deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock that uses the system time.
+type SystemClock struct{}
+
+// Now returns the current system time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
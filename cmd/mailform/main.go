@@ -0,0 +1,573 @@
+// Command mailform sends and inspects physical mail from the command line using https://mailform.io
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/circa10a/go-mailform"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// tokenEnv is the environment variable the API token is read from
+	tokenEnv = "MAILFORM_API_TOKEN"
+	// configEnv is the environment variable that overrides the config file path
+	configEnv = "MAILFORM_CONFIG"
+	// Output formats
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var (
+	// errUsage is returned when the command line is invalid, the usage has already been printed
+	errUsage = errors.New("invalid usage")
+)
+
+// config is the CLI config file.
+type config struct {
+	Token   string `yaml:"token"`
+	BaseURL string `yaml:"base_url"`
+}
+
+// cli holds the shared state of a command line invocation.
+type cli struct {
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+func main() {
+	c := &cli{
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		getenv: os.Getenv,
+	}
+
+	os.Exit(c.run(os.Args[1:]))
+}
+
+// run runs the command line and returns the exit code.
+func (c *cli) run(args []string) int {
+	if len(args) == 0 {
+		c.usage()
+		return 2
+	}
+
+	commands := map[string]func([]string) error{
+		"send":     c.send,
+		"get":      c.get,
+		"wait":     c.wait,
+		"cancel":   c.cancel,
+		"services": c.services,
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		c.usage()
+		return 2
+	}
+
+	err := command(args[1:])
+	if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+		return 2
+	}
+	if err != nil {
+		for _, d := range mailform.ErrorDiagnostics(err) {
+			if d.AttributePath != "" {
+				fmt.Fprintf(c.stderr, "Error: %s: %s (%s)\n", d.Summary, d.Detail, d.AttributePath)
+				continue
+			}
+			fmt.Fprintf(c.stderr, "Error: %s: %s\n", d.Summary, d.Detail)
+		}
+		return 1
+	}
+
+	return 0
+}
+
+// usage prints the top level usage.
+func (c *cli) usage() {
+	fmt.Fprint(c.stderr, `Usage: mailform <command> [flags]
+
+Commands:
+  send        Send a letter or postcard
+  get <id>    Get an order
+  wait <id>   Wait for an order to be fulfilled or cancelled
+  cancel <id> Cancel an order
+  services    List supported services
+
+The API token is read from the `+tokenEnv+` environment variable or the token key of the config file.
+Run mailform <command> -h for the flags of each command.
+`)
+}
+
+// flagSet returns a flag set with the flags shared by every command.
+func (c *cli) flagSet(name string) (*flag.FlagSet, *string, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	output := fs.String("o", outputTable, "output format, table or json")
+	configPath := fs.String("config", "", "config file path (default $"+configEnv+" or <user config dir>/mailform/config.yaml)")
+
+	return fs, output, configPath
+}
+
+// loadConfig reads the config file and applies the token from the environment over it.
+func (c *cli) loadConfig(path string) (*config, error) {
+	cfg := &config{}
+
+	explicit := true
+	if path == "" {
+		path = c.getenv(configEnv)
+	}
+	if path == "" {
+		explicit = false
+		dir, err := os.UserConfigDir()
+		if err == nil {
+			path = filepath.Join(dir, "mailform", "config.yaml")
+		}
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		// The default config file is optional
+		if err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+			return nil, err
+		}
+		if err == nil {
+			err = yaml.Unmarshal(data, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("config file %s: %w", path, err)
+			}
+		}
+	}
+
+	if token := c.getenv(tokenEnv); token != "" {
+		cfg.Token = token
+	}
+
+	return cfg, nil
+}
+
+// client returns a mailform client from the config file and environment.
+func (c *cli) client(configPath string, dryRun bool) (*mailform.Client, error) {
+	cfg, err := c.loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Token == "" && !dryRun {
+		return nil, fmt.Errorf("no API token, set %s or token in the config file", tokenEnv)
+	}
+
+	return mailform.New(&mailform.Config{
+		Token:   cfg.Token,
+		BaseURL: cfg.BaseURL,
+		DryRun:  dryRun,
+	})
+}
+
+// checkOutput checks the output format is supported.
+func (c *cli) checkOutput(fs *flag.FlagSet, output string) error {
+	if output != outputTable && output != outputJSON {
+		fmt.Fprintf(c.stderr, "unsupported output format '%s'\n", output)
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// parseArgs parses flags given before or after positional arguments, e.g. get <id> -o json,
+// and returns the positional arguments. Everything after -- is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed := len(args) - fs.NArg()
+		if parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, fs.Args()...), nil
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// noArgs parses the flags of a command that doesn't take positional arguments.
+// Stray arguments are an error rather than silently ending flag parsing.
+func (c *cli) noArgs(fs *flag.FlagSet, args []string) error {
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return errUsage
+	}
+
+	if len(positional) > 0 {
+		fmt.Fprintf(c.stderr, "%s takes no arguments, got %s\n", fs.Name(), strings.Join(positional, " "))
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// orderID parses the flags and single order ID argument of a command.
+func (c *cli) orderID(fs *flag.FlagSet, args []string) (string, error) {
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return "", errUsage
+	}
+
+	if len(positional) != 1 {
+		fmt.Fprintf(c.stderr, "%s requires exactly one order ID\n", fs.Name())
+		fs.Usage()
+		return "", errUsage
+	}
+
+	return positional[0], nil
+}
+
+// formValue is a flag that sets an order input field by its form data key.
+type formValue struct {
+	key string
+	// set records the flags that were given so they can be applied over the input file
+	set    map[string]string
+	isBool bool
+}
+
+func (f *formValue) String() string {
+	return ""
+}
+
+func (f *formValue) Set(v string) error {
+	f.set[f.key] = v
+	return nil
+}
+
+// IsBoolFlag lets boolean fields be given without a value, e.g. --color.
+func (f *formValue) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// send sends an order.
+func (c *cli) send(args []string) error {
+	fs, output, configPath := c.flagSet("send")
+	inputFile := fs.String("f", "", "YAML or JSON file of order fields keyed the same as the flags below, e.g. to.name")
+	dryRun := fs.Bool("dry-run", false, "validate and print the order without sending it")
+
+	input := &mailform.OrderInput{}
+	set := map[string]string{}
+	for _, key := range mailform.FormKeys {
+		value := &formValue{
+			key: key,
+			set: set,
+		}
+		switch key {
+		case "simplex", "color", "flat", "stamp":
+			value.isBool = true
+		}
+		fs.Var(value, key, fmt.Sprintf("order %s", key))
+	}
+
+	err := c.noArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	err = c.checkOutput(fs, *output)
+	if err != nil {
+		return err
+	}
+
+	if *inputFile != "" {
+		err = readOrderInput(*inputFile, input)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Flags take precedence over the input file
+	for key, value := range set {
+		err = input.SetFormValue(key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	client, err := c.client(*configPath, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	order, err := client.CreateOrder(*input)
+	if err != nil {
+		return err
+	}
+
+	return c.printOrder(*output, order)
+}
+
+// readOrderInput reads order fields keyed by form data key from a YAML or JSON file.
+func readOrderInput(path string, input *mailform.OrderInput) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// JSON is valid YAML so this handles both.
+	// Values are read as written so ZIP codes like 02134 aren't decoded as numbers.
+	fields := map[string]yaml.Node{}
+	err = yaml.Unmarshal(data, &fields)
+	if err != nil {
+		return fmt.Errorf("order file %s: %w", path, err)
+	}
+
+	for key, node := range fields {
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("order file %s: %s must be a single value", path, key)
+		}
+		value := node.Value
+		if node.Tag == "!!null" {
+			value = ""
+		}
+		err = input.SetFormValue(key, value)
+		if err != nil {
+			return fmt.Errorf("order file %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// get gets an order.
+func (c *cli) get(args []string) error {
+	fs, output, configPath := c.flagSet("get")
+
+	id, err := c.orderID(fs, args)
+	if err != nil {
+		return err
+	}
+	err = c.checkOutput(fs, *output)
+	if err != nil {
+		return err
+	}
+
+	client, err := c.client(*configPath, false)
+	if err != nil {
+		return err
+	}
+
+	order, err := client.GetOrder(id)
+	if err != nil {
+		return err
+	}
+
+	return c.printOrder(*output, order)
+}
+
+// cancel cancels an order.
+func (c *cli) cancel(args []string) error {
+	fs, output, configPath := c.flagSet("cancel")
+
+	id, err := c.orderID(fs, args)
+	if err != nil {
+		return err
+	}
+	err = c.checkOutput(fs, *output)
+	if err != nil {
+		return err
+	}
+
+	client, err := c.client(*configPath, false)
+	if err != nil {
+		return err
+	}
+
+	order, err := client.CancelOrder(id)
+	if err != nil {
+		return err
+	}
+
+	return c.printOrder(*output, order)
+}
+
+// wait polls an order until it is fulfilled or cancelled.
+func (c *cli) wait(args []string) error {
+	fs, output, configPath := c.flagSet("wait")
+	interval := fs.Duration("interval", time.Minute, "how often to check the order")
+	timeout := fs.Duration("timeout", 0, "give up after this long, 0 waits forever")
+
+	id, err := c.orderID(fs, args)
+	if err != nil {
+		return err
+	}
+	err = c.checkOutput(fs, *output)
+	if err != nil {
+		return err
+	}
+	if *interval <= 0 || *timeout < 0 {
+		fmt.Fprintln(c.stderr, "interval must be positive and timeout can't be negative")
+		fs.Usage()
+		return errUsage
+	}
+
+	client, err := c.client(*configPath, false)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		order, err := client.GetOrderWithContext(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if order.Data.State == mailform.StatusFulfilled || order.Data.State == mailform.StatusCancelled {
+			return c.printOrder(*output, order)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("order %s is still %s: %w", id, order.Data.State, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// services lists the supported services with an estimated price for a single page.
+func (c *cli) services(args []string) error {
+	fs, output, _ := c.flagSet("services")
+
+	err := c.noArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	err = c.checkOutput(fs, *output)
+	if err != nil {
+		return err
+	}
+
+	type service struct {
+		Service string `json:"service"`
+		// Estimate is the estimated price in cents of a single page letter
+		Estimate int `json:"estimate"`
+	}
+
+	services := []service{}
+	for _, code := range mailform.ServiceCodes {
+		s := service{Service: code}
+		estimate, err := mailform.EstimatePrice(mailform.OrderInput{Service: code}, 1)
+		if err == nil {
+			s.Estimate = estimate.Total
+		}
+		services = append(services, s)
+	}
+
+	if *output == outputJSON {
+		return c.printJSON(services)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tESTIMATE (1 PAGE)")
+	for _, s := range services {
+		fmt.Fprintf(w, "%s\t%s\n", s.Service, formatCents(s.Estimate))
+	}
+
+	return w.Flush()
+}
+
+// printJSON prints v as indented JSON.
+func (c *cli) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+// printOrder prints an order in the output format.
+func (c *cli) printOrder(output string, order *mailform.Order) error {
+	if output == outputJSON {
+		return c.printJSON(order)
+	}
+
+	rows := [][2]string{
+		{"ID", order.Data.ID},
+		{"STATE", order.Data.State},
+		{"CUSTOMER REFERENCE", order.Data.CustomerReference},
+		{"TOTAL", formatCents(order.Data.Total)},
+		{"TEST MODE", strconv.FormatBool(order.Data.TestMode)},
+	}
+	if !order.Data.Created.IsZero() {
+		rows = append(rows, [2]string{"CREATED", order.Data.Created.Format(time.RFC3339)})
+	}
+	if order.Data.CancellationReason != "" {
+		rows = append(rows, [2]string{"CANCELLATION REASON", order.Data.CancellationReason})
+	}
+	for i, lineitem := range order.Data.Lineitems {
+		prefix := fmt.Sprintf("LINEITEM %d ", i+1)
+		to := []string{lineitem.To.Name, lineitem.To.Address1, lineitem.To.City, lineitem.To.State, lineitem.To.Postcode}
+		rows = append(rows,
+			[2]string{prefix + "SERVICE", lineitem.Service},
+			[2]string{prefix + "TO", strings.Join(nonEmpty(to), ", ")},
+		)
+	}
+	if order.DryRunRequest != nil {
+		rows = append(rows, [2]string{"DRY RUN", fmt.Sprintf("%s %s (%d byte body)", order.DryRunRequest.Method, order.DryRunRequest.URL, len(order.DryRunRequest.Body))})
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\n", row[0], row[1])
+	}
+
+	return w.Flush()
+}
+
+// formatCents formats cents as dollars.
+func formatCents(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+
+	return fmt.Sprintf("%s$%d.%02d", sign, cents/100, cents%100)
+}
+
+// nonEmpty returns the strings that aren't empty.
+func nonEmpty(values []string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/circa10a/go-mailform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCLI returns a cli with the given environment and the buffers it writes to.
+func testCLI(env map[string]string) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	return &cli{
+		stdout: stdout,
+		stderr: stderr,
+		getenv: func(key string) string { return env[key] },
+	}, stdout, stderr
+}
+
+// testServer serves orders in the given states in turn, repeating the last one.
+func testServer(t *testing.T, states ...string) *httptest.Server {
+	t.Helper()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer some_token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"code":"401","message":"unauthorized"}}`)
+			return
+		}
+
+		state := states[len(states)-1]
+		if requests < len(states) {
+			state = states[requests]
+		}
+		requests++
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"success":true,"data":{"id":"some_id","state":"%s","total":1234,"test_mode":true}}`, state)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// writeConfig writes a config file pointing at the server and returns its path.
+func writeConfig(t *testing.T, server *httptest.Server, token string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(fmt.Sprintf("token: %s\nbase_url: %s\n", token, server.URL)), 0o600)
+	require.NoError(t, err)
+
+	return path
+}
+
+func TestRun(t *testing.T) {
+	server := testServer(t, "queued", "queued", "fulfilled")
+	configPath := writeConfig(t, server, "some_token")
+
+	type test struct {
+		description    string
+		args           []string
+		env            map[string]string
+		expectedCode   int
+		expectedStdout []string
+		expectedStderr string
+	}
+
+	tests := []test{
+		{
+			description:  "No command prints usage",
+			args:         []string{},
+			expectedCode: 2,
+		},
+		{
+			description:  "Unknown command prints usage",
+			args:         []string{"some_command"},
+			expectedCode: 2,
+		},
+		{
+			description:    "Get an order as a table",
+			args:           []string{"get", "--config", configPath, "some_id"},
+			expectedStdout: []string{"ID", "some_id", "$12.34"},
+		},
+		{
+			description:    "Get an order as JSON",
+			args:           []string{"get", "--config", configPath, "-o", "json", "some_id"},
+			expectedStdout: []string{`"id": "some_id"`},
+		},
+		{
+			description:    "Flags can follow the order ID",
+			args:           []string{"get", "some_id", "--config", configPath, "-o", "json"},
+			expectedStdout: []string{`"id": "some_id"`},
+		},
+		{
+			description:    "Order IDs after -- aren't flags",
+			args:           []string{"get", "--config", configPath, "--", "some_id"},
+			expectedStdout: []string{"some_id"},
+		},
+		{
+			description:    "Get requires exactly one order ID",
+			args:           []string{"get", "some_id", "--config", configPath, "other_id"},
+			expectedCode:   2,
+			expectedStderr: "get requires exactly one order ID",
+		},
+		{
+			description:  "Get requires an order ID",
+			args:         []string{"get", "--config", configPath},
+			expectedCode: 2,
+		},
+		{
+			description:  "Unsupported output format",
+			args:         []string{"get", "--config", configPath, "-o", "xml", "some_id"},
+			expectedCode: 2,
+		},
+		{
+			description:    "Token from the environment overrides the config file",
+			args:           []string{"get", "--config", writeConfig(t, server, "bad_token"), "some_id"},
+			env:            map[string]string{tokenEnv: "some_token"},
+			expectedStdout: []string{"some_id"},
+		},
+		{
+			description:    "Invalid token",
+			args:           []string{"get", "--config", writeConfig(t, server, "bad_token"), "some_id"},
+			expectedCode:   1,
+			expectedStderr: "Error: Unauthorized",
+		},
+		{
+			description:    "Missing config file",
+			args:           []string{"get", "--config", filepath.Join(t.TempDir(), "missing.yaml"), "some_id"},
+			expectedCode:   1,
+			expectedStderr: "no such file",
+		},
+		{
+			description:    "Wait for an order to be fulfilled",
+			args:           []string{"wait", "--config", configPath, "--interval", "1ms", "some_id"},
+			expectedStdout: []string{"fulfilled"},
+		},
+		{
+			description:    "Wait with flags after the order ID",
+			args:           []string{"wait", "some_id", "--config", configPath, "--interval", "1ms", "-o", "json"},
+			expectedStdout: []string{`"state": "fulfilled"`},
+		},
+		{
+			description:    "Wait needs a positive interval",
+			args:           []string{"wait", "--config", configPath, "--interval", "0s", "some_id"},
+			expectedCode:   2,
+			expectedStderr: "interval must be positive",
+		},
+		{
+			description:    "Wait can't have a negative timeout",
+			args:           []string{"wait", "--config", configPath, "--timeout", "-1s", "some_id"},
+			expectedCode:   2,
+			expectedStderr: "timeout can't be negative",
+		},
+		{
+			description:    "Cancel an order",
+			args:           []string{"cancel", "--config", writeConfig(t, testServer(t, "cancelled"), "some_token"), "some_id"},
+			expectedStdout: []string{"some_id", "cancelled"},
+		},
+		{
+			description:  "Cancel requires an order ID",
+			args:         []string{"cancel", "--config", configPath},
+			expectedCode: 2,
+		},
+		{
+			description:    "Services takes no arguments",
+			args:           []string{"services", "extra"},
+			expectedCode:   2,
+			expectedStderr: "services takes no arguments, got extra",
+		},
+		{
+			description:    "List services",
+			args:           []string{"services"},
+			expectedStdout: []string{"SERVICE", "USPS_POSTCARD"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c, stdout, stderr := testCLI(test.env)
+			code := c.run(test.args)
+			assert.Equal(t, test.expectedCode, code, stderr.String())
+			for _, expected := range test.expectedStdout {
+				assert.Contains(t, stdout.String(), expected)
+			}
+			assert.Contains(t, stderr.String(), test.expectedStderr)
+		})
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	server := testServer(t, "queued")
+	configPath := writeConfig(t, server, "some_token")
+
+	c, _, stderr := testCLI(nil)
+	code := c.run([]string{"wait", "--config", configPath, "--interval", "1h", "--timeout", "50ms", "some_id"})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "order some_id is still queued")
+}
+
+func TestSendDryRun(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "order.yaml")
+	err := os.WriteFile(inputPath, []byte(`
+url: https://example.com/letter.pdf
+service: USPS_STANDARD
+to.name: some_name
+to.address1: some_address1
+to.city: some_city
+to.state: some_state
+to.postcode: 12345
+to.country: some_country
+from.name: some_name
+from.address1: some_address1
+from.city: some_city
+from.state: some_state
+from.postcode: 12345
+from.country: some_country
+color: true
+`), 0o600)
+	require.NoError(t, err)
+	configPath := filepath.Join(dir, "config.yaml")
+	err = os.WriteFile(configPath, []byte("{}\n"), 0o600)
+	require.NoError(t, err)
+
+	type test struct {
+		description    string
+		args           []string
+		expectedCode   int
+		expectedStderr string
+		check          func(t *testing.T, body string)
+	}
+
+	tests := []test{
+		{
+			description: "Input file",
+			args:        []string{"send", "--dry-run", "-o", "json", "-f", inputPath},
+			check: func(t *testing.T, body string) {
+				assert.Contains(t, body, "USPS_STANDARD")
+			},
+		},
+		{
+			description: "Flags override the input file",
+			args:        []string{"send", "--dry-run", "-o", "json", "-f", inputPath, "--service", "USPS_PRIORITY", "--simplex"},
+			check: func(t *testing.T, body string) {
+				assert.Contains(t, body, "USPS_PRIORITY")
+				assert.Contains(t, body, `"simplex": true`)
+			},
+		},
+		{
+			description:    "Invalid order",
+			args:           []string{"send", "--dry-run", "--service", "USPS_STANDARD"},
+			expectedCode:   1,
+			expectedStderr: "Error: Invalid order input",
+		},
+		{
+			description:    "Invalid flag value",
+			args:           []string{"send", "--dry-run", "-f", inputPath, "--amount", "lots"},
+			expectedCode:   1,
+			expectedStderr: "invalid value for amount",
+		},
+		{
+			description:    "Stray arguments are an error",
+			args:           []string{"send", "-f", inputPath, "extra", "--dry-run"},
+			expectedCode:   2,
+			expectedStderr: "send takes no arguments, got extra",
+		},
+		{
+			description:    "No token without dry run",
+			args:           []string{"send", "-f", inputPath},
+			expectedCode:   1,
+			expectedStderr: "no API token",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c, stdout, stderr := testCLI(map[string]string{configEnv: configPath})
+			code := c.run(test.args)
+			assert.Equal(t, test.expectedCode, code, stderr.String())
+			assert.Contains(t, stderr.String(), test.expectedStderr)
+			if test.check != nil {
+				order := map[string]interface{}{}
+				require.NoError(t, json.Unmarshal(stdout.Bytes(), &order))
+				test.check(t, stdout.String())
+			}
+		})
+	}
+}
+
+func TestReadOrderInput(t *testing.T) {
+	dir := t.TempDir()
+
+	inputPath := filepath.Join(dir, "order.yaml")
+	err := os.WriteFile(inputPath, []byte(`
+to.postcode: 02134
+from.postcode: 0123
+amount: 1500
+to.address2:
+color: true
+`), 0o600)
+	require.NoError(t, err)
+
+	input := mailform.OrderInput{}
+	require.NoError(t, readOrderInput(inputPath, &input))
+	// Leading zeros are kept
+	assert.Equal(t, "02134", input.ToPostcode)
+	assert.Equal(t, "0123", input.FromPostcode)
+	assert.Equal(t, 1500, input.Amount)
+	assert.Equal(t, "", input.ToAddress2)
+	assert.True(t, input.Color)
+
+	jsonPath := filepath.Join(dir, "order.json")
+	err = os.WriteFile(jsonPath, []byte(`{"to.postcode": "02134", "amount": 1500}`), 0o600)
+	require.NoError(t, err)
+	input = mailform.OrderInput{}
+	require.NoError(t, readOrderInput(jsonPath, &input))
+	assert.Equal(t, "02134", input.ToPostcode)
+	assert.Equal(t, 1500, input.Amount)
+
+	listPath := filepath.Join(dir, "list.yaml")
+	err = os.WriteFile(listPath, []byte("to.name: [a, b]\n"), 0o600)
+	require.NoError(t, err)
+	err = readOrderInput(listPath, &mailform.OrderInput{})
+	assert.ErrorContains(t, err, "to.name must be a single value")
+}
@@ -0,0 +1,433 @@
+package mailform
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrUnknownCountry is returned when a country isn't an ISO 3166-1 country name or code.
+var ErrUnknownCountry = errors.New("unknown country")
+
+// Country is an ISO 3166-1 country.
+type Country struct {
+	// Alpha2 is the 2 letter code, the canonical code used in order inputs
+	Alpha2 string `json:"alpha2"`
+	// Alpha3 is the 3 letter code
+	Alpha3 string `json:"alpha3"`
+	Name   string `json:"name"`
+}
+
+// countryRule is what a country's addresses need, beyond the name, first address line and city.
+type countryRule struct {
+	requireState    bool
+	requirePostcode bool
+	// postcode is the format postcodes must match when given, nil accepts anything
+	postcode *regexp.Regexp
+	// postcodeExample shows the postcode format in errors
+	postcodeExample string
+}
+
+// isoCountries are the ISO 3166-1 countries as alpha-2, alpha-3 and short name, one per line.
+const isoCountries = `AF AFG Afghanistan
+AX ALA Åland Islands
+AL ALB Albania
+DZ DZA Algeria
+AS ASM American Samoa
+AD AND Andorra
+AO AGO Angola
+AI AIA Anguilla
+AQ ATA Antarctica
+AG ATG Antigua and Barbuda
+AR ARG Argentina
+AM ARM Armenia
+AW ABW Aruba
+AU AUS Australia
+AT AUT Austria
+AZ AZE Azerbaijan
+BS BHS Bahamas
+BH BHR Bahrain
+BD BGD Bangladesh
+BB BRB Barbados
+BY BLR Belarus
+BE BEL Belgium
+BZ BLZ Belize
+BJ BEN Benin
+BM BMU Bermuda
+BT BTN Bhutan
+BO BOL Bolivia
+BQ BES Bonaire, Sint Eustatius and Saba
+BA BIH Bosnia and Herzegovina
+BW BWA Botswana
+BV BVT Bouvet Island
+BR BRA Brazil
+IO IOT British Indian Ocean Territory
+BN BRN Brunei Darussalam
+BG BGR Bulgaria
+BF BFA Burkina Faso
+BI BDI Burundi
+CV CPV Cabo Verde
+KH KHM Cambodia
+CM CMR Cameroon
+CA CAN Canada
+KY CYM Cayman Islands
+CF CAF Central African Republic
+TD TCD Chad
+CL CHL Chile
+CN CHN China
+CX CXR Christmas Island
+CC CCK Cocos (Keeling) Islands
+CO COL Colombia
+KM COM Comoros
+CG COG Congo
+CD COD Democratic Republic of the Congo
+CK COK Cook Islands
+CR CRI Costa Rica
+CI CIV Côte d'Ivoire
+HR HRV Croatia
+CU CUB Cuba
+CW CUW Curaçao
+CY CYP Cyprus
+CZ CZE Czechia
+DK DNK Denmark
+DJ DJI Djibouti
+DM DMA Dominica
+DO DOM Dominican Republic
+EC ECU Ecuador
+EG EGY Egypt
+SV SLV El Salvador
+GQ GNQ Equatorial Guinea
+ER ERI Eritrea
+EE EST Estonia
+SZ SWZ Eswatini
+ET ETH Ethiopia
+FK FLK Falkland Islands
+FO FRO Faroe Islands
+FJ FJI Fiji
+FI FIN Finland
+FR FRA France
+GF GUF French Guiana
+PF PYF French Polynesia
+TF ATF French Southern Territories
+GA GAB Gabon
+GM GMB Gambia
+GE GEO Georgia
+DE DEU Germany
+GH GHA Ghana
+GI GIB Gibraltar
+GR GRC Greece
+GL GRL Greenland
+GD GRD Grenada
+GP GLP Guadeloupe
+GU GUM Guam
+GT GTM Guatemala
+GG GGY Guernsey
+GN GIN Guinea
+GW GNB Guinea-Bissau
+GY GUY Guyana
+HT HTI Haiti
+HM HMD Heard Island and McDonald Islands
+VA VAT Holy See
+HN HND Honduras
+HK HKG Hong Kong
+HU HUN Hungary
+IS ISL Iceland
+IN IND India
+ID IDN Indonesia
+IR IRN Iran
+IQ IRQ Iraq
+IE IRL Ireland
+IM IMN Isle of Man
+IL ISR Israel
+IT ITA Italy
+JM JAM Jamaica
+JP JPN Japan
+JE JEY Jersey
+JO JOR Jordan
+KZ KAZ Kazakhstan
+KE KEN Kenya
+KI KIR Kiribati
+KP PRK North Korea
+KR KOR South Korea
+KW KWT Kuwait
+KG KGZ Kyrgyzstan
+LA LAO Laos
+LV LVA Latvia
+LB LBN Lebanon
+LS LSO Lesotho
+LR LBR Liberia
+LY LBY Libya
+LI LIE Liechtenstein
+LT LTU Lithuania
+LU LUX Luxembourg
+MO MAC Macao
+MG MDG Madagascar
+MW MWI Malawi
+MY MYS Malaysia
+MV MDV Maldives
+ML MLI Mali
+MT MLT Malta
+MH MHL Marshall Islands
+MQ MTQ Martinique
+MR MRT Mauritania
+MU MUS Mauritius
+YT MYT Mayotte
+MX MEX Mexico
+FM FSM Micronesia
+MD MDA Moldova
+MC MCO Monaco
+MN MNG Mongolia
+ME MNE Montenegro
+MS MSR Montserrat
+MA MAR Morocco
+MZ MOZ Mozambique
+MM MMR Myanmar
+NA NAM Namibia
+NR NRU Nauru
+NP NPL Nepal
+NL NLD Netherlands
+NC NCL New Caledonia
+NZ NZL New Zealand
+NI NIC Nicaragua
+NE NER Niger
+NG NGA Nigeria
+NU NIU Niue
+NF NFK Norfolk Island
+MK MKD North Macedonia
+MP MNP Northern Mariana Islands
+NO NOR Norway
+OM OMN Oman
+PK PAK Pakistan
+PW PLW Palau
+PS PSE Palestine
+PA PAN Panama
+PG PNG Papua New Guinea
+PY PRY Paraguay
+PE PER Peru
+PH PHL Philippines
+PN PCN Pitcairn
+PL POL Poland
+PT PRT Portugal
+PR PRI Puerto Rico
+QA QAT Qatar
+RE REU Réunion
+RO ROU Romania
+RU RUS Russia
+RW RWA Rwanda
+BL BLM Saint Barthélemy
+SH SHN Saint Helena, Ascension and Tristan da Cunha
+KN KNA Saint Kitts and Nevis
+LC LCA Saint Lucia
+MF MAF Saint Martin
+PM SPM Saint Pierre and Miquelon
+VC VCT Saint Vincent and the Grenadines
+WS WSM Samoa
+SM SMR San Marino
+ST STP Sao Tome and Principe
+SA SAU Saudi Arabia
+SN SEN Senegal
+RS SRB Serbia
+SC SYC Seychelles
+SL SLE Sierra Leone
+SG SGP Singapore
+SX SXM Sint Maarten
+SK SVK Slovakia
+SI SVN Slovenia
+SB SLB Solomon Islands
+SO SOM Somalia
+ZA ZAF South Africa
+GS SGS South Georgia and the South Sandwich Islands
+SS SSD South Sudan
+ES ESP Spain
+LK LKA Sri Lanka
+SD SDN Sudan
+SR SUR Suriname
+SJ SJM Svalbard and Jan Mayen
+SE SWE Sweden
+CH CHE Switzerland
+SY SYR Syria
+TW TWN Taiwan
+TJ TJK Tajikistan
+TZ TZA Tanzania
+TH THA Thailand
+TL TLS Timor-Leste
+TG TGO Togo
+TK TKL Tokelau
+TO TON Tonga
+TT TTO Trinidad and Tobago
+TN TUN Tunisia
+TR TUR Türkiye
+TM TKM Turkmenistan
+TC TCA Turks and Caicos Islands
+TV TUV Tuvalu
+UG UGA Uganda
+UA UKR Ukraine
+AE ARE United Arab Emirates
+GB GBR United Kingdom
+US USA United States
+UM UMI United States Minor Outlying Islands
+UY URY Uruguay
+UZ UZB Uzbekistan
+VU VUT Vanuatu
+VE VEN Venezuela
+VN VNM Vietnam
+VG VGB British Virgin Islands
+VI VIR US Virgin Islands
+WF WLF Wallis and Futuna
+EH ESH Western Sahara
+YE YEM Yemen
+ZM ZMB Zambia
+ZW ZWE Zimbabwe`
+
+var (
+	// countries are the ISO 3166-1 countries by alpha-2 code, built from isoCountries
+	countries = map[string]Country{}
+	// countryKeys maps the normalized names and codes of countries to their alpha-2 code
+	countryKeys = map[string]string{}
+	// countryAliases are other names countries are commonly written as
+	countryAliases = map[string]string{
+		"UNITED STATES OF AMERICA": "US", "AMERICA": "US",
+		"UK": "GB", "GREAT BRITAIN": "GB", "BRITAIN": "GB", "ENGLAND": "GB", "SCOTLAND": "GB", "WALES": "GB", "NORTHERN IRELAND": "GB",
+		"UNITED KINGDOM OF GREAT BRITAIN AND NORTHERN IRELAND": "GB",
+		"KOREA": "KR", "REPUBLIC OF KOREA": "KR", "DEMOCRATIC PEOPLE'S REPUBLIC OF KOREA": "KP",
+		"RUSSIAN FEDERATION": "RU", "VIET NAM": "VN", "LAO PEOPLE'S DEMOCRATIC REPUBLIC": "LA",
+		"SYRIAN ARAB REPUBLIC": "SY", "CZECH REPUBLIC": "CZ", "TURKEY": "TR", "IVORY COAST": "CI",
+		"MACEDONIA": "MK", "SWAZILAND": "SZ", "CAPE VERDE": "CV", "HOLLAND": "NL", "BURMA": "MM",
+		"VATICAN": "VA", "VATICAN CITY": "VA", "BRUNEI": "BN", "MACAU": "MO", "EAST TIMOR": "TL",
+		"DR CONGO": "CD", "DRC": "CD", "REPUBLIC OF THE CONGO": "CG",
+		"FEDERATED STATES OF MICRONESIA": "FM", "UNITED STATES VIRGIN ISLANDS": "VI", "PALESTINE, STATE OF": "PS",
+		"DEUTSCHLAND": "DE", "ESPAÑA": "ES", "NIPPON": "JP",
+	}
+	// countryDiacritics are the accented letters in country names and what they're commonly typed as
+	countryDiacritics = strings.NewReplacer("Å", "A", "Ô", "O", "Ç", "C", "É", "E", "Ü", "U", "Ñ", "N")
+
+	// usPostcodeRule is the rule for the US and its territories, which use ZIP codes and state codes
+	usPostcodeRule = countryRule{requireState: true, requirePostcode: true, postcode: usZIPCode, postcodeExample: "12345 or 12345-6789"}
+	// legacyCountryRule is used for countries that can't be parsed, which need every field like before countries were known
+	legacyCountryRule = countryRule{requireState: true, requirePostcode: true}
+	// countryRules are the address rules of major destinations. Other countries only need a name, address and city.
+	countryRules = map[string]countryRule{
+		"US": usPostcodeRule, "PR": usPostcodeRule, "VI": usPostcodeRule, "GU": usPostcodeRule, "AS": usPostcodeRule, "MP": usPostcodeRule,
+		"CA": {requireState: true, requirePostcode: true, postcode: regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`), postcodeExample: "A1A 1A1"},
+		"MX": {requireState: true, requirePostcode: true, postcode: regexp.MustCompile(`^\d{5}$`), postcodeExample: "12345"},
+		"BR": {requireState: true, requirePostcode: true, postcode: regexp.MustCompile(`^\d{5}-?\d{3}$`), postcodeExample: "12345-678"},
+		"AU": {requireState: true, requirePostcode: true, postcode: regexp.MustCompile(`^\d{4}$`), postcodeExample: "1234"},
+		"NZ": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{4}$`), postcodeExample: "1234"},
+		"GB": {requirePostcode: true, postcode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`), postcodeExample: "SW1A 1AA"},
+		"IE": {postcode: regexp.MustCompile(`^[A-Z]\d[\dW] ?[A-Z\d]{4}$`), postcodeExample: "D02 X285"},
+		"DE": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{5}$`), postcodeExample: "12345"},
+		"FR": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{5}$`), postcodeExample: "12345"},
+		"IT": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{5}$`), postcodeExample: "12345"},
+		"ES": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{5}$`), postcodeExample: "12345"},
+		"FI": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{5}$`), postcodeExample: "12345"},
+		"NL": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`), postcodeExample: "1234 AB"},
+		"BE": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{4}$`), postcodeExample: "1234"},
+		"AT": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{4}$`), postcodeExample: "1234"},
+		"CH": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{4}$`), postcodeExample: "1234"},
+		"DK": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{4}$`), postcodeExample: "1234"},
+		"NO": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{4}$`), postcodeExample: "1234"},
+		"SE": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{3} ?\d{2}$`), postcodeExample: "123 45"},
+		"PL": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{2}-\d{3}$`), postcodeExample: "12-345"},
+		"PT": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{4}-\d{3}$`), postcodeExample: "1234-567"},
+		"JP": {requireState: true, requirePostcode: true, postcode: regexp.MustCompile(`^\d{3}-?\d{4}$`), postcodeExample: "123-4567"},
+		"CN": {requireState: true, requirePostcode: true, postcode: regexp.MustCompile(`^\d{6}$`), postcodeExample: "123456"},
+		"IN": {requireState: true, requirePostcode: true, postcode: regexp.MustCompile(`^\d{6}$`), postcodeExample: "123456"},
+		"KR": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{5}$`), postcodeExample: "12345"},
+		"SG": {requirePostcode: true, postcode: regexp.MustCompile(`^\d{6}$`), postcodeExample: "123456"},
+	}
+)
+
+func init() {
+	for _, line := range strings.Split(isoCountries, "\n") {
+		parts := strings.SplitN(line, " ", 3)
+		country := Country{Alpha2: parts[0], Alpha3: parts[1], Name: parts[2]}
+		countries[country.Alpha2] = country
+		for _, key := range []string{country.Alpha2, country.Alpha3, country.Name} {
+			countryKeys[countryKey(key)] = country.Alpha2
+		}
+	}
+	for alias, code := range countryAliases {
+		countryKeys[countryKey(alias)] = code
+	}
+}
+
+// countryKey normalizes a country name or code for lookup, ignoring case, accents, periods and extra whitespace.
+func countryKey(s string) string {
+	s = countryDiacritics.Replace(strings.ToUpper(s))
+	s = strings.NewReplacer(".", "", "&", " AND ", "’", "'").Replace(s)
+	words := strings.Fields(s)
+	if len(words) > 1 && words[0] == "THE" {
+		words = words[1:]
+	}
+	if len(words) > 1 && words[0] == "ST" {
+		words[0] = "SAINT"
+	}
+
+	return strings.Join(words, " ")
+}
+
+// ParseCountry parses an ISO 3166-1 alpha-2 code, alpha-3 code or country name, ignoring case and accents.
+// Common alternative names like UK or South Korea are understood too.
+func ParseCountry(s string) (Country, error) {
+	code, ok := countryKeys[countryKey(s)]
+	if !ok {
+		return Country{}, fmt.Errorf("%w: '%s'", ErrUnknownCountry, s)
+	}
+
+	return countries[code], nil
+}
+
+// isUnitedStates returns true if country is the United States.
+func isUnitedStates(country string) bool {
+	c, err := ParseCountry(country)
+
+	return err == nil && c.Alpha2 == "US"
+}
+
+// addressRule returns the country of an address and its rule, or the legacy rule if the country is unknown.
+func addressRule(a Address) (Country, countryRule) {
+	country, err := ParseCountry(a.Country)
+	if err != nil {
+		return country, legacyCountryRule
+	}
+
+	return country, countryRules[country.Alpha2]
+}
+
+// validateAddressRegion checks the state, postcode and country of an address against the rules of its country.
+// prefix is the OrderInput field prefix of the address.
+func validateAddressRegion(prefix string, a Address) error {
+	genericRejectionStr := "%s not provided, but is required"
+	country, rule := addressRule(a)
+
+	if rule.requireState && strings.TrimSpace(a.State) == "" {
+		return &ErrOrderInvalid{
+			field:   prefix + "State",
+			message: fmt.Sprintf(genericRejectionStr, prefix+"State"),
+		}
+	}
+
+	postcode := strings.ToUpper(strings.TrimSpace(a.Postcode))
+	if rule.requirePostcode && postcode == "" {
+		return &ErrOrderInvalid{
+			field:   prefix + "Postcode",
+			message: fmt.Sprintf(genericRejectionStr, prefix+"Postcode"),
+		}
+	}
+	if rule.postcode != nil && postcode != "" && !rule.postcode.MatchString(postcode) {
+		return &ErrOrderInvalid{
+			field:   prefix + "Postcode",
+			message: fmt.Sprintf("postcode: '%s' is not a valid %s postcode, must look like %s", a.Postcode, country.Name, rule.postcodeExample),
+		}
+	}
+
+	if strings.TrimSpace(a.Country) == "" {
+		return &ErrOrderInvalid{
+			field:   prefix + "Country",
+			message: fmt.Sprintf(genericRejectionStr, prefix+"Country"),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package mailform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCountry(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  string
+		expectErr bool
+	}{
+		{name: "alpha-2", input: "us", expected: "US"},
+		{name: "alpha-3", input: "DEU", expected: "DE"},
+		{name: "name", input: "united kingdom", expected: "GB"},
+		{name: "alias", input: "U.K.", expected: "GB"},
+		{name: "periods", input: "U.S.A.", expected: "US"},
+		{name: "article", input: "The Netherlands", expected: "NL"},
+		{name: "accent", input: "Côte d’Ivoire", expected: "CI"},
+		{name: "without accent", input: "Cote d'Ivoire", expected: "CI"},
+		{name: "saint", input: "St. Lucia", expected: "LC"},
+		{name: "ampersand", input: "Trinidad & Tobago", expected: "TT"},
+		{name: "whitespace", input: "  south   korea ", expected: "KR"},
+		{name: "unknown", input: "some_country", expectErr: true},
+		{name: "empty", input: "", expectErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			country, err := ParseCountry(test.input)
+			if test.expectErr {
+				assert.ErrorIs(t, err, ErrUnknownCountry)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, country.Alpha2)
+		})
+	}
+
+	country, err := ParseCountry("germany")
+	assert.NoError(t, err)
+	assert.Equal(t, Country{Alpha2: "DE", Alpha3: "DEU", Name: "Germany"}, country)
+	assert.Len(t, countries, 249)
+}
+
+func TestValidateCountryRules(t *testing.T) {
+	tests := []struct {
+		name           string
+		to             Address
+		expectedErrStr string
+	}{
+		{
+			name: "US",
+			to:   Address{State: "TX", Postcode: "78701-1234", Country: "US"},
+		},
+		{
+			name:           "US without state",
+			to:             Address{Postcode: "78701", Country: "United States"},
+			expectedErrStr: "ToState not provided",
+		},
+		{
+			name:           "US malformed ZIP",
+			to:             Address{State: "TX", Postcode: "7870", Country: "USA"},
+			expectedErrStr: "postcode: '7870' is not a valid United States postcode, must look like 12345 or 12345-6789",
+		},
+		{
+			name: "UK without state",
+			to:   Address{Postcode: "sw1a 2aa", Country: "UK"},
+		},
+		{
+			name:           "UK without postcode",
+			to:             Address{Country: "GB"},
+			expectedErrStr: "ToPostcode not provided",
+		},
+		{
+			name:           "UK malformed postcode",
+			to:             Address{Postcode: "12345", Country: "GB"},
+			expectedErrStr: "is not a valid United Kingdom postcode, must look like SW1A 1AA",
+		},
+		{
+			name: "Canada",
+			to:   Address{State: "ON", Postcode: "K1A 0B1", Country: "CAN"},
+		},
+		{
+			name:           "Canada without province",
+			to:             Address{Postcode: "K1A 0B1", Country: "Canada"},
+			expectedErrStr: "ToState not provided",
+		},
+		{
+			name: "Ireland without Eircode",
+			to:   Address{Country: "Ireland"},
+		},
+		{
+			name: "country without rules",
+			to:   Address{Country: "Hong Kong"},
+		},
+		{
+			name:           "unknown country",
+			to:             Address{Country: "some_country"},
+			expectedErrStr: "ToState not provided",
+		},
+		{
+			name:           "no country",
+			to:             Address{State: "some_state", Postcode: "some_postcode"},
+			expectedErrStr: "ToCountry not provided",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := testOrderInput()
+			test.to.Name = input.ToName
+			test.to.Address1 = input.ToAddress1
+			test.to.City = input.ToCity
+			input.SetTo(test.to)
+
+			err := input.Validate()
+			if test.expectedErrStr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, test.expectedErrStr)
+		})
+	}
+
+	// The sender address has rules too
+	input := testOrderInput()
+	input.FromState = ""
+	input.FromPostcode = "75008"
+	input.FromCountry = "France"
+	assert.NoError(t, input.Validate())
+
+	input.FromPostcode = "7500"
+	assert.ErrorContains(t, input.Validate(), "postcode: '7500' is not a valid France postcode")
+}
@@ -0,0 +1,193 @@
+package mailform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultNameSimilarity is how similar recipient names at the same address must be to be duplicates by default
+	DefaultNameSimilarity = 0.8
+)
+
+// DedupeStrategy is what is done with duplicate recipients.
+type DedupeStrategy int
+
+const (
+	// DedupeKeepFirst keeps the first order input of each cluster of duplicates
+	DedupeKeepFirst DedupeStrategy = iota
+	// DedupeKeepAll keeps every order input, only reporting the duplicates
+	DedupeKeepAll
+	// DedupeMerge keeps the first order input of each cluster with empty recipient fields filled in from the rest
+	DedupeMerge
+)
+
+// DedupeOptions configures how duplicate recipients are found and handled.
+type DedupeOptions struct {
+	// Strategy is what is done with duplicates, defaults to DedupeKeepFirst
+	Strategy DedupeStrategy
+	// NameSimilarity is how similar names at the same address must be from 0 to 1, defaults to DefaultNameSimilarity.
+	// Set it to 1 to only match names that are the same once normalized.
+	NameSimilarity float64
+}
+
+// DuplicateCluster is a group of order inputs to the same recipient.
+type DuplicateCluster struct {
+	// Indexes are the positions of the order inputs in the batch, in order
+	Indexes []int
+	// Reason explains why the order inputs are duplicates
+	Reason string
+}
+
+// Dedupe finds order inputs to the same recipient, being the same normalized address and a similar name,
+// such as "Jane Doe, 123 North Main Street" and "JANE  DOE, 123 N MAIN ST". Only the recipient is compared.
+// It returns the order inputs left by the strategy, in their original order, and the clusters of duplicates found.
+func Dedupe(inputs []OrderInput, opts *DedupeOptions) ([]OrderInput, []DuplicateCluster) {
+	if opts == nil {
+		opts = &DedupeOptions{}
+	}
+	threshold := opts.NameSimilarity
+	if threshold <= 0 {
+		threshold = DefaultNameSimilarity
+	}
+
+	// Group by address first so names are only compared with names at the same address
+	groups := map[string][]int{}
+	for i, input := range inputs {
+		key := addressKey(addressWithoutName(input.To()))
+		groups[key] = append(groups[key], i)
+	}
+
+	clusters := []DuplicateCluster{}
+	for _, group := range groups {
+		for _, indexes := range clusterNames(inputs, group, threshold) {
+			if len(indexes) > 1 {
+				clusters = append(clusters, DuplicateCluster{
+					Indexes: indexes,
+					Reason:  duplicateReason(inputs, indexes),
+				})
+			}
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Indexes[0] < clusters[j].Indexes[0]
+	})
+
+	if opts.Strategy == DedupeKeepAll {
+		return append([]OrderInput{}, inputs...), clusters
+	}
+
+	kept := make([]OrderInput, len(inputs))
+	copy(kept, inputs)
+	dropped := map[int]bool{}
+	for _, cluster := range clusters {
+		first := cluster.Indexes[0]
+		for _, i := range cluster.Indexes[1:] {
+			dropped[i] = true
+			if opts.Strategy == DedupeMerge {
+				kept[first].SetTo(mergeAddresses(kept[first].To(), inputs[i].To()))
+			}
+		}
+	}
+
+	deduped := []OrderInput{}
+	for i, input := range kept {
+		if !dropped[i] {
+			deduped = append(deduped, input)
+		}
+	}
+
+	return deduped, clusters
+}
+
+// clusterNames splits order inputs at the same address into clusters of similar names, each sorted by index.
+// Names are similar if they're similar to any name already in the cluster.
+func clusterNames(inputs []OrderInput, indexes []int, threshold float64) [][]int {
+	clusters := [][]int{}
+	for _, i := range indexes {
+		joined := -1
+		for c := range clusters {
+			for _, j := range clusters[c] {
+				if nameSimilarity(recipientName(inputs[i]), recipientName(inputs[j])) >= threshold {
+					joined = c
+					break
+				}
+			}
+			if joined >= 0 {
+				break
+			}
+		}
+
+		if joined < 0 {
+			clusters = append(clusters, []int{i})
+			continue
+		}
+		clusters[joined] = append(clusters[joined], i)
+	}
+
+	return clusters
+}
+
+// recipientName returns the name of the recipient, or the organization if there's no name.
+func recipientName(o OrderInput) string {
+	if strings.TrimSpace(o.ToName) == "" {
+		return o.ToOrganization
+	}
+
+	return o.ToName
+}
+
+// nameSimilarity returns how similar two names are from 0 to 1, ignoring the order of their words
+// so "Doe, Jane" is the same as "Jane Doe".
+func nameSimilarity(a, b string) float64 {
+	sorted := func(s string) string {
+		words := strings.Fields(similarityKey(s))
+		sort.Strings(words)
+		return strings.Join(words, " ")
+	}
+
+	score := similarity(a, b)
+	if sortedScore := similarity(sorted(a), sorted(b)); sortedScore > score {
+		return sortedScore
+	}
+
+	return score
+}
+
+// duplicateReason explains why order inputs are duplicates.
+func duplicateReason(inputs []OrderInput, indexes []int) string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, i := range indexes {
+		name := recipientName(inputs[i])
+		key := similarityKey(name)
+		if !seen[key] {
+			seen[key] = true
+			names = append(names, fmt.Sprintf("%q", name))
+		}
+	}
+
+	address := strings.Join(addressWithoutName(inputs[indexes[0]].To()).Lines(), ", ")
+	if len(names) == 1 {
+		return fmt.Sprintf("same name %s and address %s", names[0], address)
+	}
+
+	return fmt.Sprintf("similar names %s at the same address %s", strings.Join(names, ", "), address)
+}
+
+// mergeAddresses fills in the empty fields of a from b, and the ZIP+4 if b has one and a doesn't.
+func mergeAddresses(a, b Address) Address {
+	fields, others := a.fields(), b.fields()
+	for i, field := range fields {
+		if strings.TrimSpace(*field) == "" {
+			*field = *others[i]
+		}
+	}
+
+	if len(b.Postcode) > len(a.Postcode) && strings.HasPrefix(b.Postcode, a.Postcode+"-") {
+		a.Postcode = b.Postcode
+	}
+
+	return a
+}
@@ -0,0 +1,102 @@
+package mailform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDedupeInputs() []OrderInput {
+	inputs := []OrderInput{}
+	for _, to := range []Address{
+		{Name: "Jane Doe", Address1: "123 North Main Street", City: "Austin", State: "TX", Postcode: "78701", Country: "US"},
+		{Name: "John Smith", Address1: "1 Elm St", City: "Austin", State: "TX", Postcode: "78702", Country: "US"},
+		{Name: "JANE  DOE", Organization: "Acme", Address1: "123 N Main St", City: "Austin", State: "Texas", Postcode: "78701-1234", Country: "USA"},
+		{Name: "Jane Smith", Address1: "1 Elm Street", City: "Austin", State: "TX", Postcode: "78702", Country: "US"},
+		{Name: "Jon Smith", Address1: "1 ELM ST", City: "AUSTIN", State: "TX", Postcode: "78702", Country: "US"},
+		{Name: "Doe, Jane", Address1: "123 Main St", City: "Austin", State: "TX", Postcode: "78701", Country: "US"},
+	} {
+		input := testOrderInput()
+		input.SetTo(to)
+		inputs = append(inputs, input)
+	}
+
+	return inputs
+}
+
+func TestDedupe(t *testing.T) {
+	expectedClusters := []DuplicateCluster{
+		{
+			Indexes: []int{0, 2},
+			Reason:  `same name "Jane Doe" and address 123 North Main Street, Austin, TX 78701, US`,
+		},
+		{
+			Indexes: []int{1, 4},
+			Reason:  `similar names "John Smith", "Jon Smith" at the same address 1 Elm St, Austin, TX 78702, US`,
+		},
+	}
+
+	tests := []struct {
+		name          string
+		opts          *DedupeOptions
+		expectedNames []string
+		expectedFirst Address
+	}{
+		{
+			name:          "keep first",
+			opts:          nil,
+			expectedNames: []string{"Jane Doe", "John Smith", "Jane Smith", "Doe, Jane"},
+			expectedFirst: testDedupeInputs()[0].To(),
+		},
+		{
+			name:          "keep all",
+			opts:          &DedupeOptions{Strategy: DedupeKeepAll},
+			expectedNames: []string{"Jane Doe", "John Smith", "JANE  DOE", "Jane Smith", "Jon Smith", "Doe, Jane"},
+			expectedFirst: testDedupeInputs()[0].To(),
+		},
+		{
+			name:          "merge",
+			opts:          &DedupeOptions{Strategy: DedupeMerge},
+			expectedNames: []string{"Jane Doe", "John Smith", "Jane Smith", "Doe, Jane"},
+			expectedFirst: Address{Name: "Jane Doe", Organization: "Acme", Address1: "123 North Main Street", City: "Austin", State: "TX", Postcode: "78701-1234", Country: "US"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inputs := testDedupeInputs()
+			deduped, clusters := Dedupe(inputs, test.opts)
+			assert.Equal(t, expectedClusters, clusters)
+
+			names := []string{}
+			for _, input := range deduped {
+				names = append(names, input.ToName)
+			}
+			assert.Equal(t, test.expectedNames, names)
+			assert.Equal(t, test.expectedFirst, deduped[0].To())
+
+			// The batch is left alone
+			assert.Equal(t, testDedupeInputs(), inputs)
+		})
+	}
+}
+
+func TestDedupeNameSimilarity(t *testing.T) {
+	inputs := testDedupeInputs()
+
+	// Only exact names
+	_, clusters := Dedupe(inputs, &DedupeOptions{NameSimilarity: 1})
+	assert.Len(t, clusters, 1)
+	assert.Equal(t, []int{0, 2}, clusters[0].Indexes)
+
+	// Loose enough to catch the whole household
+	_, clusters = Dedupe(inputs, &DedupeOptions{NameSimilarity: 0.6})
+	assert.Len(t, clusters, 2)
+	assert.Equal(t, []int{1, 3, 4}, clusters[1].Indexes)
+}
+
+func TestNameSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, nameSimilarity("Doe, Jane", "jane doe"))
+	assert.InDelta(t, 0.9, nameSimilarity("Jon Smith", "John Smith"), 0.001)
+	assert.Less(t, nameSimilarity("John Smith", "Jane Smith"), DefaultNameSimilarity)
+}
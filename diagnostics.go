@@ -0,0 +1,165 @@
+package mailform
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Diagnostic severities
+	DiagnosticSeverityError   = "error"
+	DiagnosticSeverityWarning = "warning"
+)
+
+var (
+	// attributePaths maps OrderInput field names to their attribute paths, which are the same as their form data keys
+	attributePaths = map[string]string{
+		"FilePath":          "file",
+		"URL":               "url",
+		"CustomerReference": "customer_reference",
+		"Service":           "service",
+		"Webhook":           "webhook",
+		"Company":           "company",
+		"Simplex":           "simplex",
+		"Color":             "color",
+		"Flat":              "flat",
+		"Stamp":             "stamp",
+		"Message":           "message",
+		"ToName":            "to.name",
+		"ToOrganization":    "to.organization",
+		"ToAddress1":        "to.address1",
+		"ToAddress2":        "to.address2",
+		"ToCity":            "to.city",
+		"ToState":           "to.state",
+		"ToPostcode":        "to.postcode",
+		"ToCountry":         "to.country",
+		"FromName":          "from.name",
+		"FromOrganization":  "from.organization",
+		"FromAddress1":      "from.address1",
+		"FromAddress2":      "from.address2",
+		"FromCity":          "from.city",
+		"FromState":         "from.state",
+		"FromPostcode":      "from.postcode",
+		"FromCountry":       "from.country",
+		"BankAccount":       "bank_account",
+		"Amount":            "amount",
+		"CheckName":         "check_name",
+		"CheckNumber":       "check_number",
+		"CheckMemo":         "check_memo",
+	}
+	// sentinelAttributePaths are the attribute paths of sentinel errors caused by a specific field
+	sentinelAttributePaths = map[error]string{
+		ErrNoFileUploaded: "file",
+	}
+)
+
+// Diagnostic is a summary and detail of a problem, optionally attached to an attribute.
+// It is shaped to be converted directly to Terraform provider or CLI diagnostics.
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail"`
+	// AttributePath is the path of the attribute the problem is with, such as to.postcode
+	AttributePath string `json:"attribute_path,omitempty"`
+}
+
+// Diagnostics is a list of diagnostics.
+type Diagnostics []Diagnostic
+
+// HasError returns true if any of the diagnostics is an error.
+func (d Diagnostics) HasError() bool {
+	for _, diag := range d {
+		if diag.Severity == DiagnosticSeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AttributePath returns the attribute path of an OrderInput field name, such as to.postcode for ToPostcode.
+// It returns an empty string for unknown fields.
+func AttributePath(field string) string {
+	return attributePaths[field]
+}
+
+// ErrorDiagnostics converts an error into diagnostics.
+// Errors from this package get a summary and attribute path, anything else is passed through as is.
+func ErrorDiagnostics(err error) Diagnostics {
+	if err == nil {
+		return Diagnostics{}
+	}
+
+	invalidErr := &ErrOrderInvalid{}
+	if errors.As(err, &invalidErr) {
+		return invalidErr.Diagnostics()
+	}
+
+	mailformErr := &ErrMailform{}
+	if errors.As(err, &mailformErr) {
+		return mailformErr.Diagnostics()
+	}
+
+	return Diagnostics{
+		{
+			Severity: DiagnosticSeverityError,
+			Summary:  "Mailform request failed",
+			Detail:   err.Error(),
+		},
+	}
+}
+
+// Field returns the name of the OrderInput field that is invalid.
+func (e *ErrOrderInvalid) Field() string {
+	return e.field
+}
+
+// Diagnostics converts the error into diagnostics attached to the invalid field.
+func (e *ErrOrderInvalid) Diagnostics() Diagnostics {
+	return Diagnostics{
+		{
+			Severity:      DiagnosticSeverityError,
+			Summary:       "Invalid order input",
+			Detail:        e.message,
+			AttributePath: AttributePath(e.field),
+		},
+	}
+}
+
+// Diagnostics converts the error into diagnostics.
+// Known errors are summarized by their sentinel and the detail includes the HTTP context to help debugging.
+func (e *ErrMailform) Diagnostics() Diagnostics {
+	summary := "Mailform API error"
+	attributePath := ""
+
+	if sentinel := e.classify(); sentinel != nil {
+		message := sentinel.Error()
+		summary = strings.ToUpper(message[:1]) + message[1:]
+		attributePath = sentinelAttributePaths[sentinel]
+	}
+
+	detail := e.Error()
+	context := []string{}
+	if e.StatusCode != 0 {
+		context = append(context, fmt.Sprintf("HTTP %d", e.StatusCode))
+	}
+	if e.Method != "" {
+		context = append(context, fmt.Sprintf("%s %s", e.Method, e.Endpoint))
+	}
+	if e.RequestID != "" {
+		context = append(context, fmt.Sprintf("request ID %s", e.RequestID))
+	}
+	if len(context) > 0 {
+		detail = fmt.Sprintf("%s (%s)", detail, strings.Join(context, ", "))
+	}
+
+	return Diagnostics{
+		{
+			Severity:      DiagnosticSeverityError,
+			Summary:       summary,
+			Detail:        detail,
+			AttributePath: attributePath,
+		},
+	}
+}
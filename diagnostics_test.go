@@ -0,0 +1,145 @@
+package mailform
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorDiagnostics(t *testing.T) {
+	notFound := &ErrMailform{
+		StatusCode: http.StatusNotFound,
+		Method:     http.MethodGet,
+		Endpoint:   "/orders/someID",
+		RequestID:  "some_request_id",
+	}
+	notFound.Err.Code = "erroroccurred"
+	notFound.Err.Message = "not found"
+
+	bankAccountNotFound := &ErrMailform{
+		StatusCode: http.StatusNotFound,
+		Method:     http.MethodPost,
+		Endpoint:   "/orders",
+	}
+	bankAccountNotFound.Err.Code = "erroroccurred"
+	bankAccountNotFound.Err.Message = "Bank account not found"
+
+	noFile := &ErrMailform{}
+	noFile.Err.Message = "no_file_uploaded"
+
+	unknown := &ErrMailform{Detail: "something broke"}
+
+	tests := []struct {
+		name     string
+		input    error
+		expected Diagnostics
+	}{
+		{
+			name:     "EnsureNilIsEmpty",
+			input:    nil,
+			expected: Diagnostics{},
+		},
+		{
+			name:  "EnsureInvalidOrderHasAttributePath",
+			input: (&OrderInput{Service: "USPS_STANDARD", ToName: "some_name", ToAddress1: "some_address1", ToCity: "some_city", ToState: "some_state"}).Validate(),
+			expected: Diagnostics{
+				{
+					Severity:      DiagnosticSeverityError,
+					Summary:       "Invalid order input",
+					Detail:        "ToPostcode not provided, but is required",
+					AttributePath: "to.postcode",
+				},
+			},
+		},
+		{
+			name:  "EnsureMailformErrorHasContext",
+			input: fmt.Errorf("wrapped: %w", notFound),
+			expected: Diagnostics{
+				{
+					Severity: DiagnosticSeverityError,
+					Summary:  "Order not found",
+					Detail:   "not found (HTTP 404, GET /orders/someID, request ID some_request_id)",
+				},
+			},
+		},
+		{
+			name:  "EnsureOtherNotFoundIsNotOrderNotFound",
+			input: bankAccountNotFound,
+			expected: Diagnostics{
+				{
+					Severity: DiagnosticSeverityError,
+					Summary:  "Mailform API error",
+					Detail:   "Bank account not found (HTTP 404, POST /orders)",
+				},
+			},
+		},
+		{
+			name:  "EnsureNoFileHasAttributePath",
+			input: noFile,
+			expected: Diagnostics{
+				{
+					Severity:      DiagnosticSeverityError,
+					Summary:       "No file uploaded",
+					Detail:        "no_file_uploaded",
+					AttributePath: "file",
+				},
+			},
+		},
+		{
+			name:  "EnsureUnknownMailformError",
+			input: unknown,
+			expected: Diagnostics{
+				{
+					Severity: DiagnosticSeverityError,
+					Summary:  "Mailform API error",
+					Detail:   "something broke",
+				},
+			},
+		},
+		{
+			name:  "EnsureOtherErrorsPassThrough",
+			input: errors.New("connection refused"),
+			expected: Diagnostics{
+				{
+					Severity: DiagnosticSeverityError,
+					Summary:  "Mailform request failed",
+					Detail:   "connection refused",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := ErrorDiagnostics(test.input)
+			assert.Equal(t, test.expected, actual)
+			assert.Equal(t, test.input != nil, actual.HasError())
+		})
+	}
+}
+
+func TestAttributePathCoversFormData(t *testing.T) {
+	formData := (&OrderInput{
+		URL:         "some_url",
+		BankAccount: "some_bank_account",
+		Amount:      1,
+		CheckName:   "some_check_name",
+		CheckNumber: 1,
+		CheckMemo:   "some_memo",
+	}).FormData()
+
+	paths := map[string]bool{}
+	for _, path := range attributePaths {
+		paths[path] = true
+	}
+
+	// Every form data key should have a field mapped onto it
+	for key := range formData {
+		assert.True(t, paths[key], key)
+	}
+	assert.Equal(t, "to.postcode", AttributePath("ToPostcode"))
+	assert.Equal(t, "", AttributePath("Unknown"))
+}
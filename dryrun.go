@@ -0,0 +1,208 @@
+package mailform
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// DryRunOrderIDPrefix is the prefix of order IDs synthesized in dry run mode.
+	DryRunOrderIDPrefix = "dryrun_"
+	// redacted replaces secrets in requests that are handed back to callers
+	redacted = "REDACTED"
+)
+
+// PreparedRequest is an HTTP request the client would send to mailform.
+type PreparedRequest struct {
+	Method string
+	URL    string
+	// Header is the request headers. The API token in the Authorization header is redacted.
+	Header http.Header
+	Body   []byte
+}
+
+// prepareOrder builds the request that creating an order would send.
+// Like the real request, the body is multipart when a file is uploaded and url encoded otherwise.
+// Form fields are written in sorted order so the same input always produces the same body.
+func (c *Client) prepareOrder(o OrderInput) (*PreparedRequest, error) {
+	formData := o.FormData()
+	keys := make([]string, 0, len(formData))
+	for k := range formData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	prepared := &PreparedRequest{
+		Method: http.MethodPost,
+		URL:    strings.TrimRight(c.restClient.BaseURL, "/") + ordersEndpoint,
+		Header: http.Header{},
+	}
+	if c.restClient.Token != "" {
+		prepared.Header.Set("Authorization", "Bearer "+redacted)
+	}
+
+	document, name, err := o.document()
+	if err != nil {
+		return nil, err
+	}
+
+	if document == nil {
+		values := url.Values{}
+		for _, k := range keys {
+			values.Set(k, formData[k])
+		}
+		prepared.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		prepared.Body = []byte(values.Encode())
+		return prepared, nil
+	}
+
+	file, err := io.ReadAll(document)
+	document.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+
+	// Derive the boundary from the content so it's deterministic too
+	hash := sha256.New()
+	for _, k := range keys {
+		hash.Write([]byte(k + "=" + formData[k] + "\n"))
+	}
+	hash.Write(file)
+	err = w.SetBoundary(hex.EncodeToString(hash.Sum(nil))[:30])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range keys {
+		err = w.WriteField(k, formData[k])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Content type is sniffed from the first 512 bytes the same way resty does it
+	sniff := make([]byte, 512)
+	copy(sniff, file)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, name))
+	header.Set("Content-Type", http.DetectContentType(sniff))
+
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+	_, err = part.Write(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	prepared.Header.Set("Content-Type", w.FormDataContentType())
+	prepared.Body = body.Bytes()
+
+	return prepared, nil
+}
+
+// dryRunLineItem mirrors the JSON of an order line item so one can be synthesized.
+type dryRunLineItem struct {
+	ID      string        `json:"id"`
+	To      dryRunAddress `json:"to"`
+	From    dryRunAddress `json:"from"`
+	Simplex bool          `json:"simplex"`
+	Color   bool          `json:"color"`
+	Service string        `json:"service"`
+	Pricing []Price       `json:"pricing"`
+}
+
+// dryRunAddress mirrors the JSON of an order line item address.
+type dryRunAddress struct {
+	Name         string `json:"name"`
+	Organization string `json:"organization"`
+	Address1     string `json:"address1"`
+	Address2     string `json:"address2"`
+	City         string `json:"city"`
+	State        string `json:"state"`
+	Postcode     string `json:"postcode"`
+	Country      string `json:"country"`
+}
+
+// dryRunOrder synthesizes the order mailform would return without sending anything.
+// The order ID is derived from the request body so the same input always gets the same ID.
+func (c *Client) dryRunOrder(o OrderInput) (*Order, error) {
+	prepared, err := c.prepareOrder(o)
+	if err != nil {
+		return &Order{}, err
+	}
+
+	sum := sha256.Sum256(prepared.Body)
+	id := DryRunOrderIDPrefix + hex.EncodeToString(sum[:])[:24]
+
+	order := &Order{
+		Success:       true,
+		DryRunRequest: prepared,
+	}
+	order.Data.Object = "order"
+	order.Data.ID = id
+	order.Data.Webhook = o.Webhook
+	order.Data.CustomerReference = o.CustomerReference
+	order.Data.TestMode = true
+	order.Data.State = StatusQueued
+
+	lineitem := dryRunLineItem{
+		ID: id + "_1",
+		To: dryRunAddress{
+			Name:         o.ToName,
+			Organization: o.ToOrganization,
+			Address1:     o.ToAddress1,
+			Address2:     o.ToAddress2,
+			City:         o.ToCity,
+			State:        o.ToState,
+			Postcode:     o.ToPostcode,
+			Country:      o.ToCountry,
+		},
+		From: dryRunAddress{
+			Name:         o.FromName,
+			Organization: o.FromOrganization,
+			Address1:     o.FromAddress1,
+			Address2:     o.FromAddress2,
+			City:         o.FromCity,
+			State:        o.FromState,
+			Postcode:     o.FromPostcode,
+			Country:      o.FromCountry,
+		},
+		Simplex: o.Simplex,
+		Color:   o.Color,
+		Service: o.Service,
+		Pricing: []Price{},
+	}
+
+	// Line items are anonymous structs so go through JSON to fill one in
+	data, err := json.Marshal([]dryRunLineItem{lineitem})
+	if err != nil {
+		return order, err
+	}
+
+	err = json.Unmarshal(data, &order.Data.Lineitems)
+	if err != nil {
+		return order, err
+	}
+
+	return order, nil
+}
@@ -0,0 +1,99 @@
+package mailform
+
+import (
+	"bytes"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateOrderDryRun(t *testing.T) {
+	mailformClient, err := New(&Config{
+		Token:  "someToken",
+		DryRun: true,
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	input := testOrderInput()
+	input.CustomerReference = "some_customer_reference"
+
+	order, err := mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+	// Ensure nothing was sent
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+
+	assert.True(t, order.Data.TestMode)
+	assert.Contains(t, order.Data.ID, DryRunOrderIDPrefix)
+	assert.Equal(t, "some_customer_reference", order.Data.CustomerReference)
+	assert.Len(t, order.Data.Lineitems, 1)
+	assert.Equal(t, "some_name", order.Data.Lineitems[0].To.Name)
+	assert.Equal(t, "some_fromcity", order.Data.Lineitems[0].From.City)
+
+	// Ensure the request is what would have been sent
+	req := order.DryRunRequest
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, DefaultBaseURL+ordersEndpoint, req.URL)
+	assert.Equal(t, "Bearer "+redacted, req.Header.Get("Authorization"))
+	values, err := url.ParseQuery(string(req.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, "some_name", values.Get("to.name"))
+	assert.Equal(t, "USPS_STANDARD", values.Get("service"))
+
+	// Ensure IDs are deterministic
+	again, err := mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+	assert.Equal(t, order.Data.ID, again.Data.ID)
+
+	input.ToName = "someone_else"
+	other, err := mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+	assert.NotEqual(t, order.Data.ID, other.Data.ID)
+}
+
+func TestCreateOrderDryRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sample.pdf")
+	err := os.WriteFile(path, []byte("%PDF-1.4 some_pdf"), 0o600)
+	assert.NoError(t, err)
+
+	mailformClient, err := New(&Config{
+		DryRun: true,
+	})
+	assert.NoError(t, err)
+
+	input := testOrderInput()
+	input.FilePath = path
+
+	order, err := mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(order.DryRunRequest.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/form-data", mediaType)
+
+	form, err := multipart.NewReader(bytes.NewReader(order.DryRunRequest.Body), params["boundary"]).ReadForm(1024 * 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"some_name"}, form.Value["to.name"])
+	assert.Len(t, form.File["file"], 1)
+	assert.Equal(t, "sample.pdf", form.File["file"][0].Filename)
+	assert.Equal(t, "application/pdf", form.File["file"][0].Header.Get("Content-Type"))
+
+	// Missing files fail like they would for real
+	input.FilePath = filepath.Join(t.TempDir(), "missing.pdf")
+	_, err = mailformClient.CreateOrder(input)
+	assert.Error(t, err)
+
+	// Invalid input is still rejected
+	_, err = mailformClient.CreateOrder(OrderInput{})
+	invalidErr := &ErrOrderInvalid{}
+	assert.ErrorAs(t, err, &invalidErr)
+}
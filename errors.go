@@ -0,0 +1,128 @@
+package mailform
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnauthorized is matched by mailform errors caused by a missing or invalid API token.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrInsufficientFunds is matched by mailform errors caused by the account not having enough funds for the order.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrNoFileUploaded is matched by mailform errors caused by an order without a file or URL.
+	ErrNoFileUploaded = errors.New("no file uploaded")
+	// ErrOrderNotFound is matched by mailform errors caused by an order ID that doesn't exist.
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrRateLimited is matched by mailform errors caused by sending too many requests.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServer is matched by mailform errors caused by a problem on mailform's side.
+	ErrServer = errors.New("mailform server error")
+)
+
+// errorRule maps a mailform error onto a sentinel error.
+type errorRule struct {
+	sentinel error
+	// codes are the error codes or HTTP status codes that match
+	codes []string
+	// phrases are matched case insensitively against the error message and detail
+	phrases []string
+	// orderRequest only applies the rule to requests for a single order, such as getting or cancelling one
+	orderRequest bool
+}
+
+// errorRules are checked in order, the first match wins.
+var errorRules = []errorRule{
+	{
+		sentinel: ErrUnauthorized,
+		codes:    []string{"401", "403"},
+		phrases:  []string{"unauthorized", "invalid_token", "invalid api key"},
+	},
+	{
+		sentinel: ErrRateLimited,
+		codes:    []string{"429"},
+		phrases:  []string{"too_many_requests", "too many requests", "rate limit"},
+	},
+	{
+		sentinel: ErrInsufficientFunds,
+		codes:    []string{"402"},
+		phrases:  []string{"not enough funds", "insufficient funds", "insufficient_funds"},
+	},
+	{
+		sentinel: ErrNoFileUploaded,
+		phrases:  []string{"no_file_uploaded", "no file uploaded"},
+	},
+	{
+		sentinel: ErrOrderNotFound,
+		phrases:  []string{"order not found", "order_not_found"},
+	},
+	// Other things can be missing when creating an order, like a bank account, so a bare not found only means the order
+	// when asking for one
+	{
+		sentinel:     ErrOrderNotFound,
+		codes:        []string{"404"},
+		phrases:      []string{"not found", "not_found"},
+		orderRequest: true,
+	},
+}
+
+// ClassifyError returns the sentinel error that err matches, or nil if it isn't a known mailform error.
+func ClassifyError(err error) error {
+	mailformErr := &ErrMailform{}
+	if !errors.As(err, &mailformErr) {
+		return nil
+	}
+
+	return mailformErr.classify()
+}
+
+// classify maps the error code, message and detail onto a sentinel error.
+func (e *ErrMailform) classify() error {
+	text := strings.ToLower(e.Err.Message + "\n" + e.Detail)
+	status := strconv.Itoa(e.StatusCode)
+
+	for _, rule := range errorRules {
+		if rule.orderRequest && !strings.HasPrefix(e.Endpoint, ordersEndpoint+"/") {
+			continue
+		}
+		for _, code := range rule.codes {
+			if e.Err.Code == code || status == code {
+				return rule.sentinel
+			}
+		}
+		for _, phrase := range rule.phrases {
+			if strings.Contains(text, phrase) {
+				return rule.sentinel
+			}
+		}
+	}
+
+	if e.StatusCode >= 500 && e.StatusCode <= 599 {
+		return ErrServer
+	}
+	if code, err := strconv.Atoi(e.Err.Code); err == nil && code >= 500 && code <= 599 {
+		return ErrServer
+	}
+
+	return nil
+}
+
+// Is reports whether the error matches one of the sentinel errors such as ErrInsufficientFunds.
+func (e *ErrMailform) Is(target error) bool {
+	sentinel := e.classify()
+
+	return sentinel != nil && sentinel == target
+}
+
+// Retryable returns true if sending the same request again later could succeed.
+func (e *ErrMailform) Retryable() bool {
+	sentinel := e.classify()
+
+	return sentinel == ErrRateLimited || sentinel == ErrServer
+}
+
+// Temporary returns true if the error is temporary. It is the same as Retryable.
+func (e *ErrMailform) Temporary() bool {
+	return e.Retryable()
+}
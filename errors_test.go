@@ -0,0 +1,159 @@
+package mailform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name              string
+		input             string
+		expected          error
+		expectedRetryable bool
+	}{
+		{
+			name:     "EnsureUnauthorizedByCode",
+			input:    `{"error":{"code":"401","message":"unauthorized"}}`,
+			expected: ErrUnauthorized,
+		},
+		{
+			name:     "EnsureInsufficientFundsByDetail",
+			input:    `{"error":{"code":"erroroccurred","message":"unknown_error"},"detail":"Error: Not enough funds (2274:0)"}`,
+			expected: ErrInsufficientFunds,
+		},
+		{
+			name:     "EnsureNoFileUploadedByMessage",
+			input:    `{"error":{"code":"erroroccurred","message":"no_file_uploaded"}}`,
+			expected: ErrNoFileUploaded,
+		},
+		{
+			name:     "EnsureOrderNotFoundByMessage",
+			input:    `{"error":{"code":"erroroccurred","message":"Order not found"}}`,
+			expected: ErrOrderNotFound,
+		},
+		{
+			name:  "EnsureOtherNotFoundIsNil",
+			input: `{"error":{"code":"404","message":"Bank account not found"}}`,
+		},
+		{
+			name:              "EnsureRateLimitedByCode",
+			input:             `{"error":{"code":"429","message":"slow down"}}`,
+			expected:          ErrRateLimited,
+			expectedRetryable: true,
+		},
+		{
+			name:              "EnsureServerErrorByCode",
+			input:             `{"error":{"code":"502","message":"bad gateway"}}`,
+			expected:          ErrServer,
+			expectedRetryable: true,
+		},
+		{
+			name:  "EnsureUnknownErrorIsNil",
+			input: `{"error":{"code":"erroroccurred","message":"unknown_error"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mailformErr := &ErrMailform{}
+			assert.NoError(t, json.Unmarshal([]byte(test.input), mailformErr))
+
+			// Ensure it works through wrapping too
+			err := fmt.Errorf("wrapped: %w", mailformErr)
+			assert.Equal(t, test.expected, ClassifyError(err))
+			if test.expected != nil {
+				assert.ErrorIs(t, err, test.expected)
+			}
+			assert.False(t, errors.Is(err, errors.New("unauthorized")))
+			assert.Equal(t, test.expectedRetryable, mailformErr.Retryable())
+			assert.Equal(t, test.expectedRetryable, mailformErr.Temporary())
+		})
+	}
+}
+
+func TestClassifyErrorNotFound(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		endpoint string
+		expected error
+	}{
+		{name: "EnsureGetOrderIsNotFound", method: "GET", endpoint: ordersEndpoint + "/someID", expected: ErrOrderNotFound},
+		{name: "EnsureCancelOrderIsNotFound", method: "POST", endpoint: ordersEndpoint + "/someID/cancel", expected: ErrOrderNotFound},
+		{name: "EnsureCreateOrderIsNotOrderNotFound", method: "POST", endpoint: ordersEndpoint},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mailformErr := &ErrMailform{StatusCode: 404, Method: test.method, Endpoint: test.endpoint}
+			mailformErr.Err.Message = "Bank account not found"
+			assert.Equal(t, test.expected, ClassifyError(mailformErr))
+		})
+	}
+}
+
+func TestIsOrderRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    error
+		expected bool
+	}{
+		{
+			name:     "EnsureInvalidOrderIsRejected",
+			input:    &ErrOrderInvalid{message: "some_message"},
+			expected: true,
+		},
+		{
+			name:     "EnsureUndeliverableAddressIsRejected",
+			input:    fmt.Errorf("%w: recipient address: no city", ErrAddressUndeliverable),
+			expected: true,
+		},
+		{
+			name:     "EnsureUsedCheckNumberIsRejected",
+			input:    fmt.Errorf("%w: check number 1001 on bank account acct_1", ErrCheckNumberUsed),
+			expected: true,
+		},
+		{
+			name:     "EnsureApprovalRequiredIsRejected",
+			input:    &ErrApprovalRequired{},
+			expected: true,
+		},
+		{
+			name:     "EnsureSuppressedRecipientIsRejected",
+			input:    &ErrRecipientSuppressed{},
+			expected: true,
+		},
+		{
+			name:     "EnsureUnknownMailformErrorIsRejected",
+			input:    &ErrMailform{Detail: "bad address"},
+			expected: true,
+		},
+		{
+			name:  "EnsureInsufficientFundsIsNotRejected",
+			input: &ErrMailform{Detail: "Error: Not enough funds (2274:0)"},
+		},
+		{
+			name: "EnsureRateLimitIsNotRejected",
+			input: func() error {
+				err := &ErrMailform{}
+				err.Err.Code = "429"
+				return err
+			}(),
+		},
+		{
+			name:  "EnsureTransportErrorIsNotRejected",
+			input: errors.New("connection refused"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isOrderRejected(test.input))
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package mailform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// RequestInfo is a sanitized view of a request sent to mailform.
+// It never includes the API token or the contents of the uploaded file.
+type RequestInfo struct {
+	Method   string
+	Endpoint string
+	// Form is the form data sent, as returned by OrderInput.FormData
+	Form map[string]string
+	// FileName is the base name of the uploaded file, if any
+	FileName string
+	// FileSize is the size of the uploaded file in bytes
+	FileSize int64
+	// FileSHA256 is the hex encoded SHA-256 checksum of the uploaded file
+	FileSHA256 string
+	Sent       time.Time
+}
+
+// ResponseInfo is a view of a response received from mailform.
+type ResponseInfo struct {
+	Request    *RequestInfo
+	StatusCode int
+	Header     http.Header
+	// Body is the raw response body
+	Body []byte
+	// Err is the mailform error the response was turned into, if any
+	Err      *ErrMailform
+	Duration time.Duration
+}
+
+// RequestHook is called with every request before it is sent to mailform.
+type RequestHook func(*RequestInfo)
+
+// ResponseHook is called with every response received from mailform.
+// It isn't called when no response is received at all, such as a network error.
+type ResponseHook func(*ResponseInfo)
+
+// fileInfo adds the name, size and checksum of the order input's document to the request info.
+func (r *RequestInfo) fileInfo(o OrderInput) error {
+	document, name, err := o.document()
+	if err != nil || document == nil {
+		return err
+	}
+	defer document.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, document)
+	if err != nil {
+		return err
+	}
+
+	r.FileName = name
+	r.FileSize = size
+	r.FileSHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	return nil
+}
+
+// requestHook passes request info to the request hook if one is configured.
+func (c *Client) requestHook(info *RequestInfo) {
+	info.Sent = time.Now()
+
+	if c.onRequest != nil {
+		c.onRequest(info)
+	}
+}
+
+// responseHook passes response info to the response hook if one is configured.
+// err is the error the response was turned into.
+func (c *Client) responseHook(info *RequestInfo, resp *resty.Response, err error) {
+	if c.onResponse == nil || resp == nil || resp.RawResponse == nil {
+		return
+	}
+
+	respInfo := &ResponseInfo{
+		Request:    info,
+		StatusCode: resp.StatusCode(),
+		Header:     resp.Header().Clone(),
+		Body:       resp.Body(),
+		Duration:   time.Since(info.Sent),
+	}
+
+	mailformErr := &ErrMailform{}
+	if errors.As(err, &mailformErr) {
+		respInfo.Err = mailformErr
+	}
+
+	c.onResponse(respInfo)
+}
@@ -0,0 +1,88 @@
+package mailform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooksCreateOrder(t *testing.T) {
+	fakeEndpoint := fmt.Sprintf("%s%s", DefaultBaseURL, ordersEndpoint)
+	content := []byte("%PDF-1.4 some_pdf")
+	path := filepath.Join(t.TempDir(), "sample.pdf")
+	err := os.WriteFile(path, content, 0o600)
+	assert.NoError(t, err)
+
+	var requests []*RequestInfo
+	var responses []*ResponseInfo
+	mailformClient, err := New(&Config{
+		Token: "someToken",
+		OnRequest: func(r *RequestInfo) {
+			requests = append(requests, r)
+		},
+		OnResponse: func(r *ResponseInfo) {
+			responses = append(responses, r)
+		},
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	response := `{"error":{"code":"erroroccurred","message":"no_file_uploaded"}}`
+	httpmock.RegisterResponder(http.MethodPost, fakeEndpoint, jsonResponder(400, response))
+
+	input := testOrderInput()
+	input.FilePath = path
+	_, err = mailformClient.CreateOrder(input)
+	assert.Error(t, err)
+
+	sum := sha256.Sum256(content)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, http.MethodPost, requests[0].Method)
+	assert.Equal(t, ordersEndpoint, requests[0].Endpoint)
+	assert.Equal(t, "some_name", requests[0].Form["to.name"])
+	assert.Equal(t, "sample.pdf", requests[0].FileName)
+	assert.Equal(t, int64(len(content)), requests[0].FileSize)
+	assert.Equal(t, hex.EncodeToString(sum[:]), requests[0].FileSHA256)
+
+	assert.Len(t, responses, 1)
+	assert.Equal(t, requests[0], responses[0].Request)
+	assert.Equal(t, 400, responses[0].StatusCode)
+	assert.Equal(t, "application/json", responses[0].Header.Get("Content-Type"))
+	assert.Equal(t, response, string(responses[0].Body))
+	assert.Equal(t, "no_file_uploaded", responses[0].Err.Err.Message)
+}
+
+func TestHooksGetOrder(t *testing.T) {
+	fakeOrderID := "someID"
+	fakeEndpoint := fmt.Sprintf("%s%s/%s", DefaultBaseURL, ordersEndpoint, fakeOrderID)
+
+	var responses []*ResponseInfo
+	mailformClient, err := New(&Config{
+		OnResponse: func(r *ResponseInfo) {
+			responses = append(responses, r)
+		},
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder(http.MethodGet, fakeEndpoint, jsonResponder(200, `{"success":true,"data":{"id":"someID"}}`))
+
+	_, err = mailformClient.GetOrder(fakeOrderID)
+	assert.NoError(t, err)
+
+	assert.Len(t, responses, 1)
+	assert.Equal(t, 200, responses[0].StatusCode)
+	assert.Equal(t, http.MethodGet, responses[0].Request.Method)
+	assert.Nil(t, responses[0].Err)
+}
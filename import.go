@@ -0,0 +1,267 @@
+package mailform
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ImportOptions configures how rows are imported into order inputs.
+type ImportOptions struct {
+	// Mapping maps column names to form data keys, such as "Street" to "to.address1".
+	// Columns that aren't in the mapping are matched to form data keys by name, ignoring case.
+	// Map a column to an empty string to ignore it.
+	Mapping map[string]string
+	// Defaults are the fields shared by every row, such as the sender, service and document.
+	// Empty cells keep the default. A default File is read once and every row gets its own reader of it.
+	Defaults OrderInput
+	// IgnoreUnknownColumns ignores columns that don't map to a form data key instead of failing the import.
+	IgnoreUnknownColumns bool
+}
+
+// ImportRowError is a problem with a single imported row.
+type ImportRowError struct {
+	// Row is the line number of the row in the file, the CSV header is row 1
+	Row int
+	Err error
+}
+
+// Error returns the error message with the row number.
+func (e *ImportRowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+}
+
+// Unwrap returns the underlying error, such as an *ErrOrderInvalid.
+func (e *ImportRowError) Unwrap() error {
+	return e.Err
+}
+
+// ImportErrors is every row that failed to import.
+type ImportErrors []*ImportRowError
+
+// Error returns the error message of every row.
+func (e ImportErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, rowErr := range e {
+		messages = append(messages, rowErr.Error())
+	}
+
+	return fmt.Sprintf("%d invalid rows: %s", len(e), strings.Join(messages, "; "))
+}
+
+// importer turns rows of column values into validated order inputs.
+type importer struct {
+	opts *ImportOptions
+	// file is the buffered default document, if any
+	file    []byte
+	inputs  []OrderInput
+	rowErrs ImportErrors
+}
+
+// newImporter returns an importer, nil options use the defaults.
+func newImporter(opts *ImportOptions) (*importer, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	i := &importer{
+		opts:    opts,
+		inputs:  []OrderInput{},
+		rowErrs: ImportErrors{},
+	}
+
+	// Readers can only be read once, so buffer the document for every row to share
+	if opts.Defaults.File != nil {
+		file, err := io.ReadAll(opts.Defaults.File)
+		if err != nil {
+			return nil, fmt.Errorf("reading default file: %w", err)
+		}
+		i.file = file
+	}
+
+	return i, nil
+}
+
+// formKey returns the form data key of a column and whether the column should be imported.
+func (i *importer) formKey(column string) (string, bool, error) {
+	column = strings.TrimSpace(column)
+
+	if key, ok := i.opts.Mapping[column]; ok {
+		return key, key != "", nil
+	}
+
+	for _, key := range FormKeys {
+		if strings.EqualFold(column, key) {
+			return key, true, nil
+		}
+	}
+
+	if i.opts.IgnoreUnknownColumns {
+		return "", false, nil
+	}
+
+	return "", false, fmt.Errorf("column '%s' does not map to a form data key", column)
+}
+
+// add builds an order input from the defaults and the row's values and validates it.
+// values are keyed by form data key, in column order.
+func (i *importer) add(row int, keys []string, values []string) {
+	input := i.opts.Defaults
+	if input.File != nil {
+		input.File = bytes.NewReader(i.file)
+	}
+
+	for n, key := range keys {
+		value := strings.TrimSpace(values[n])
+		if key == "" || value == "" {
+			continue
+		}
+
+		err := input.SetFormValue(key, value)
+		if err != nil {
+			i.rowErrs = append(i.rowErrs, &ImportRowError{Row: row, Err: err})
+			return
+		}
+	}
+
+	err := input.Validate()
+	if err != nil {
+		i.rowErrs = append(i.rowErrs, &ImportRowError{Row: row, Err: err})
+		return
+	}
+
+	i.inputs = append(i.inputs, input)
+}
+
+// result returns the valid order inputs and the row errors, if any.
+func (i *importer) result() ([]OrderInput, error) {
+	if len(i.rowErrs) > 0 {
+		return i.inputs, i.rowErrs
+	}
+
+	return i.inputs, nil
+}
+
+// ImportCSV reads order inputs from CSV with a header row.
+// Every row is validated, the valid order inputs are returned along with ImportErrors listing every invalid row.
+// Other errors, such as an unknown column or malformed CSV, stop the import.
+func ImportCSV(r io.Reader, opts *ImportOptions) ([]OrderInput, error) {
+	i, err := newImporter(opts)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return i.result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(header))
+	for n, column := range header {
+		// Spreadsheets like to start their exports with a byte order mark
+		if n == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+
+		key, ok, err := i.formKey(column)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			keys[n] = key
+		}
+	}
+
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		parseErr := &csv.ParseError{}
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			i.rowErrs = append(i.rowErrs, &ImportRowError{Row: parseErr.StartLine, Err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row, _ := reader.FieldPos(0)
+		i.add(row, keys, values)
+	}
+
+	return i.result()
+}
+
+// ImportJSONL reads order inputs from JSON lines, one object per line keyed by column name.
+// Blank lines are skipped. Every row is validated, the valid order inputs are returned along with
+// ImportErrors listing every invalid row. Other errors, such as an unknown column, stop the import.
+func ImportJSONL(r io.Reader, opts *ImportOptions) ([]OrderInput, error) {
+	i, err := newImporter(opts)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	row := 0
+	for scanner.Scan() {
+		row++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		fields := map[string]interface{}{}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		// Keep numbers like postcodes exactly as written
+		decoder.UseNumber()
+		err := decoder.Decode(&fields)
+		if err != nil {
+			i.rowErrs = append(i.rowErrs, &ImportRowError{Row: row, Err: err})
+			continue
+		}
+
+		// Sort the columns so rows are imported the same way every time
+		columns := make([]string, 0, len(fields))
+		for column := range fields {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		keys := []string{}
+		values := []string{}
+		for _, column := range columns {
+			value := fields[column]
+			key, ok, err := i.formKey(column)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", row, err)
+			}
+			if !ok || value == nil {
+				continue
+			}
+
+			keys = append(keys, key)
+			values = append(values, fmt.Sprint(value))
+		}
+
+		i.add(row, keys, values)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return i.result()
+}
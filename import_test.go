@@ -0,0 +1,233 @@
+package mailform
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testImportDefaults returns import options with the sender and service shared by every row.
+func testImportDefaults() *ImportOptions {
+	defaults := testOrderInput()
+	defaults.URL = "some_url"
+	defaults.ToName = ""
+	defaults.ToAddress1 = ""
+	defaults.ToCity = ""
+	defaults.ToState = ""
+	defaults.ToPostcode = ""
+	defaults.ToCountry = ""
+
+	return &ImportOptions{
+		Defaults: defaults,
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	type test struct {
+		description     string
+		input           string
+		opts            *ImportOptions
+		expectedInputs  []OrderInput
+		expectedRowErrs map[int]string
+		expectedErr     string
+	}
+
+	expected := testOrderInput()
+	expected.URL = "some_url"
+
+	expectedColor := expected
+	expectedColor.Color = true
+
+	mapping := testImportDefaults()
+	mapping.Mapping = map[string]string{
+		"Name":   "to.name",
+		"Street": "to.address1",
+		"Notes":  "",
+	}
+
+	ignore := testImportDefaults()
+	ignore.IgnoreUnknownColumns = true
+
+	tests := []test{
+		{
+			description: "Form data key headers",
+			input: "\ufeffto.name,to.address1,to.city,to.state,to.postcode,to.country\n" +
+				"some_name,some_address1,some_city,some_state,some_postcode,some_country\n",
+			opts:           testImportDefaults(),
+			expectedInputs: []OrderInput{expected},
+		},
+		{
+			description: "Headers are matched ignoring case and rows override defaults",
+			input: "TO.NAME,to.address1,to.city,to.state,to.postcode,to.country,color\n" +
+				"some_name,some_address1,some_city,some_state,some_postcode,some_country,true\n",
+			opts:           testImportDefaults(),
+			expectedInputs: []OrderInput{expectedColor},
+		},
+		{
+			description: "Header mapping",
+			input: "Name,Street,to.city,to.state,to.postcode,to.country,Notes\n" +
+				"some_name,some_address1,some_city,some_state,some_postcode,some_country,call first\n",
+			opts:           mapping,
+			expectedInputs: []OrderInput{expected},
+		},
+		{
+			description: "Empty cells keep the defaults",
+			input: "to.name,to.address1,to.city,to.state,to.postcode,to.country,service\n" +
+				"some_name,some_address1,some_city,some_state,some_postcode,some_country,\n",
+			opts:           testImportDefaults(),
+			expectedInputs: []OrderInput{expected},
+		},
+		{
+			description: "Invalid rows are numbered by line",
+			input: "to.name,to.address1,to.city,to.state,to.postcode,to.country,color\n" +
+				"some_name,some_address1,some_city,some_state,some_postcode,some_country,\n" +
+				",some_address1,some_city,some_state,some_postcode,some_country,\n" +
+				"some_name,some_address1,some_city,some_state,some_postcode,some_country,maybe\n" +
+				"some_name,some_address1\n",
+			opts:           testImportDefaults(),
+			expectedInputs: []OrderInput{expected},
+			expectedRowErrs: map[int]string{
+				3: "ToName",
+				4: "invalid value for color",
+				5: "wrong number of fields",
+			},
+		},
+		{
+			description: "Unknown column",
+			input:       "to.name,some_column\n",
+			opts:        testImportDefaults(),
+			expectedErr: "column 'some_column' does not map to a form data key",
+		},
+		{
+			description: "Ignore unknown columns",
+			input: "to.name,to.address1,to.city,to.state,to.postcode,to.country,some_column\n" +
+				"some_name,some_address1,some_city,some_state,some_postcode,some_country,some_value\n",
+			opts:           ignore,
+			expectedInputs: []OrderInput{expected},
+		},
+		{
+			description:    "Empty file",
+			input:          "",
+			expectedInputs: []OrderInput{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			inputs, err := ImportCSV(strings.NewReader(test.input), test.opts)
+			assertImport(t, inputs, err, test.expectedInputs, test.expectedRowErrs, test.expectedErr)
+		})
+	}
+}
+
+func TestImportJSONL(t *testing.T) {
+	type test struct {
+		description     string
+		input           string
+		opts            *ImportOptions
+		expectedInputs  []OrderInput
+		expectedRowErrs map[int]string
+		expectedErr     string
+	}
+
+	expected := testOrderInput()
+	expected.URL = "some_url"
+
+	expectedNumber := expected
+	expectedNumber.ToPostcode = "02134"
+
+	mapping := testImportDefaults()
+	mapping.Mapping = map[string]string{
+		"zip": "to.postcode",
+	}
+
+	tests := []test{
+		{
+			description: "Rows with blank lines",
+			input: `{"to.name":"some_name","to.address1":"some_address1","to.city":"some_city","to.state":"some_state","to.postcode":"some_postcode","to.country":"some_country"}` + "\n\n" +
+				`{"to.name":null,"to.address1":"some_address1","to.city":"some_city","to.state":"some_state","to.postcode":"some_postcode","to.country":"some_country"}` + "\n" +
+				`{"to.name":` + "\n",
+			opts:           testImportDefaults(),
+			expectedInputs: []OrderInput{expected},
+			expectedRowErrs: map[int]string{
+				3: "ToName",
+				4: "unexpected EOF",
+			},
+		},
+		{
+			description: "Header mapping and string form of values",
+			input: `{"to.name":"some_name","to.address1":"some_address1","to.city":"some_city","to.state":"some_state","zip":"02134","to.country":"some_country","color":false}` + "\n" +
+				`{"to.name":"some_name","to.address1":"some_address1","to.city":"some_city","to.state":"some_state","zip":"02134","to.country":"some_country","amount":1e3}`,
+			opts:           mapping,
+			expectedInputs: []OrderInput{expectedNumber},
+			expectedRowErrs: map[int]string{
+				2: "invalid value for amount",
+			},
+		},
+		{
+			description: "Unknown column",
+			input:       `{"some_column":"some_value"}`,
+			opts:        testImportDefaults(),
+			expectedErr: "row 1: column 'some_column' does not map to a form data key",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			inputs, err := ImportJSONL(strings.NewReader(test.input), test.opts)
+			assertImport(t, inputs, err, test.expectedInputs, test.expectedRowErrs, test.expectedErr)
+		})
+	}
+}
+
+// assertImport checks the result of an import.
+func assertImport(t *testing.T, inputs []OrderInput, err error, expectedInputs []OrderInput, expectedRowErrs map[int]string, expectedErr string) {
+	t.Helper()
+
+	if expectedErr != "" {
+		assert.EqualError(t, err, expectedErr)
+		assert.Nil(t, inputs)
+		return
+	}
+
+	assert.Equal(t, expectedInputs, inputs)
+
+	if len(expectedRowErrs) == 0 {
+		assert.NoError(t, err)
+		return
+	}
+
+	rowErrs := ImportErrors{}
+	assert.True(t, errors.As(err, &rowErrs))
+	assert.Len(t, rowErrs, len(expectedRowErrs))
+	for _, rowErr := range rowErrs {
+		assert.Contains(t, rowErr.Error(), expectedRowErrs[rowErr.Row], rowErr.Row)
+	}
+}
+
+func TestImportDefaultFile(t *testing.T) {
+	opts := testImportDefaults()
+	opts.Defaults.URL = ""
+	opts.Defaults.File = strings.NewReader("some_pdf")
+
+	inputs, err := ImportCSV(strings.NewReader("to.name,to.address1,to.city,to.state,to.postcode,to.country\n"+
+		"some_name,some_address1,some_city,some_state,some_postcode,some_country\n"+
+		"other_name,some_address1,some_city,some_state,some_postcode,some_country\n"), opts)
+	assert.NoError(t, err)
+	assert.Len(t, inputs, 2)
+
+	// Every row reads the whole document
+	for _, input := range inputs {
+		data, err := io.ReadAll(input.File)
+		assert.NoError(t, err)
+		assert.Equal(t, "some_pdf", string(data))
+	}
+
+	opts.Defaults.File = iotest.ErrReader(errors.New("some_error"))
+	_, err = ImportJSONL(strings.NewReader(""), opts)
+	assert.EqualError(t, err, "reading default file: some_error")
+}
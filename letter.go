@@ -0,0 +1,427 @@
+package mailform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// letterMargin is the margin around the body of a letter
+	letterMargin = 1 * pointsPerInch
+	// letterBodyTop is how far from the top of the first page the body starts, leaving room for the address window area
+	letterBodyTop = 3.5 * pointsPerInch
+	// defaultLetterFontSize is the body font size in points
+	defaultLetterFontSize = 11
+	// letterLineHeight is the line height as a multiple of the font size
+	letterLineHeight = 1.4
+	// letterBulletIndent is how far bullet text is indented from the bullet
+	letterBulletIndent = 14
+	// letterFileName is the name letters are uploaded as
+	letterFileName = "letter.pdf"
+)
+
+var (
+	// ErrAddressTooLong is returned when an address has more lines than fit in its envelope window.
+	ErrAddressTooLong = errors.New("address is too long to fit the envelope window")
+)
+
+// envelopeWindow is an area of the first page that shows through a window of a #10 double window envelope.
+// Positions are in points from the top left of the page.
+type envelopeWindow struct {
+	left     float64
+	top      float64
+	width    float64
+	height   float64
+	fontSize float64
+}
+
+var (
+	// returnWindow shows the sender's address
+	returnWindow = envelopeWindow{
+		left:     0.625 * pointsPerInch,
+		top:      0.5 * pointsPerInch,
+		width:    3.25 * pointsPerInch,
+		height:   0.875 * pointsPerInch,
+		fontSize: 8,
+	}
+	// recipientWindow shows the recipient's address
+	recipientWindow = envelopeWindow{
+		left:     0.75 * pointsPerInch,
+		top:      2 * pointsPerInch,
+		width:    4 * pointsPerInch,
+		height:   1 * pointsPerInch,
+		fontSize: 10,
+	}
+)
+
+// LetterData is what letter templates are executed with.
+// The order input's fields can be used directly, such as {{.ToName}}.
+type LetterData struct {
+	OrderInput
+	// Fields are the per recipient values passed to Render, such as {{.Fields.amount}}
+	Fields map[string]interface{}
+	// Date is when the letter was rendered
+	Date time.Time
+}
+
+// LetterBuilder renders letters from a template into US letter PDFs ready to mail.
+//
+// Templates are Go text/template executed with LetterData, written as simple markdown:
+// lines starting with # are headings, lines starting with - or * are bullets, **text** is bold
+// and blank lines separate paragraphs. Unlike markdown, every line break is kept, so signatures
+// and the like come out the way they're written.
+//
+// The sender and recipient addresses are printed on the first page where the windows of a
+// #10 double window envelope are and the body starts below them, with one inch margins all around.
+type LetterBuilder struct {
+	template *template.Template
+	clock    Clock
+	// FontSize is the body font size in points, defaults to 11
+	FontSize float64
+}
+
+// NewLetterBuilder parses a letter template. If clock is nil, the system clock is used.
+// Referring to a field that wasn't passed to Render is an error rather than printing <no value>.
+func NewLetterBuilder(text string, clock Clock) (*LetterBuilder, error) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	tmpl, err := template.New("letter").Option("missingkey=error").Funcs(template.FuncMap{
+		"money": formatMoney,
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LetterBuilder{
+		template: tmpl,
+		clock:    clock,
+	}, nil
+}
+
+// Render renders the letter for an order input and returns the PDF.
+func (b *LetterBuilder) Render(o OrderInput, fields map[string]interface{}) (io.Reader, error) {
+	text := &strings.Builder{}
+	err := b.template.Execute(text, LetterData{
+		OrderInput: o,
+		Fields:     fields,
+		Date:       b.clock.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	size := b.FontSize
+	if size <= 0 {
+		size = defaultLetterFontSize
+	}
+
+	l := &letterLayout{
+		left:   letterMargin,
+		top:    letterPageHeight - letterMargin,
+		bottom: letterMargin,
+		width:  letterPageWidth - 2*letterMargin,
+	}
+	l.newPage()
+
+	err = l.address(returnWindow, o.From().Lines())
+	if err != nil {
+		return nil, fmt.Errorf("sender %w", err)
+	}
+	err = l.address(recipientWindow, o.To().Lines())
+	if err != nil {
+		return nil, fmt.Errorf("recipient %w", err)
+	}
+
+	l.y = letterPageHeight - letterBodyTop
+	l.markdown(text.String(), size)
+
+	doc := newPDFDocument()
+	resources := "<< " + doc.addFonts(helvetica, helveticaBold) + " >>"
+	for _, page := range l.pages {
+		err = doc.addPage(letterPageWidth, letterPageHeight, resources, page.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return bytes.NewReader(doc.bytes()), nil
+}
+
+// Attach renders the letter for an order input and returns the order input with the letter as its File.
+func (b *LetterBuilder) Attach(o OrderInput, fields map[string]interface{}) (OrderInput, error) {
+	letter, err := b.Render(o, fields)
+	if err != nil {
+		return o, err
+	}
+
+	o.File = letter
+	if o.FileName == "" {
+		o.FileName = letterFileName
+	}
+
+	return o, nil
+}
+
+// formatMoney formats cents as dollars with thousands separators, such as $1,234.56.
+func formatMoney(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+
+	dollars := fmt.Sprint(cents / 100)
+	for i := len(dollars) - 3; i > 0; i -= 3 {
+		dollars = dollars[:i] + "," + dollars[i:]
+	}
+
+	return fmt.Sprintf("%s$%s.%02d", sign, dollars, cents%100)
+}
+
+// letterSpan is a run of text in a single font.
+type letterSpan struct {
+	text string
+	font *pdfFont
+}
+
+// letterLayout lays text out onto pages.
+// Positions are in points from the bottom left of the page.
+type letterLayout struct {
+	pages []*bytes.Buffer
+	// left is where lines start
+	left float64
+	// top is where the body starts on a new page
+	top float64
+	// bottom is as far down the page as lines go
+	bottom float64
+	// width is the width of the body
+	width float64
+	// y is the top of the next line
+	y float64
+}
+
+// newPage starts a new page.
+func (l *letterLayout) newPage() {
+	l.pages = append(l.pages, &bytes.Buffer{})
+	l.y = l.top
+}
+
+// text writes text with its baseline at x, y on the current page.
+func (l *letterLayout) text(x, y float64, span letterSpan, size float64) {
+	fmt.Fprintf(l.pages[len(l.pages)-1], "BT /%s %s Tf %s %s Td %s Tj ET\n",
+		span.font.name, pdfNumber(size), pdfNumber(x), pdfNumber(y), pdfString(span.text))
+}
+
+// address prints address lines in an envelope window.
+func (l *letterLayout) address(window envelopeWindow, lines []string) error {
+	leading := window.fontSize * 1.2
+	if float64(len(lines))*leading > window.height {
+		return ErrAddressTooLong
+	}
+
+	y := letterPageHeight - window.top
+	for _, line := range lines {
+		if helvetica.width(line, window.fontSize) > window.width {
+			return fmt.Errorf("%w: %s", ErrAddressTooLong, line)
+		}
+		y -= leading
+		l.text(window.left, y, letterSpan{text: line, font: helvetica}, window.fontSize)
+	}
+
+	return nil
+}
+
+// line writes a line of spans starting at indent, moving to a new page if it doesn't fit.
+// If bullet is true a bullet is written in front of the indent.
+func (l *letterLayout) line(spans []letterSpan, indent, size float64, bullet bool) {
+	leading := size * letterLineHeight
+	if l.y-leading < l.bottom {
+		l.newPage()
+	}
+
+	// Put the baseline far enough down the line for the tallest letters
+	baseline := l.y - size
+	x := l.left + indent
+	if bullet {
+		l.text(x-letterBulletIndent, baseline, letterSpan{text: "•", font: helvetica}, size)
+	}
+	for _, span := range spans {
+		l.text(x, baseline, span, size)
+		x += span.font.width(span.text, size)
+	}
+	l.y -= leading
+}
+
+// gap adds vertical space, such as between paragraphs. Gaps at the top of a page are dropped.
+func (l *letterLayout) gap(size float64) {
+	if l.y >= l.top {
+		return
+	}
+
+	l.y -= size * letterLineHeight
+	if l.y < l.bottom {
+		l.newPage()
+	}
+}
+
+// markdown lays out the rendered letter text.
+func (l *letterLayout) markdown(text string, size float64) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.Trim(text, "\n")
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t")
+		trimmed := strings.TrimLeft(line, " \t")
+
+		switch {
+		case trimmed == "":
+			l.gap(size)
+		case strings.HasPrefix(trimmed, "# "):
+			l.heading(trimmed[2:], size*1.5)
+		case strings.HasPrefix(trimmed, "## "):
+			l.heading(trimmed[3:], size*1.25)
+		case strings.HasPrefix(trimmed, "### "):
+			l.heading(trimmed[4:], size)
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			l.bullet(trimmed[2:], size)
+		default:
+			l.paragraph(line, 0, size)
+		}
+	}
+}
+
+// heading writes a bold heading.
+func (l *letterLayout) heading(text string, size float64) {
+	for _, line := range wrapSpans([]letterSpan{{text: strings.TrimSpace(text), font: helveticaBold}}, l.width, size) {
+		l.line(line, 0, size, false)
+	}
+}
+
+// bullet writes a bulleted line with a hanging indent.
+func (l *letterLayout) bullet(text string, size float64) {
+	for i, line := range wrapSpans(parseSpans(strings.TrimSpace(text)), l.width-letterBulletIndent, size) {
+		l.line(line, letterBulletIndent, size, i == 0)
+	}
+}
+
+// paragraph writes a line of text, wrapping it to fit the body.
+func (l *letterLayout) paragraph(text string, indent, size float64) {
+	// Keep the indent of the text, in spaces
+	leading := len(text) - len(strings.TrimLeft(text, " \t"))
+	indent += helvetica.width(strings.Repeat(" ", leading), size)
+
+	for _, line := range wrapSpans(parseSpans(strings.TrimSpace(text)), l.width-indent, size) {
+		l.line(line, indent, size, false)
+	}
+}
+
+// parseSpans splits text on ** into regular and bold spans. An unmatched ** is left as is.
+func parseSpans(text string) []letterSpan {
+	spans := []letterSpan{}
+	bold := false
+	for {
+		i := strings.Index(text, "**")
+		if i < 0 || (!bold && !strings.Contains(text[i+2:], "**")) {
+			break
+		}
+
+		if i > 0 {
+			spans = append(spans, letterSpan{text: text[:i], font: spanFont(bold)})
+		}
+		text = text[i+2:]
+		bold = !bold
+	}
+	if text != "" {
+		spans = append(spans, letterSpan{text: text, font: spanFont(bold)})
+	}
+
+	return spans
+}
+
+// spanFont returns the font of regular or bold text.
+func spanFont(bold bool) *pdfFont {
+	if bold {
+		return helveticaBold
+	}
+
+	return helvetica
+}
+
+// wrapSpans breaks spans into lines no wider than width. Words longer than a whole line are split.
+func wrapSpans(spans []letterSpan, width, size float64) [][]letterSpan {
+	lines := [][]letterSpan{}
+	line := []letterSpan{}
+	lineWidth := 0.0
+
+	addWord := func(word letterSpan, space bool) {
+		wordWidth := word.font.width(word.text, size)
+		spaceWidth := 0.0
+		if space && len(line) > 0 {
+			spaceWidth = word.font.width(" ", size)
+		}
+
+		if len(line) > 0 && lineWidth+spaceWidth+wordWidth > width {
+			lines = append(lines, line)
+			line = []letterSpan{}
+			lineWidth = 0
+			spaceWidth = 0
+		}
+
+		// Split words that don't fit on a line of their own
+		for len(line) == 0 && wordWidth > width {
+			runes := []rune(word.text)
+			n := 1
+			for n < len(runes) && word.font.width(string(runes[:n+1]), size) <= width {
+				n++
+			}
+			lines = append(lines, []letterSpan{{text: string(runes[:n]), font: word.font}})
+			word.text = string(runes[n:])
+			wordWidth = word.font.width(word.text, size)
+		}
+
+		if spaceWidth > 0 {
+			line = append(line, letterSpan{text: " ", font: word.font})
+		}
+		line = append(line, word)
+		lineWidth += spaceWidth + wordWidth
+	}
+
+	// Spaces at the edges of spans separate words across spans, such as "a **b**"
+	space := false
+	for _, span := range spans {
+		if strings.TrimLeft(span.text, " \t") != span.text {
+			space = true
+		}
+		for i, word := range strings.Fields(span.text) {
+			addWord(letterSpan{text: word, font: span.font}, i > 0 || space)
+		}
+		space = strings.TrimRight(span.text, " \t") != span.text
+	}
+
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+
+	// Join words in the same font so each line is written in as few pieces as possible
+	for i, line := range lines {
+		merged := []letterSpan{line[0]}
+		for _, span := range line[1:] {
+			last := &merged[len(merged)-1]
+			if span.font == last.font {
+				last.text += span.text
+				continue
+			}
+			merged = append(merged, span)
+		}
+		lines[i] = merged
+	}
+
+	return lines
+}
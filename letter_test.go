@@ -0,0 +1,218 @@
+package mailform
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// renderLetter renders a letter template for the test order input and returns the page content streams.
+func renderLetter(t *testing.T, text string, fields map[string]interface{}) []string {
+	t.Helper()
+
+	clock := &fakeClock{now: time.Date(2022, time.March, 4, 0, 0, 0, 0, time.UTC)}
+	builder, err := NewLetterBuilder(text, clock)
+	require.NoError(t, err)
+
+	letter, err := builder.Render(testOrderInput(), fields)
+	require.NoError(t, err)
+	pdf, err := io.ReadAll(letter)
+	require.NoError(t, err)
+	assertPDFXref(t, pdf)
+
+	return pdfStreams(t, pdf)
+}
+
+func TestLetterBuilderRender(t *testing.T) {
+	pages := renderLetter(t, `# Invoice
+
+Dear {{.ToName}},
+
+You owe **{{money .Fields.amount}}** as of {{.Date.Format "January 2, 2006"}}.
+- first (item)
+- second item
+
+Sincerely,
+  {{.FromName}}`, map[string]interface{}{"amount": 123456})
+	require.Len(t, pages, 1)
+	page := pages[0]
+
+	// Addresses are in the envelope windows
+	assert.Contains(t, page, "/F1 8 Tf 45 746.4 Td (some_fromname) Tj")
+	assert.Contains(t, page, "/F1 10 Tf 54 636 Td (some_name) Tj")
+	assert.Contains(t, page, "(some_city, some_state some_postcode) Tj")
+	assert.Contains(t, page, "(some_country) Tj")
+
+	// The body starts below the address window area at the left margin
+	assert.Contains(t, page, "/F2 16.5 Tf 72 523.5 Td (Invoice) Tj")
+	assert.Contains(t, page, "/F1 11 Tf 72 ")
+	assert.Contains(t, page, "(Dear some_name,) Tj")
+	assert.Contains(t, page, "/F2 11 Tf")
+	assert.Contains(t, page, "/F1 11 Tf 72 459.7 Td (You owe) Tj")
+	assert.Contains(t, page, "/F2 11 Tf 114.8 459.7 Td ( $1,234.56) Tj")
+	assert.Contains(t, page, "( as of March 4, 2022.) Tj")
+	assert.Contains(t, page, `/F1 11 Tf 72 444.3 Td (\225) Tj`)
+	assert.Contains(t, page, `/F1 11 Tf 86 444.3 Td (first \(item\)) Tj`)
+	assert.Contains(t, page, "/F1 11 Tf 78.12 382.7 Td (some_fromname) Tj")
+	assert.Contains(t, page, "(some_fromname) Tj")
+
+	// Lines are in order down the page
+	assert.Less(t, strings.Index(page, "(Invoice)"), strings.Index(page, "(Dear some_name,)"))
+	assert.Less(t, strings.Index(page, "(Sincerely,)"), strings.LastIndex(page, "(some_fromname)"))
+}
+
+func TestLetterBuilderRenderPages(t *testing.T) {
+	paragraph := strings.Repeat("All work and no play makes a dull letter. ", 20)
+	pages := renderLetter(t, strings.Repeat(paragraph+"\n\n", 10)+"The end", nil)
+	assert.Greater(t, len(pages), 2)
+	assert.Contains(t, pages[len(pages)-1], "(The end) Tj")
+
+	// Pages after the first start at the top margin
+	assert.Contains(t, pages[1], " 72 709 Td ")
+	assert.NotContains(t, pages[1], "some_fromname")
+}
+
+func TestLetterBuilderErrors(t *testing.T) {
+	_, err := NewLetterBuilder("{{.ToName", nil)
+	assert.Error(t, err)
+
+	builder, err := NewLetterBuilder("{{.Fields.missing}}", nil)
+	require.NoError(t, err)
+	_, err = builder.Render(testOrderInput(), map[string]interface{}{})
+	assert.ErrorContains(t, err, "missing")
+
+	builder, err = NewLetterBuilder("some_text", nil)
+	require.NoError(t, err)
+	input := testOrderInput()
+	input.ToAddress2 = strings.Repeat("some_address2 ", 10)
+	_, err = builder.Render(input, nil)
+	assert.ErrorIs(t, err, ErrAddressTooLong)
+}
+
+func TestWrapSpans(t *testing.T) {
+	width := 100.0
+	text := "some words and **bold words** and averyveryveryveryveryverylongword"
+	lines := wrapSpans(parseSpans(text), width, 11)
+	assert.Greater(t, len(lines), 3)
+
+	wrapped := ""
+	for _, line := range lines {
+		lineWidth := 0.0
+		for _, span := range line {
+			lineWidth += span.font.width(span.text, 11)
+			wrapped += span.text
+			if span.text == "bold" {
+				assert.Equal(t, helveticaBold, span.font)
+			}
+		}
+		assert.LessOrEqual(t, lineWidth, width)
+	}
+
+	// Nothing is lost apart from the spaces lines are broken at
+	assert.Equal(t, strings.ReplaceAll(strings.ReplaceAll(text, "**", ""), " ", ""), strings.ReplaceAll(wrapped, " ", ""))
+}
+
+func TestParseSpans(t *testing.T) {
+	assert.Equal(t, []letterSpan{
+		{text: "a ", font: helvetica},
+		{text: "b", font: helveticaBold},
+		{text: " c ** d", font: helvetica},
+	}, parseSpans("a **b** c ** d"))
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := map[int]string{
+		0:         "$0.00",
+		5:         "$0.05",
+		123456:    "$1,234.56",
+		-12345678: "-$123,456.78",
+		100000000: "$1,000,000.00",
+	}
+
+	for cents, expected := range tests {
+		assert.Equal(t, expected, formatMoney(cents))
+	}
+}
+
+func TestCreateOrderLetter(t *testing.T) {
+	fakeEndpoint := fmt.Sprintf("%s%s", DefaultBaseURL, ordersEndpoint)
+
+	var request *RequestInfo
+	mailformClient, err := New(&Config{
+		Token: "someToken",
+		OnRequest: func(r *RequestInfo) {
+			request = r
+		},
+	})
+	require.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	var uploaded []byte
+	var fileName string
+	httpmock.RegisterResponder(http.MethodPost, fakeEndpoint, func(req *http.Request) (*http.Response, error) {
+		file, header, err := req.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		fileName = header.Filename
+		uploaded, err = io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		return jsonResponder(200, `{"success":true,"data":{"id":"some_id"}}`)(req)
+	})
+
+	builder, err := NewLetterBuilder("Dear {{.ToName}}", nil)
+	require.NoError(t, err)
+	input, err := builder.Attach(testOrderInput(), nil)
+	require.NoError(t, err)
+
+	order, err := mailformClient.CreateOrder(input)
+	require.NoError(t, err)
+	assert.Equal(t, "some_id", order.Data.ID)
+	assert.Equal(t, letterFileName, fileName)
+	assert.True(t, strings.HasPrefix(string(uploaded), "%PDF-1.4"))
+
+	// The hook saw the same document that was uploaded
+	assert.Equal(t, letterFileName, request.FileName)
+	assert.Equal(t, int64(len(uploaded)), request.FileSize)
+
+	// Readers can't be persisted
+	_, err = (&Outbox{}).Enqueue(input)
+	assert.ErrorIs(t, err, ErrFileNotPersisted)
+	_, err = (&Scheduler{}).Schedule(input, time.Now())
+	assert.ErrorIs(t, err, ErrFileNotPersisted)
+}
+
+func TestCreateOrderDryRunLetter(t *testing.T) {
+	mailformClient, err := New(&Config{
+		DryRun: true,
+	})
+	require.NoError(t, err)
+
+	builder, err := NewLetterBuilder("Dear {{.ToName}}", &fakeClock{})
+	require.NoError(t, err)
+
+	ids := []string{}
+	for i := 0; i < 2; i++ {
+		input, err := builder.Attach(testOrderInput(), nil)
+		require.NoError(t, err)
+		order, err := mailformClient.CreateOrder(input)
+		require.NoError(t, err)
+		assert.Contains(t, string(order.DryRunRequest.Body), `filename="letter.pdf"`)
+		ids = append(ids, order.Data.ID)
+	}
+
+	// Letters are deterministic, so dry run IDs are too
+	assert.Equal(t, ids[0], ids[1])
+}
@@ -3,6 +3,8 @@ package mailform
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -12,6 +14,8 @@ const (
 	// DefaultBaseURL is the default mailform API base url, but is can be overwritten via Config
 	DefaultBaseURL = "https://www.mailform.io/app/api/v1"
 	DefaultTimeout = time.Second * 15
+	// MaxErrBodySize is the most of a response body that is kept on an ErrMailform
+	MaxErrBodySize = 4096
 	// Order Statuses
 	StatusCancelled           = "cancelled"
 	StatusQueued              = "queued"
@@ -22,11 +26,26 @@ const (
 var (
 	// ErrNilConfig is returned when a nil config is being passed to New().
 	ErrNilConfig = errors.New("config cannot be nil")
+	// requestIDHeaders are the response headers checked for a request ID, in order
+	requestIDHeaders = []string{"X-Request-Id", "X-Amzn-Requestid", "Cf-Ray"}
 )
 
 // Client is the mailform REST API client.
 type Client struct {
-	restClient *resty.Client
+	restClient   *resty.Client
+	limiter      *rateLimiter
+	budget       *BudgetGuard
+	dryRun       bool
+	onRequest    RequestHook
+	onResponse   ResponseHook
+	audit        *AuditLog
+	normalize    bool
+	strictness   AddressStrictness
+	verify       *VerificationPolicy
+	suppress     *SuppressionList
+	checkNumbers *CheckNumbers
+	checkLedger  *CheckLedger
+	approvals    *ApprovalGate
 }
 
 // Config is the configuration used to communicate with the mailform API.
@@ -34,6 +53,39 @@ type Config struct {
 	Token   string
 	BaseURL string
 	Timeout time.Duration
+	// RateLimit throttles requests client side before they are sent.
+	// When set, 429 responses also pause the client for the duration of the Retry-After header.
+	RateLimit *RateLimit
+	// Budget refuses to create orders that would cross spend or order count caps.
+	Budget *BudgetGuard
+	// DryRun validates orders and builds the request that would be sent, but never calls mailform.
+	// CreateOrder returns a synthesized test mode order with the request attached as DryRunRequest.
+	DryRun bool
+	// OnRequest is called with a sanitized view of every request before it is sent.
+	OnRequest RequestHook
+	// OnResponse is called with every response received, including the error it was turned into.
+	OnResponse ResponseHook
+	// Audit records every order created in a tamper-evident audit log.
+	Audit *AuditLog
+	// NormalizeAddresses normalizes the recipient and sender addresses of every order to USPS Publication 28 conventions
+	// before it's validated and sent. The changes made are attached to the order as AddressChanges.
+	NormalizeAddresses bool
+	// AddressStrictness checks the states and ZIP codes of US addresses before orders are sent.
+	// Problems are attached to the order as Warnings, or stop the order at AddressStrictnessStrict. Off by default.
+	AddressStrictness AddressStrictness
+	// Verification verifies addresses are deliverable before orders are sent, correcting, rejecting
+	// or warning about them by the policy's mode. Warnings are attached to the order.
+	Verification *VerificationPolicy
+	// Suppression stops orders to recipients on the suppression list with an *ErrRecipientSuppressed.
+	Suppression *SuppressionList
+	// CheckNumbers stops orders whose check number has already been used on the bank account with ErrCheckNumberUsed.
+	// Numbers of orders that fail to be created are released so they can be used again.
+	CheckNumbers *CheckNumbers
+	// CheckLedger records every order created with a check in a check register.
+	CheckLedger *CheckLedger
+	// Approvals holds orders that match its rules as pending approvals instead of sending them, returning an *ErrApprovalRequired.
+	// Orders that match need a requester set with WithAuditActor. Approved orders are sent with SubmitApproved.
+	Approvals *ApprovalGate
 }
 
 // ErrMailform is the error returned when mailform responds with an error.
@@ -43,6 +95,18 @@ type ErrMailform struct {
 		Message string `json:"message"`
 	} `json:"error"`
 	Detail string `json:"detail"`
+	// StatusCode is the HTTP status code of the response
+	StatusCode int `json:"-"`
+	// Method is the HTTP method of the request
+	Method string `json:"-"`
+	// Endpoint is the path of the request
+	Endpoint string `json:"-"`
+	// RequestID is the request ID from the response headers, if mailform or a proxy sent one
+	RequestID string `json:"-"`
+	// Header is the response headers
+	Header http.Header `json:"-"`
+	// RawBody is the response body, truncated to MaxErrBodySize bytes
+	RawBody string `json:"-"`
 }
 
 func (e *ErrMailform) Error() string {
@@ -80,6 +144,29 @@ func New(c *Config) (*Client, error) {
 			SetBaseURL(baseURL).
 			SetTimeout(timeout).
 			SetAuthToken(c.Token),
+		budget:       c.Budget,
+		dryRun:       c.DryRun,
+		onRequest:    c.OnRequest,
+		onResponse:   c.OnResponse,
+		audit:        c.Audit,
+		normalize:    c.NormalizeAddresses,
+		strictness:   c.AddressStrictness,
+		verify:       c.Verification,
+		suppress:     c.Suppression,
+		checkNumbers: c.CheckNumbers,
+		checkLedger:  c.CheckLedger,
+		approvals:    c.Approvals,
+	}
+
+	// Throttle requests if a rate limit is configured
+	if c.RateLimit != nil {
+		if c.RateLimit.RequestsPerSecond <= 0 {
+			return nil, ErrInvalidRateLimit
+		}
+		mailformClient.limiter = newRateLimiter(c.RateLimit)
+		mailformClient.restClient.
+			OnBeforeRequest(mailformClient.limiter.beforeRequest).
+			OnAfterResponse(mailformClient.limiter.afterResponse)
 	}
 
 	return mailformClient, nil
@@ -101,3 +188,81 @@ func checkBodyForErr(b []byte) error {
 
 	return nil
 }
+
+// send sends a request to mailform, decoding a successful response into result.
+// Every error mailform responds with goes through here so they all carry the same HTTP context.
+func (c *Client) send(req *resty.Request, info *RequestInfo, result interface{}) (err error) {
+	mailformErr := &ErrMailform{}
+
+	c.requestHook(info)
+	resp, err := req.
+		SetResult(result).
+		SetError(mailformErr).
+		Execute(info.Method, info.Endpoint)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		c.responseHook(info, resp, err)
+	}()
+
+	return responseErr(resp, info, mailformErr)
+}
+
+// responseErr returns the error a response from mailform represents, if any.
+// mailformErr is the error body resty decoded, if it could.
+func responseErr(resp *resty.Response, info *RequestInfo, mailformErr *ErrMailform) error {
+	if resp.StatusCode() == http.StatusUnauthorized {
+		mailformErr.Err.Code = strconv.Itoa(http.StatusUnauthorized)
+		mailformErr.Err.Message = "unauthorized"
+		mailformErr.Detail = ""
+		return mailformErr.withResponse(resp, info)
+	}
+
+	if resp.IsError() {
+		// This API is trash and tf provider needs some kind of diag summary
+		if mailformErr.Err.Code == "" {
+			mailformErr.Err.Code = strconv.Itoa(resp.StatusCode())
+		}
+		if mailformErr.Err.Message == "" {
+			mailformErr.Err.Message = truncateBody(resp.Body())
+		}
+		return mailformErr.withResponse(resp, info)
+	}
+
+	// We can actually get 200 failed successfully and it's so dumb amirite
+	err := checkBodyForErr(resp.Body())
+	bodyErr := &ErrMailform{}
+	if errors.As(err, &bodyErr) {
+		return bodyErr.withResponse(resp, info)
+	}
+
+	return err
+}
+
+// withResponse records the HTTP context of the request and response on the error.
+func (e *ErrMailform) withResponse(resp *resty.Response, info *RequestInfo) *ErrMailform {
+	e.StatusCode = resp.StatusCode()
+	e.Method = info.Method
+	e.Endpoint = info.Endpoint
+	e.Header = resp.Header().Clone()
+	e.RawBody = truncateBody(resp.Body())
+
+	for _, header := range requestIDHeaders {
+		if id := resp.Header().Get(header); id != "" {
+			e.RequestID = id
+			break
+		}
+	}
+
+	return e
+}
+
+// truncateBody returns the body as a string, cut down to MaxErrBodySize bytes.
+func truncateBody(b []byte) string {
+	if len(b) <= MaxErrBodySize {
+		return string(b)
+	}
+
+	return string(b[:MaxErrBodySize]) + "...(truncated)"
+}
@@ -2,8 +2,12 @@ package mailform
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,6 +25,22 @@ func TestNew(t *testing.T) {
 			expectErr:   true,
 			expectedErr: ErrNilConfig,
 		},
+		{
+			name: "EnsureZeroRateLimitErrReturned",
+			input: &Config{
+				RateLimit: &RateLimit{Burst: 5},
+			},
+			expectErr:   true,
+			expectedErr: ErrInvalidRateLimit,
+		},
+		{
+			name: "EnsureNegativeRateLimitErrReturned",
+			input: &Config{
+				RateLimit: &RateLimit{RequestsPerSecond: -1, Burst: 5},
+			},
+			expectErr:   true,
+			expectedErr: ErrInvalidRateLimit,
+		},
 		{
 			name: "EnsureCustomBaseURLIsSet",
 			input: &Config{
@@ -96,3 +116,99 @@ func TestCheckBodyForErr(t *testing.T) {
 		})
 	}
 }
+
+func TestErrMailformHTTPContext(t *testing.T) {
+	fakeOrderID := "someID"
+	tests := []struct {
+		name               string
+		method             string
+		endpoint           string
+		status             int
+		body               string
+		headers            map[string]string
+		expectedCode       string
+		expectedMessage    string
+		expectedRequestID  string
+		expectedRawBodyLen int
+	}{
+		{
+			name:               "EnsureGetOrderNonJSONErrorIsFilled",
+			method:             http.MethodGet,
+			endpoint:           fmt.Sprintf("%s/%s", ordersEndpoint, fakeOrderID),
+			status:             http.StatusBadGateway,
+			body:               "bad gateway",
+			headers:            map[string]string{"X-Request-Id": "some_request_id"},
+			expectedCode:       "502",
+			expectedMessage:    "bad gateway",
+			expectedRequestID:  "some_request_id",
+			expectedRawBodyLen: len("bad gateway"),
+		},
+		{
+			name:               "EnsureUnauthorizedKeepsContext",
+			method:             http.MethodPost,
+			endpoint:           ordersEndpoint,
+			status:             http.StatusUnauthorized,
+			body:               `{"message":"Unauthenticated."}`,
+			headers:            map[string]string{"Cf-Ray": "some_ray"},
+			expectedCode:       "401",
+			expectedMessage:    "unauthorized",
+			expectedRequestID:  "some_ray",
+			expectedRawBodyLen: len(`{"message":"Unauthenticated."}`),
+		},
+		{
+			name:               "EnsureOKWithErrorBodyKeepsContext",
+			method:             http.MethodPost,
+			endpoint:           ordersEndpoint,
+			status:             http.StatusOK,
+			body:               `{"error":{"code":"erroroccurred","message":"no_file_uploaded"}}`,
+			expectedCode:       "erroroccurred",
+			expectedMessage:    "no_file_uploaded",
+			expectedRawBodyLen: len(`{"error":{"code":"erroroccurred","message":"no_file_uploaded"}}`),
+		},
+		{
+			name:               "EnsureRawBodyIsTruncated",
+			method:             http.MethodGet,
+			endpoint:           fmt.Sprintf("%s/%s", ordersEndpoint, fakeOrderID),
+			status:             http.StatusInternalServerError,
+			body:               strings.Repeat("a", MaxErrBodySize*2),
+			expectedCode:       "500",
+			expectedMessage:    strings.Repeat("a", MaxErrBodySize) + "...(truncated)",
+			expectedRawBodyLen: MaxErrBodySize + len("...(truncated)"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mailformClient, err := New(&Config{})
+			assert.NoError(t, err)
+
+			httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+			defer httpmock.DeactivateAndReset()
+
+			httpmock.RegisterResponder(test.method, DefaultBaseURL+test.endpoint,
+				func(req *http.Request) (*http.Response, error) {
+					resp := httpmock.NewStringResponse(test.status, test.body)
+					for k, v := range test.headers {
+						resp.Header.Set(k, v)
+					}
+					return resp, nil
+				})
+
+			if test.method == http.MethodGet {
+				_, err = mailformClient.GetOrder(fakeOrderID)
+			} else {
+				_, err = mailformClient.CreateOrder(testOrderInput())
+			}
+
+			mailformErr := &ErrMailform{}
+			assert.ErrorAs(t, err, &mailformErr)
+			assert.Equal(t, test.status, mailformErr.StatusCode)
+			assert.Equal(t, test.method, mailformErr.Method)
+			assert.Equal(t, test.endpoint, mailformErr.Endpoint)
+			assert.Equal(t, test.expectedCode, mailformErr.Err.Code)
+			assert.Equal(t, test.expectedMessage, mailformErr.Err.Message)
+			assert.Equal(t, test.expectedRequestID, mailformErr.RequestID)
+			assert.Len(t, mailformErr.RawBody, test.expectedRawBodyLen)
+		})
+	}
+}
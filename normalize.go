@@ -0,0 +1,389 @@
+package mailform
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxAddressLineLength is the most characters an address line can have, longer lines are truncated when normalizing
+	MaxAddressLineLength = 40
+)
+
+// AddressChange is a change made to an address field while normalizing it.
+type AddressChange struct {
+	// Field is the name of the field that changed, such as ToAddress1
+	Field  string
+	Before string
+	After  string
+	// Reasons are what was done to the field, such as "abbreviated street suffix"
+	Reasons []string
+}
+
+// String describes the change.
+func (c AddressChange) String() string {
+	return fmt.Sprintf("%s: %q -> %q (%s)", c.Field, c.Before, c.After, strings.Join(c.Reasons, ", "))
+}
+
+var (
+	// streetSuffixes maps street suffixes and their common misspellings to their USPS Publication 28 abbreviations
+	streetSuffixes = map[string]string{
+		"ALLEY": "ALY", "ALLEE": "ALY", "ALLY": "ALY",
+		"AVENUE": "AVE", "AV": "AVE", "AVEN": "AVE", "AVENU": "AVE", "AVN": "AVE", "AVNUE": "AVE",
+		"BEACH": "BCH", "BEND": "BND", "BLUFF": "BLF",
+		"BOULEVARD": "BLVD", "BOUL": "BLVD", "BOULV": "BLVD",
+		"BRANCH": "BR", "BRIDGE": "BRG", "BROOK": "BRK", "BYPASS": "BYP",
+		"CANYON": "CYN", "CAUSEWAY": "CSWY", "CENTER": "CTR", "CENTRE": "CTR", "CENTR": "CTR",
+		"CIRCLE": "CIR", "CIRC": "CIR", "CIRCL": "CIR", "CRCLE": "CIR",
+		"CLIFF": "CLF", "CLUB": "CLB", "COMMON": "CMN", "CORNER": "COR",
+		"COURT": "CT", "CRT": "CT", "COVE": "CV", "CREEK": "CRK", "CRESCENT": "CRES", "CROSSING": "XING",
+		"DRIVE": "DR", "DRIV": "DR", "DRV": "DR",
+		"ESTATE": "EST", "ESTATES": "ESTS", "EXPRESSWAY": "EXPY", "EXPRESS": "EXPY",
+		"FALLS": "FLS", "FERRY": "FRY", "FIELD": "FLD", "FIELDS": "FLDS", "FOREST": "FRST", "FORK": "FRK",
+		"FORT": "FT", "FREEWAY": "FWY",
+		"GARDEN": "GDN", "GARDENS": "GDNS", "GATEWAY": "GTWY", "GLEN": "GLN", "GREEN": "GRN", "GROVE": "GRV",
+		"HARBOR": "HBR", "HAVEN": "HVN", "HEIGHTS": "HTS", "HIGHWAY": "HWY", "HIGHWY": "HWY", "HIWAY": "HWY",
+		"HILL": "HL", "HILLS": "HLS", "HOLLOW": "HOLW",
+		"ISLAND": "IS", "JUNCTION": "JCT", "KNOLL": "KNL",
+		"LAKE": "LK", "LAKES": "LKS", "LANDING": "LNDG", "LANE": "LN",
+		"MANOR": "MNR", "MEADOW": "MDW", "MEADOWS": "MDWS", "MILL": "ML", "MOUNT": "MT", "MOUNTAIN": "MTN",
+		"ORCHARD": "ORCH", "PARKWAY": "PKWY", "PARKWY": "PKWY", "PKY": "PKWY",
+		"PINES": "PNES", "PLACE": "PL", "PLAINS": "PLNS", "PLAZA": "PLZ", "POINT": "PT", "PORT": "PRT", "PRAIRIE": "PR",
+		"RANCH": "RNCH", "RIDGE": "RDG", "RIVER": "RIV", "ROAD": "RD", "ROUTE": "RTE",
+		"SHORE": "SHR", "SHORES": "SHRS", "SPRING": "SPG", "SPRINGS": "SPGS", "SQUARE": "SQ", "SQR": "SQ",
+		"STATION": "STA", "STREAM": "STRM", "STREET": "ST", "STR": "ST", "STRT": "ST", "SUMMIT": "SMT",
+		"TERRACE": "TER", "TERR": "TER", "TRACE": "TRCE", "TRACK": "TRAK", "TRAIL": "TRL", "TURNPIKE": "TPKE",
+		"UNION": "UN", "VALLEY": "VLY", "VIEW": "VW", "VILLAGE": "VLG", "VISTA": "VIS", "WELLS": "WLS",
+	}
+	// streetSuffixAbbreviations are the standard abbreviations, plus suffixes that aren't abbreviated
+	streetSuffixAbbreviations = map[string]bool{
+		"LOOP": true, "OVAL": true, "PARK": true, "PASS": true, "PATH": true, "PIKE": true, "RUN": true,
+		"WALK": true, "WAY": true, "ROW": true, "MALL": true,
+	}
+	// unitDesignators maps secondary unit designators to their USPS Publication 28 abbreviations
+	unitDesignators = map[string]string{
+		"APARTMENT": "APT", "BASEMENT": "BSMT", "BUILDING": "BLDG", "DEPARTMENT": "DEPT", "FLOOR": "FL",
+		"FRONT": "FRNT", "HANGAR": "HNGR", "LOBBY": "LBBY", "LOWER": "LOWR", "OFFICE": "OFC",
+		"PENTHOUSE": "PH", "ROOM": "RM", "SPACE": "SPC", "SUITE": "STE", "TRAILER": "TRLR", "UPPER": "UPPR",
+	}
+	// standaloneUnitDesignators are the unit designators that aren't followed by a unit number
+	standaloneUnitDesignators = map[string]bool{
+		"BSMT": true, "FRNT": true, "LBBY": true, "LOWR": true, "OFC": true, "PH": true, "REAR": true, "SIDE": true, "UPPR": true,
+	}
+	// unitDesignatorAbbreviations are the standard abbreviations, plus designators that aren't abbreviated
+	unitDesignatorAbbreviations = map[string]bool{
+		"KEY": true, "LOT": true, "PIER": true, "REAR": true, "SIDE": true, "SLIP": true, "STOP": true, "UNIT": true,
+	}
+	// directionals maps directions to their USPS Publication 28 abbreviations
+	directionals = map[string]string{
+		"NORTH": "N", "SOUTH": "S", "EAST": "E", "WEST": "W",
+		"NORTHEAST": "NE", "NORTHWEST": "NW", "SOUTHEAST": "SE", "SOUTHWEST": "SW",
+	}
+)
+
+func init() {
+	for _, abbreviation := range streetSuffixes {
+		streetSuffixAbbreviations[abbreviation] = true
+	}
+	for _, abbreviation := range unitDesignators {
+		unitDesignatorAbbreviations[abbreviation] = true
+	}
+}
+
+// NormalizeAddress normalizes an address to USPS Publication 28 conventions.
+// Everything is upper cased and stray punctuation is removed. Street suffixes, directionals and unit designators
+// are abbreviated, US ZIP+4 codes are written as 12345-6789 and lines are truncated to MaxAddressLineLength characters.
+// Countries are only upper cased so they can still be parsed.
+// It returns the normalized address and the changes made, with Field set to the Address field name.
+func NormalizeAddress(a Address) (Address, []AddressChange) {
+	changes := []AddressChange{}
+	fields := a.fields()
+	// ZIP codes are only canonicalized in the US, which addresses without a country are assumed to be in
+	us := strings.TrimSpace(a.Country) == "" || isUnitedStates(a.Country)
+
+	for i, field := range fields {
+		before := *field
+		after, reasons := normalizeField(addressFields[i], before, us)
+		*field = after
+		if after != before {
+			changes = append(changes, AddressChange{
+				Field:   addressFields[i],
+				Before:  before,
+				After:   after,
+				Reasons: reasons,
+			})
+		}
+	}
+
+	return a, changes
+}
+
+// NormalizeAddresses normalizes the recipient and sender addresses with NormalizeAddress and returns the changes made.
+func (o *OrderInput) NormalizeAddresses() []AddressChange {
+	to, toChanges := NormalizeAddress(o.To())
+	from, fromChanges := NormalizeAddress(o.From())
+	o.SetTo(to)
+	o.SetFrom(from)
+
+	changes := make([]AddressChange, 0, len(toChanges)+len(fromChanges))
+	for _, change := range toChanges {
+		change.Field = "To" + change.Field
+		changes = append(changes, change)
+	}
+	for _, change := range fromChanges {
+		change.Field = "From" + change.Field
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// normalizeField normalizes a single address field and returns why it changed.
+func normalizeField(field, value string, us bool) (string, []string) {
+	reasons := []string{}
+	reason := func(before, after, why string) string {
+		if before != after {
+			reasons = append(reasons, why)
+		}
+		return after
+	}
+
+	value = reason(value, strings.Join(strings.Fields(value), " "), "collapsed whitespace")
+	value = reason(value, strings.ToUpper(value), "upper cased")
+
+	switch field {
+	case "Postcode":
+		if us {
+			value = reason(value, normalizeZIP(value), "canonicalized ZIP code")
+		}
+		return value, reasons
+	case "Country":
+		// Country names are parsed by Validate, so punctuation like the apostrophe in COTE D'IVOIRE
+		// and the end of long names have to be kept
+		return value, reasons
+	case "Name", "Organization":
+		// Apostrophes and hyphens are part of names
+		value = reason(value, removePunctuation(value, ".,;:!?\""), "removed punctuation")
+	default:
+		value = reason(value, removePunctuation(value, ".,;:!?\"'"), "removed punctuation")
+	}
+
+	switch field {
+	case "Address1", "Address2":
+		words := strings.Fields(value)
+		value = reason(value, strings.Join(abbreviatePOBox(words), " "), "abbreviated PO box")
+		words = strings.Fields(value)
+		value = reason(value, strings.Join(abbreviateUnit(words), " "), "abbreviated unit designator")
+		words = strings.Fields(value)
+		value = reason(value, strings.Join(abbreviateStreet(words), " "), "abbreviated street suffix and directionals")
+	}
+
+	if utf8.RuneCountInString(value) > MaxAddressLineLength {
+		value = reason(value, strings.TrimSpace(string([]rune(value)[:MaxAddressLineLength])), fmt.Sprintf("truncated to %d characters", MaxAddressLineLength))
+	}
+
+	return value, reasons
+}
+
+// removePunctuation removes the punctuation characters from value.
+// Punctuation between letters and digits is replaced with a space so words don't run together.
+func removePunctuation(value string, punctuation string) string {
+	out := &strings.Builder{}
+	runes := []rune(value)
+	for i, r := range runes {
+		if !strings.ContainsRune(punctuation, r) {
+			out.WriteRune(r)
+			continue
+		}
+		// "MAIN ST.,APT 4" shouldn't become "MAIN STAPT 4", but "P.O." should become "PO"
+		if r == ',' || r == ';' || r == ':' {
+			if i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+				out.WriteRune(' ')
+			}
+		}
+	}
+
+	return strings.Join(strings.Fields(out.String()), " ")
+}
+
+// normalizeZIP writes 9 digit ZIP codes as ZIP+4 with a dash. Anything that isn't 5 or 9 digits is left alone.
+func normalizeZIP(value string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, value)
+
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return value
+		}
+	}
+
+	switch len(digits) {
+	case 5:
+		return digits
+	case 9:
+		return digits[:5] + "-" + digits[5:]
+	}
+
+	return value
+}
+
+// abbreviatePOBox writes PO boxes as PO BOX.
+// A line starting with just BOX is only a PO box if a box number follows, so BOX ELDER RD is left alone.
+func abbreviatePOBox(words []string) []string {
+	for _, prefix := range [][]string{{"POST", "OFFICE", "BOX"}, {"P", "O", "BOX"}, {"POB"}, {"BOX"}} {
+		if len(words) <= len(prefix) {
+			continue
+		}
+		if len(prefix) == 1 && prefix[0] == "BOX" && !isBoxNumber(words[1]) {
+			continue
+		}
+
+		match := true
+		for i, word := range prefix {
+			if words[i] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return append([]string{"PO", "BOX"}, words[len(prefix):]...)
+		}
+	}
+
+	return words
+}
+
+// isBoxNumber returns true if word is a PO box number, which starts with a digit like 12 or 12A.
+func isBoxNumber(word string) bool {
+	return word != "" && word[0] >= '0' && word[0] <= '9'
+}
+
+// unitStart returns the index of the first word of the secondary unit, such as APT 4, or len(words) if there isn't one.
+// Lines that start with a unit designator are all unit. Otherwise the unit comes after at least a number and street name,
+// designators like APT must be followed by a unit number and ones like REAR must be last, so a street like
+// 123 N FRONT ST isn't mistaken for one.
+func unitStart(words []string) int {
+	if len(words) > 0 && (isUnitDesignator(words[0]) || strings.HasPrefix(words[0], "#")) {
+		return 0
+	}
+
+	for i := 2; i < len(words); i++ {
+		word := words[i]
+		if strings.HasPrefix(word, "#") {
+			return i
+		}
+		if !isUnitDesignator(word) {
+			continue
+		}
+
+		last := i == len(words)-1
+		if standaloneUnitDesignators[abbreviateUnitDesignator(word)] {
+			if last {
+				return i
+			}
+			continue
+		}
+		if !last && !isStreetSuffix(words[i+1]) {
+			return i
+		}
+	}
+
+	return len(words)
+}
+
+// isUnitDesignator returns true if word is a secondary unit designator or its abbreviation.
+func isUnitDesignator(word string) bool {
+	_, ok := unitDesignators[word]
+
+	return ok || unitDesignatorAbbreviations[word]
+}
+
+// abbreviateUnitDesignator abbreviates a unit designator, abbreviations are returned as is.
+func abbreviateUnitDesignator(word string) string {
+	if abbreviation, ok := unitDesignators[word]; ok {
+		return abbreviation
+	}
+
+	return word
+}
+
+// abbreviateUnit abbreviates the secondary unit designator of an address line.
+func abbreviateUnit(words []string) []string {
+	start := unitStart(words)
+	if start == len(words) {
+		return words
+	}
+
+	words[start] = abbreviateUnitDesignator(words[start])
+
+	return words
+}
+
+// abbreviateStreet abbreviates the street suffix and directionals of an address line, leaving any secondary unit alone.
+// Only the last word of the street can be a suffix and only when there's a street name before it,
+// so 123 AVENUE OF THE AMERICAS keeps its AVENUE.
+func abbreviateStreet(words []string) []string {
+	if len(words) > 0 && words[0] == "PO" {
+		return words
+	}
+
+	street := words[:unitStart(words)]
+
+	// Post directional, such as 123 MAIN STREET NORTH
+	last := len(street) - 1
+	if last >= 2 && isDirectional(street[last]) && isStreetSuffix(street[last-1]) {
+		street[last] = abbreviateDirectional(street[last])
+		last--
+	}
+
+	// Street suffix, after at least a number and name
+	if last >= 2 && isStreetSuffix(street[last]) {
+		if abbreviation, ok := streetSuffixes[street[last]]; ok {
+			street[last] = abbreviation
+		}
+	}
+
+	// Pre directional, such as 123 NORTH MAIN STREET
+	if last >= 3 && isDirectional(street[1]) {
+		street[1] = abbreviateDirectional(street[1])
+	}
+
+	return words
+}
+
+// isStreetSuffix returns true if word is a street suffix or its abbreviation.
+func isStreetSuffix(word string) bool {
+	_, ok := streetSuffixes[word]
+
+	return ok || streetSuffixAbbreviations[word]
+}
+
+// isDirectional returns true if word is a direction or its abbreviation.
+func isDirectional(word string) bool {
+	_, ok := directionals[word]
+	if ok {
+		return true
+	}
+	for _, abbreviation := range directionals {
+		if word == abbreviation {
+			return true
+		}
+	}
+
+	return false
+}
+
+// abbreviateDirectional abbreviates a direction, abbreviations are returned as is.
+func abbreviateDirectional(word string) string {
+	if abbreviation, ok := directionals[word]; ok {
+		return abbreviation
+	}
+
+	return word
+}
@@ -0,0 +1,203 @@
+package mailform
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    Address
+		expected Address
+	}{
+		{
+			name: "street suffix, directional and unit",
+			input: Address{
+				Name:     "Jane  O'Brien",
+				Address1: "123 north main street apt. 4",
+				City:     "Springfield",
+				State:    "il",
+				Postcode: "627011234",
+				Country:  "US",
+			},
+			expected: Address{
+				Name:     "JANE O'BRIEN",
+				Address1: "123 N MAIN ST APT 4",
+				City:     "SPRINGFIELD",
+				State:    "IL",
+				Postcode: "62701-1234",
+				Country:  "US",
+			},
+		},
+		{
+			name:     "already abbreviated",
+			input:    Address{Address1: "123 N Front St", Postcode: "12345 6789", Country: "USA"},
+			expected: Address{Address1: "123 N FRONT ST", Postcode: "12345-6789", Country: "USA"},
+		},
+		{
+			name:     "street named after a suffix",
+			input:    Address{Address1: "1211 Avenue of the Americas", Country: "US"},
+			expected: Address{Address1: "1211 AVENUE OF THE AMERICAS", Country: "US"},
+		},
+		{
+			name:     "post directional",
+			input:    Address{Address1: "500 Main Street North", Country: "US"},
+			expected: Address{Address1: "500 MAIN ST N", Country: "US"},
+		},
+		{
+			name:     "unit on its own line",
+			input:    Address{Address1: "1 Market Boulevard", Address2: "Suite 100", Country: "US"},
+			expected: Address{Address1: "1 MARKET BLVD", Address2: "STE 100", Country: "US"},
+		},
+		{
+			name:     "po box",
+			input:    Address{Address1: "P.O. Box 12", Postcode: "12345-6789", Country: "US"},
+			expected: Address{Address1: "PO BOX 12", Postcode: "12345-6789", Country: "US"},
+		},
+		{
+			name:     "box",
+			input:    Address{Address1: "Box 12", Country: "US"},
+			expected: Address{Address1: "PO BOX 12", Country: "US"},
+		},
+		{
+			name:     "street named box",
+			input:    Address{Address1: "Box Elder Road 5", Country: "US"},
+			expected: Address{Address1: "BOX ELDER ROAD 5", Country: "US"},
+		},
+		{
+			name:     "non US postcode",
+			input:    Address{Address1: "10 Downing Street", Postcode: "sw1a 2aa", Country: "GB"},
+			expected: Address{Address1: "10 DOWNING ST", Postcode: "SW1A 2AA", Country: "GB"},
+		},
+		{
+			name:     "long line",
+			input:    Address{Address1: "12345 Extraordinarily Long Named Boulevard", Country: "US"},
+			expected: Address{Address1: "12345 EXTRAORDINARILY LONG NAMED BLVD", Country: "US"},
+		},
+		{
+			name:     "country names keep their punctuation and length",
+			input:    Address{Address1: "1 Rue Main", Country: "Côte d'Ivoire"},
+			expected: Address{Address1: "1 RUE MAIN", Country: "CÔTE D'IVOIRE"},
+		},
+		{
+			name:     "truncated",
+			input:    Address{Organization: "The Extraordinarily Long Named Company Incorporated"},
+			expected: Address{Organization: "THE EXTRAORDINARILY LONG NAMED COMPANY I"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, _ := NormalizeAddress(test.input)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestNormalizeAddressChanges(t *testing.T) {
+	_, changes := NormalizeAddress(Address{
+		Name:     "JANE DOE",
+		Address1: "123 Main Street.",
+		Postcode: "12345",
+		Country:  "US",
+	})
+
+	assert.Equal(t, []AddressChange{
+		{
+			Field:   "Address1",
+			Before:  "123 Main Street.",
+			After:   "123 MAIN ST",
+			Reasons: []string{"upper cased", "removed punctuation", "abbreviated street suffix and directionals"},
+		},
+	}, changes)
+	assert.Equal(t, `Address1: "123 Main Street." -> "123 MAIN ST" (upper cased, removed punctuation, abbreviated street suffix and directionals)`, changes[0].String())
+
+	_, changes = NormalizeAddress(Address{Name: "JANE DOE"})
+	assert.Empty(t, changes)
+}
+
+func TestOrderInputNormalizeAddresses(t *testing.T) {
+	input := testOrderInput()
+	input.ToAddress1 = "1 Main Street"
+	input.FromPostcode = "123456789"
+	input.FromCountry = "US"
+
+	changes := input.NormalizeAddresses()
+	assert.Equal(t, "1 MAIN ST", input.ToAddress1)
+	assert.Equal(t, "SOME_NAME", input.ToName)
+	assert.Equal(t, "12345-6789", input.FromPostcode)
+
+	fields := []string{}
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	assert.Contains(t, fields, "ToAddress1")
+	assert.Contains(t, fields, "FromPostcode")
+	assert.NotContains(t, fields, "ToOrganization")
+}
+
+func TestNormalizeAddressesValidCountries(t *testing.T) {
+	for _, country := range []string{
+		"Côte d'Ivoire",
+		"Lao People's Democratic Republic",
+		"Bonaire, Sint Eustatius and Saba",
+		"United Kingdom of Great Britain and Northern Ireland",
+	} {
+		t.Run(country, func(t *testing.T) {
+			input := testOrderInput()
+			input.ToPostcode = "SW1A 1AA"
+			input.ToCountry = country
+			expected, err := ParseCountry(country)
+			assert.NoError(t, err)
+			assert.NoError(t, input.Validate())
+
+			input.NormalizeAddresses()
+			actual, err := ParseCountry(input.ToCountry)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+			assert.NoError(t, input.Validate())
+		})
+	}
+}
+
+func TestCreateOrderNormalizeAddresses(t *testing.T) {
+	mailformClient, err := New(&Config{
+		Token:              "someToken",
+		NormalizeAddresses: true,
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	var sent url.Values
+	httpmock.RegisterResponder("POST", DefaultBaseURL+ordersEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			err := req.ParseForm()
+			assert.NoError(t, err)
+			sent = req.PostForm
+			return jsonResponder(http.StatusOK, `{"success": true, "data": {"id": "some_id"}}`)(req)
+		})
+
+	input := testOrderInput()
+	input.ToAddress1 = "1 Main Street"
+
+	order, err := mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "1 MAIN ST", sent.Get("to.address1"))
+	assert.Equal(t, "SOME_NAME", sent.Get("to.name"))
+	assert.NotEmpty(t, order.AddressChanges)
+	assert.Equal(t, "ToName", order.AddressChanges[0].Field)
+
+	// Addresses are left alone by default
+	mailformClient.normalize = false
+	order, err = mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "1 Main Street", sent.Get("to.address1"))
+	assert.Nil(t, order.AddressChanges)
+}
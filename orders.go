@@ -1,14 +1,22 @@
 package mailform
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 )
 
 const (
 	ordersEndpoint = "/orders"
+	// defaultFileName is the name File is uploaded as when FileName isn't set
+	defaultFileName = "document.pdf"
 )
 
 var (
@@ -24,6 +32,18 @@ var (
 		"USPS_STANDARD",
 		"USPS_POSTCARD",
 	}
+	// ErrFileNotPersisted is returned when queueing, scheduling or holding for approval an order input with a File,
+	// since readers can't be saved.
+	// Write the document somewhere and use FilePath instead.
+	ErrFileNotPersisted = errors.New("order input File can't be persisted, use FilePath instead")
+	// FormKeys are the form data keys accepted by OrderInput.SetFormValue
+	FormKeys = []string{
+		"file", "url", "customer_reference", "service", "webhook", "company",
+		"simplex", "color", "flat", "stamp", "message",
+		"to.name", "to.organization", "to.address1", "to.address2", "to.city", "to.state", "to.postcode", "to.country",
+		"from.name", "from.organization", "from.address1", "from.address2", "from.city", "from.state", "from.postcode", "from.country",
+		"bank_account", "amount", "check_name", "check_number", "check_memo",
+	}
 )
 
 // OrderInput is the input used to create an order.
@@ -34,6 +54,11 @@ type OrderInput struct {
 	// If this is not specified, the url parameter must be provided.
 	// If both the file parameter and the url parameter are provided, the url parameter will be ignored
 	FilePath string
+	// File is the PDF document to be mailed, read from instead of FilePath if set.
+	// It is read into memory when the order is created. Order inputs with a File can't be queued or scheduled.
+	File io.Reader `json:"-"`
+	// FileName is the name File is uploaded as, defaults to document.pdf
+	FileName string
 	// The URL of the PDF document to be mailed: it will be downloaded completely before the API call completes.
 	// The download must complete within 30 seconds.
 	// If this is not specified, the file parameter must be provided.
@@ -159,6 +184,87 @@ func (o *OrderInput) FormData() map[string]string {
 	return formData
 }
 
+// SetFormValue sets the order input field with the given form data key, the reverse of FormData.
+// The key "file" sets FilePath. Booleans and numbers are parsed from their string form.
+func (o *OrderInput) SetFormValue(key string, value string) error {
+	var err error
+
+	switch key {
+	case "file":
+		o.FilePath = value
+	case "url":
+		o.URL = value
+	case "customer_reference":
+		o.CustomerReference = value
+	case "service":
+		o.Service = value
+	case "webhook":
+		o.Webhook = value
+	case "company":
+		o.Company = value
+	case "simplex":
+		o.Simplex, err = strconv.ParseBool(value)
+	case "color":
+		o.Color, err = strconv.ParseBool(value)
+	case "flat":
+		o.Flat, err = strconv.ParseBool(value)
+	case "stamp":
+		o.Stamp, err = strconv.ParseBool(value)
+	case "message":
+		o.Message = value
+	case "to.name":
+		o.ToName = value
+	case "to.organization":
+		o.ToOrganization = value
+	case "to.address1":
+		o.ToAddress1 = value
+	case "to.address2":
+		o.ToAddress2 = value
+	case "to.city":
+		o.ToCity = value
+	case "to.state":
+		o.ToState = value
+	case "to.postcode":
+		o.ToPostcode = value
+	case "to.country":
+		o.ToCountry = value
+	case "from.name":
+		o.FromName = value
+	case "from.organization":
+		o.FromOrganization = value
+	case "from.address1":
+		o.FromAddress1 = value
+	case "from.address2":
+		o.FromAddress2 = value
+	case "from.city":
+		o.FromCity = value
+	case "from.state":
+		o.FromState = value
+	case "from.postcode":
+		o.FromPostcode = value
+	case "from.country":
+		o.FromCountry = value
+	case "bank_account":
+		o.BankAccount = value
+	case "amount":
+		o.Amount, err = strconv.Atoi(value)
+	case "check_name":
+		o.CheckName = value
+	case "check_number":
+		o.CheckNumber, err = strconv.Atoi(value)
+	case "check_memo":
+		o.CheckMemo = value
+	default:
+		return fmt.Errorf("unknown form data key '%s'", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+
+	return nil
+}
+
 // Order is the details of an order from mailform.
 type Order struct {
 	Success bool `json:"success"`
@@ -210,64 +316,200 @@ type Order struct {
 		Cancelled          time.Time `json:"cancelled"`
 		CancellationReason string    `json:"cancellation_reason"`
 	} `json:"data"`
+	// DryRunRequest is the request that would have been sent when the client is in dry run mode
+	DryRunRequest *PreparedRequest `json:"-"`
+	// AddressChanges are the changes made to the order input's addresses when the client normalizes addresses
+	AddressChanges []AddressChange `json:"-"`
+	// Warnings are the problems found with the order input that didn't stop it being sent
+	Warnings Diagnostics `json:"-"`
 }
 
 // CreateOrder creates a mailform order.
 func (c *Client) CreateOrder(o OrderInput) (*Order, error) {
-	order := &Order{}
-	mailformErr := &ErrMailform{}
+	return c.CreateOrderWithContext(context.Background(), o)
+}
+
+// CreateOrderWithContext creates a mailform order.
+// The context is used for the request as well as any time spent waiting on the rate limiter.
+func (c *Client) CreateOrderWithContext(ctx context.Context, o OrderInput) (*Order, error) {
+	err := o.bufferFile()
+	if err != nil {
+		return &Order{}, err
+	}
+
+	var changes []AddressChange
+	if c.normalize {
+		changes = o.NormalizeAddresses()
+	}
+
+	// Verification can correct the order input, so what's audited is what was sent
+	order, err := c.createOrder(ctx, &o)
+	if order != nil && changes != nil {
+		order.AddressChanges = changes
+	}
+	// Dry runs never mail anything, so they'd only muddy the audit log
+	if c.audit == nil || c.dryRun {
+		return order, err
+	}
+
+	auditErr := c.audit.recordCreateOrder(ctx, o, order, err)
+	if auditErr != nil && err == nil {
+		return order, fmt.Errorf("order %s created but could not be audited: %w", order.Data.ID, auditErr)
+	}
 
+	return order, err
+}
+
+// createOrder validates an order input and sends it unless something configured on the client stops it.
+func (c *Client) createOrder(ctx context.Context, o *OrderInput) (*Order, error) {
 	// First validate order input
 	err := o.Validate()
 	if err != nil {
+		return &Order{}, err
+	}
+
+	warnings, err := o.ValidateUSAddresses(c.strictness)
+	if err != nil {
+		return &Order{}, err
+	}
+
+	if c.verify != nil {
+		verifyWarnings, err := VerifyOrderAddresses(ctx, o, c.verify)
+		if err != nil {
+			return &Order{}, err
+		}
+		warnings = append(warnings, verifyWarnings...)
+
+		// Corrections must still be valid
+		err = o.Validate()
+		if err != nil {
+			return &Order{}, err
+		}
+	}
+
+	// Checked last so corrections can't get around it
+	if c.suppress != nil {
+		if suppression, ok := c.suppress.Check(o.To()); ok {
+			return &Order{}, &ErrRecipientSuppressed{Suppression: suppression}
+		}
+	}
+
+	// Checked once the order input is final so approvers see what will be sent
+	if c.approvals != nil {
+		err = c.requireApproval(ctx, *o)
+		if err != nil {
+			return &Order{}, err
+		}
+	}
+
+	if c.checkNumbers != nil {
+		if check, ok := o.Check(); ok {
+			err = c.useCheckNumber(check)
+			if err != nil {
+				return &Order{}, err
+			}
+		}
+	}
+
+	order, err := c.submitOrder(ctx, *o)
+	if err != nil && !isOrderCreated(order) && c.checkNumbers != nil && !c.dryRun {
+		// The order was never created so its check number is still free
+		if check, ok := o.Check(); ok {
+			c.checkNumbers.Release(check)
+		}
+	}
+	if order != nil && len(warnings) > 0 {
+		order.Warnings = warnings
+	}
+	if err != nil || c.checkLedger == nil || c.dryRun {
 		return order, err
 	}
 
-	// Convert order input to form data
-	formData := o.FormData()
+	if _, ok := o.Check(); ok {
+		_, err = c.checkLedger.Record(*o, order)
+		if err != nil {
+			return order, fmt.Errorf("order %s created but could not be recorded in the check ledger: %w", order.Data.ID, err)
+		}
+	}
 
-	req := c.restClient.R()
-	// If path is provided, set file form data and read local file
-	if o.FilePath != "" {
-		req.SetFile("file", o.FilePath)
+	return order, nil
+}
+
+// useCheckNumber reserves the check's number, or only checks it's unused on dry runs.
+func (c *Client) useCheckNumber(check Check) error {
+	if !c.dryRun {
+		return c.checkNumbers.Reserve(check)
+	}
+	if c.checkNumbers.Used(check.BankAccount, check.Number) {
+		return fmt.Errorf("%w: check number %d on bank account %s", ErrCheckNumberUsed, check.Number, check.BankAccount)
 	}
 
-	// Send order
-	resp, err := req.
-		SetResult(order).
-		SetError(mailformErr).
-		SetFormData(formData).
-		Post(ordersEndpoint)
+	return nil
+}
+
+// submitOrder sends a validated order input unless it's a dry run, holding its cost against the budget.
+func (c *Client) submitOrder(ctx context.Context, o OrderInput) (*Order, error) {
+	// Nothing is sent so there's nothing to budget for
+	if c.dryRun {
+		return c.dryRunOrder(o)
+	}
 
+	if c.budget == nil {
+		return c.sendOrder(ctx, o)
+	}
+
+	// Hold the estimated cost against the budget while the order is in flight
+	reservation, err := c.budget.reserve(o)
+	if err != nil {
+		return &Order{}, err
+	}
+
+	order, err := c.sendOrder(ctx, o)
 	if err != nil {
+		c.budget.release(reservation)
 		return order, err
 	}
 
-	if resp.StatusCode() == http.StatusUnauthorized {
-		return order, &ErrMailform{
-			Err: struct {
-				Code    string `json:"code"`
-				Message string `json:"message"`
-			}{
-				Code:    strconv.Itoa(http.StatusUnauthorized),
-				Message: "unauthorized",
-			},
-		}
+	err = c.budget.commit(reservation, order)
+	if err != nil {
+		return order, fmt.Errorf("order %s created but budget usage could not be saved: %w", order.Data.ID, err)
 	}
 
-	if resp.IsError() {
-		// This API is trash and tf provider needs some kind of diag summary
-		if mailformErr.Err.Code == "" {
-			mailformErr.Err.Code = strconv.Itoa(resp.StatusCode())
-		}
-		if mailformErr.Err.Message == "" {
-			mailformErr.Err.Message = resp.String()
+	return order, nil
+}
+
+// sendOrder sends a validated order input to mailform.
+func (c *Client) sendOrder(ctx context.Context, o OrderInput) (*Order, error) {
+	order := &Order{}
+
+	// Convert order input to form data
+	formData := o.FormData()
+	info := &RequestInfo{
+		Method:   http.MethodPost,
+		Endpoint: ordersEndpoint,
+		Form:     formData,
+	}
+
+	req := c.restClient.R().SetContext(ctx).SetFormData(formData)
+	// If a file or path is provided, set file form data and read the document
+	document, name, err := o.document()
+	if err != nil {
+		return order, err
+	}
+	if document != nil {
+		defer document.Close()
+		req.SetFileReader("file", name, document)
+		// Only checksum the file if someone is going to look at it
+		if c.onRequest != nil || c.onResponse != nil {
+			err := info.fileInfo(o)
+			if err != nil {
+				return order, err
+			}
 		}
-		return order, mailformErr
 	}
 
-	// We can actually get 200 failed successfully and it's so dumb amirite
-	err = checkBodyForErr(resp.Body())
+	// Send order
+	err = c.send(req, info, order)
 	if err != nil {
 		return order, err
 	}
@@ -275,44 +517,116 @@ func (c *Client) CreateOrder(o OrderInput) (*Order, error) {
 	return order, nil
 }
 
+// bufferFile reads File into memory so the document can be read again for checksums, dry runs and the audit log.
+func (o *OrderInput) bufferFile() error {
+	if o.File == nil {
+		return nil
+	}
+
+	data, err := io.ReadAll(o.File)
+	if err != nil {
+		return fmt.Errorf("reading order input File: %w", err)
+	}
+	o.File = bytes.NewReader(data)
+
+	return nil
+}
+
+// document opens the document to upload and returns it with its file name.
+// It returns nil if the order input has no File or FilePath. File must have been buffered with bufferFile.
+func (o *OrderInput) document() (io.ReadCloser, string, error) {
+	if o.File != nil {
+		name := o.FileName
+		if name == "" {
+			name = defaultFileName
+		}
+
+		buffered, ok := o.File.(*bytes.Reader)
+		if !ok {
+			return nil, "", errors.New("order input File has not been buffered")
+		}
+
+		return io.NopCloser(io.NewSectionReader(buffered, 0, buffered.Size())), name, nil
+	}
+
+	if o.FilePath != "" {
+		file, err := os.Open(o.FilePath)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return file, filepath.Base(o.FilePath), nil
+	}
+
+	return nil, "", nil
+}
+
 // GetOrder gets a mailform order.
 func (c *Client) GetOrder(o string) (*Order, error) {
+	return c.GetOrderWithContext(context.Background(), o)
+}
+
+// GetOrderWithContext gets a mailform order.
+// The context is used for the request as well as any time spent waiting on the rate limiter.
+func (c *Client) GetOrderWithContext(ctx context.Context, o string) (*Order, error) {
 	getOrderEndpoint := fmt.Sprintf("%s/%s", ordersEndpoint, o)
 	order := &Order{}
-	mailformErr := &ErrMailform{}
+	info := &RequestInfo{
+		Method:   http.MethodGet,
+		Endpoint: getOrderEndpoint,
+	}
 
-	resp, err := c.restClient.R().SetResult(order).SetError(mailformErr).Get(getOrderEndpoint)
+	err := c.send(c.restClient.R().SetContext(ctx), info, order)
 	if err != nil {
 		return order, err
 	}
 
-	if resp.StatusCode() == http.StatusUnauthorized {
-		return order, &ErrMailform{
-			Err: struct {
-				Code    string `json:"code"`
-				Message string `json:"message"`
-			}{
-				Code:    strconv.Itoa(http.StatusUnauthorized),
-				Message: "unauthorized",
-			},
-		}
+	return order, nil
+}
+
+// CancelOrder cancels a mailform order that hasn't been fulfilled yet.
+func (c *Client) CancelOrder(o string) (*Order, error) {
+	return c.CancelOrderWithContext(context.Background(), o)
+}
+
+// CancelOrderWithContext cancels a mailform order that hasn't been fulfilled yet.
+// The context is used for the request as well as any time spent waiting on the rate limiter.
+// Cancellations are recorded in the audit log if one is configured. Dry runs return a cancelled test mode order without calling mailform.
+func (c *Client) CancelOrderWithContext(ctx context.Context, o string) (*Order, error) {
+	order := &Order{}
+	if c.dryRun {
+		order.Success = true
+		order.Data.ID = o
+		order.Data.State = StatusCancelled
+		order.Data.TestMode = true
+		return order, nil
 	}
 
-	if resp.IsError() {
-		return order, mailformErr
+	cancelOrderEndpoint := fmt.Sprintf("%s/%s/cancel", ordersEndpoint, o)
+	info := &RequestInfo{
+		Method:   http.MethodPost,
+		Endpoint: cancelOrderEndpoint,
 	}
 
-	// We can actually get 200 failed successfully and it's so dumb amirite
-	err = checkBodyForErr(resp.Body())
+	err := c.send(c.restClient.R().SetContext(ctx), info, order)
 	if err != nil {
 		return order, err
 	}
 
+	if c.audit != nil {
+		err = c.audit.RecordCancel(ctx, o, order.Data.CancellationReason)
+		if err != nil {
+			return order, fmt.Errorf("order %s cancelled but could not be audited: %w", o, err)
+		}
+	}
+
 	return order, nil
 }
 
 // ErrOrderInvalid is returned when order input is invalid
 type ErrOrderInvalid struct {
+	// field is the name of the OrderInput field that is invalid
+	field   string
 	message string
 }
 
@@ -321,12 +635,15 @@ func (e *ErrOrderInvalid) Error() string {
 }
 
 // Validate validates an order input by checking all required fields.
+// Which of the state and postcode are required, and the postcode format, depend on the country of each address.
+// Countries that aren't ISO 3166-1 names or codes need both. Orders with a check must have a valid check.
 // https://www.mailform.io/docs/api/#/orders
 func (o *OrderInput) Validate() error {
 	genericRejectionStr := "%s not provided, but is required"
 	// Validate service code
 	if supported := isServiceSupported(o.Service); !supported {
 		return &ErrOrderInvalid{
+			field:   "Service",
 			message: fmt.Sprintf("service code: '%s' not supported. Must be one of %v", o.Service, ServiceCodes),
 		}
 	}
@@ -334,6 +651,7 @@ func (o *OrderInput) Validate() error {
 	// Validate ToName
 	if o.ToName == "" {
 		return &ErrOrderInvalid{
+			field:   "ToName",
 			message: fmt.Sprintf(genericRejectionStr, "ToName"),
 		}
 	}
@@ -341,6 +659,7 @@ func (o *OrderInput) Validate() error {
 	// Validate ToAddress1
 	if o.ToAddress1 == "" {
 		return &ErrOrderInvalid{
+			field:   "ToAddress1",
 			message: fmt.Sprintf(genericRejectionStr, "ToAddress1"),
 		}
 	}
@@ -348,34 +667,21 @@ func (o *OrderInput) Validate() error {
 	// Validate ToCity
 	if o.ToCity == "" {
 		return &ErrOrderInvalid{
+			field:   "ToCity",
 			message: fmt.Sprintf(genericRejectionStr, "ToCity"),
 		}
 	}
 
-	// Validate ToState
-	if o.ToState == "" {
-		return &ErrOrderInvalid{
-			message: fmt.Sprintf(genericRejectionStr, "ToState"),
-		}
-	}
-
-	// Validate ToPostcode
-	if o.ToPostcode == "" {
-		return &ErrOrderInvalid{
-			message: fmt.Sprintf(genericRejectionStr, "ToPostcode"),
-		}
-	}
-
-	// Validate ToCountry
-	if o.ToCountry == "" {
-		return &ErrOrderInvalid{
-			message: fmt.Sprintf(genericRejectionStr, "ToCountry"),
-		}
+	// Validate ToState, ToPostcode and ToCountry by the rules of the country
+	err := validateAddressRegion("To", o.To())
+	if err != nil {
+		return err
 	}
 
 	// Validate FromName
 	if o.FromName == "" {
 		return &ErrOrderInvalid{
+			field:   "FromName",
 			message: fmt.Sprintf(genericRejectionStr, "FromName"),
 		}
 	}
@@ -383,6 +689,7 @@ func (o *OrderInput) Validate() error {
 	// Validate FromAddress1
 	if o.FromAddress1 == "" {
 		return &ErrOrderInvalid{
+			field:   "FromAddress1",
 			message: fmt.Sprintf(genericRejectionStr, "FromAddress1"),
 		}
 	}
@@ -390,29 +697,20 @@ func (o *OrderInput) Validate() error {
 	// Validate FromCity
 	if o.FromCity == "" {
 		return &ErrOrderInvalid{
+			field:   "FromCity",
 			message: fmt.Sprintf(genericRejectionStr, "FromCity"),
 		}
 	}
 
-	// Validate FromState
-	if o.FromState == "" {
-		return &ErrOrderInvalid{
-			message: fmt.Sprintf(genericRejectionStr, "FromState"),
-		}
-	}
-
-	// Validate FromPostcode
-	if o.FromPostcode == "" {
-		return &ErrOrderInvalid{
-			message: fmt.Sprintf(genericRejectionStr, "FromPostcode"),
-		}
+	// Validate FromState, FromPostcode and FromCountry by the rules of the country
+	err = validateAddressRegion("From", o.From())
+	if err != nil {
+		return err
 	}
 
-	// Validate FromCountry
-	if o.FromCountry == "" {
-		return &ErrOrderInvalid{
-			message: fmt.Sprintf(genericRejectionStr, "FromCountry"),
-		}
+	// Validate the check, if any check field is set they all must be
+	if check, ok := o.Check(); ok {
+		return check.Validate()
 	}
 
 	return nil
@@ -3,6 +3,8 @@ package mailform
 import (
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -618,3 +620,132 @@ func TestIsServiceSupported(t *testing.T) {
 		})
 	}
 }
+
+// testOrderInput returns an order input that passes validation.
+func testOrderInput() OrderInput {
+	return OrderInput{
+		Service:      "USPS_STANDARD",
+		ToName:       "some_name",
+		ToAddress1:   "some_address1",
+		ToCity:       "some_city",
+		ToState:      "some_state",
+		ToPostcode:   "some_postcode",
+		ToCountry:    "some_country",
+		FromName:     "some_fromname",
+		FromAddress1: "some_fromaddress1",
+		FromCity:     "some_fromcity",
+		FromState:    "some_fromstate",
+		FromPostcode: "some_frompostcode",
+		FromCountry:  "some_fromcountry",
+	}
+}
+
+// jsonResponder returns a responder that replies with a raw JSON body.
+func jsonResponder(status int, body string) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp := httpmock.NewStringResponse(status, body)
+		resp.Header.Set("Content-Type", "application/json")
+		return resp, nil
+	}
+}
+
+func TestSetFormValue(t *testing.T) {
+	expected := &OrderInput{
+		FilePath:          "some_file",
+		URL:               "some_url",
+		CustomerReference: "some_customer_reference",
+		Service:           "some_service",
+		Webhook:           "some_webhook",
+		Company:           "some_company",
+		Simplex:           true,
+		Color:             true,
+		Flat:              true,
+		Stamp:             true,
+		Message:           "some_message",
+		ToName:            "some_to.name",
+		ToOrganization:    "some_to.organization",
+		ToAddress1:        "some_address1",
+		ToAddress2:        "some_address2",
+		ToCity:            "some_to.city",
+		ToState:           "some_to.state",
+		ToPostcode:        "some_to.postcode",
+		ToCountry:         "some_to.country",
+		FromName:          "some_from.name",
+		FromOrganization:  "some_from.organization",
+		FromAddress1:      "some_from.address1",
+		FromAddress2:      "some_from.address2",
+		FromCity:          "some_from.city",
+		FromState:         "some_from.state",
+		FromPostcode:      "some_from.postcode",
+		FromCountry:       "some_from.country",
+		BankAccount:       "123456",
+		Amount:            1,
+		CheckName:         "some_checkname",
+		CheckNumber:       123456,
+		CheckMemo:         "some_memo",
+	}
+
+	// Round trip through form data
+	actual := &OrderInput{}
+	for k, v := range expected.FormData() {
+		assert.NoError(t, actual.SetFormValue(k, v))
+	}
+	assert.NoError(t, actual.SetFormValue("file", "some_file"))
+	assert.Equal(t, expected, actual)
+
+	// Every key is accepted
+	for _, key := range FormKeys {
+		assert.NotContains(t, fmt.Sprint(actual.SetFormValue(key, "")), "unknown")
+	}
+
+	assert.ErrorContains(t, actual.SetFormValue("unknown", "value"), "unknown form data key")
+	assert.ErrorContains(t, actual.SetFormValue("amount", "ten"), "invalid value for amount")
+	assert.ErrorContains(t, actual.SetFormValue("color", "maybe"), "invalid value for color")
+}
+
+func TestCancelOrder(t *testing.T) {
+	fakeEndpoint := fmt.Sprintf("%s%s/someID/cancel", DefaultBaseURL, ordersEndpoint)
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := OpenAuditLog(auditPath, "some_service", nil)
+	assert.NoError(t, err)
+
+	mailformClient, err := New(&Config{Audit: auditLog})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder(http.MethodPost, fakeEndpoint,
+		jsonResponder(http.StatusOK, `{"success":true,"data":{"id":"someID","state":"cancelled","cancellation_reason":"customer request"}}`))
+
+	order, err := mailformClient.CancelOrder("someID")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, order.Data.State)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	assert.NoError(t, auditLog.Close())
+
+	data, err := os.ReadFile(auditPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"action":"cancel_order"`)
+	assert.Contains(t, string(data), `"reason":"customer request"`)
+
+	// Errors are returned and not audited
+	httpmock.RegisterResponder(http.MethodPost, fakeEndpoint,
+		jsonResponder(http.StatusBadRequest, `{"error":{"code":"invalid_state","message":"order already fulfilled"}}`))
+	_, err = mailformClient.CancelOrder("someID")
+	mailErr := &ErrMailform{}
+	assert.ErrorAs(t, err, &mailErr)
+}
+
+func TestCancelOrderDryRun(t *testing.T) {
+	mailformClient, err := New(&Config{DryRun: true})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	order, err := mailformClient.CancelOrder("someID")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, order.Data.State)
+	assert.True(t, order.Data.TestMode)
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+}
@@ -0,0 +1,363 @@
+package mailform
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// Outbox journal operations
+	outboxOpEnqueue = "enqueue"
+	outboxOpAttempt = "attempt"
+	outboxOpSent    = "sent"
+	outboxOpFailed  = "failed"
+	outboxOpError   = "error"
+)
+
+var (
+	// ErrOutboxClosed is returned when using an outbox that has been closed.
+	ErrOutboxClosed = errors.New("outbox is closed")
+)
+
+// OutboxEntry is an order input that has been queued in the outbox along with the outcome of sending it.
+type OutboxEntry struct {
+	ID       string
+	Input    OrderInput
+	Enqueued time.Time
+	// Attempts is the number of times the entry has been submitted to mailform
+	Attempts int
+	// Sent is true once mailform has accepted the entry
+	Sent bool
+	// OrderID is the mailform order ID once the entry has been sent
+	OrderID string
+	// Err is the last error returned when sending the entry
+	Err string
+	// Failed is true if mailform rejected the entry and it won't be retried
+	Failed bool
+}
+
+// Pending returns true if the entry still needs to be sent.
+func (e *OutboxEntry) Pending() bool {
+	return !e.Sent && !e.Failed
+}
+
+// outboxRecord is a single line in the outbox journal.
+type outboxRecord struct {
+	Op      string      `json:"op"`
+	ID      string      `json:"id"`
+	Time    time.Time   `json:"time"`
+	Input   *OrderInput `json:"input,omitempty"`
+	OrderID string      `json:"order_id,omitempty"`
+	Err     string      `json:"error,omitempty"`
+}
+
+// Outbox is a durable queue of orders backed by an append-only journal file.
+// Every state change is appended to the journal and synced before moving on,
+// so a process that is killed mid batch can reopen the outbox and pick up where it left off.
+type Outbox struct {
+	mu sync.Mutex
+	// runMu stops concurrent runs from sending the same entry twice
+	runMu   sync.Mutex
+	file    *os.File
+	entries []*OutboxEntry
+	index   map[string]*OutboxEntry
+}
+
+// OpenOutbox opens the outbox journal at path, creating it if it doesn't exist, and replays it.
+func OpenOutbox(path string) (*Outbox, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	outbox := &Outbox{
+		file:  file,
+		index: map[string]*OutboxEntry{},
+	}
+
+	err = outbox.replay()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return outbox, nil
+}
+
+// replay rebuilds the outbox state from the journal.
+func (b *Outbox) replay() error {
+	return replayJournal(b.file, func(line int, data []byte) error {
+		record := &outboxRecord{}
+		err := json.Unmarshal(data, record)
+		if err != nil {
+			return fmt.Errorf("outbox journal line %d: %w", line, err)
+		}
+
+		b.apply(record)
+		return nil
+	})
+}
+
+// apply updates the in memory state with a journal record.
+func (b *Outbox) apply(r *outboxRecord) {
+	if r.Op == outboxOpEnqueue {
+		if r.Input == nil {
+			return
+		}
+		entry := &OutboxEntry{
+			ID:       r.ID,
+			Input:    *r.Input,
+			Enqueued: r.Time,
+		}
+		b.entries = append(b.entries, entry)
+		b.index[r.ID] = entry
+		return
+	}
+
+	entry, ok := b.index[r.ID]
+	if !ok {
+		return
+	}
+
+	switch r.Op {
+	case outboxOpAttempt:
+		entry.Attempts++
+	case outboxOpSent:
+		entry.Sent = true
+		entry.OrderID = r.OrderID
+		entry.Err = r.Err
+	case outboxOpFailed:
+		entry.Failed = true
+		entry.Err = r.Err
+	case outboxOpError:
+		entry.Err = r.Err
+	}
+}
+
+// write appends a record to the journal, syncs it to disk and applies it.
+// Callers must hold the lock.
+func (b *Outbox) write(r *outboxRecord) error {
+	if b.file == nil {
+		return ErrOutboxClosed
+	}
+
+	err := appendJournal(b.file, r)
+	if err != nil {
+		return err
+	}
+
+	b.apply(r)
+
+	return nil
+}
+
+// record locks the outbox and writes a record.
+func (b *Outbox) record(r *outboxRecord) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r.Time = time.Now()
+
+	return b.write(r)
+}
+
+// Enqueue adds an order input to the outbox and returns the ID of the outbox entry.
+func (b *Outbox) Enqueue(o OrderInput) (string, error) {
+	if o.File != nil {
+		return "", ErrFileNotPersisted
+	}
+
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	err = b.record(&outboxRecord{
+		Op:    outboxOpEnqueue,
+		ID:    id,
+		Input: &o,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Entry returns a copy of the outbox entry with the given ID.
+func (b *Outbox) Entry(id string) (OutboxEntry, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.index[id]
+	if !ok {
+		return OutboxEntry{}, false
+	}
+
+	return *entry, true
+}
+
+// Entries returns a copy of all outbox entries in the order they were enqueued.
+func (b *Outbox) Entries() []OutboxEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := make([]OutboxEntry, 0, len(b.entries))
+	for _, entry := range b.entries {
+		entries = append(entries, *entry)
+	}
+
+	return entries
+}
+
+// Pending returns a copy of the outbox entries that still need to be sent.
+func (b *Outbox) Pending() []OutboxEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := []OutboxEntry{}
+	for _, entry := range b.entries {
+		if entry.Pending() {
+			entries = append(entries, *entry)
+		}
+	}
+
+	return entries
+}
+
+// Run drains the outbox by sending each pending entry through CreateOrder.
+// Entries mailform rejects are marked as failed and won't be sent again.
+// Any other error, such as the network being down, being rate limited or running out of funds,
+// stops the run and leaves the entry pending for the next one.
+// An entry that was being sent when the process died was never recorded as sent, so it is sent again.
+func (b *Outbox) Run(ctx context.Context, c *Client) error {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+
+	for _, entry := range b.Pending() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := b.record(&outboxRecord{
+			Op: outboxOpAttempt,
+			ID: entry.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		order, err := c.CreateOrderWithContext(ctx, entry.Input)
+		if err != nil && !isOrderCreated(order) {
+			if !isOrderRejected(err) {
+				recordErr := b.record(&outboxRecord{
+					Op:  outboxOpError,
+					ID:  entry.ID,
+					Err: err.Error(),
+				})
+				if recordErr != nil {
+					return recordErr
+				}
+				return err
+			}
+
+			err = b.record(&outboxRecord{
+				Op:  outboxOpFailed,
+				ID:  entry.ID,
+				Err: err.Error(),
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		record := &outboxRecord{
+			Op:      outboxOpSent,
+			ID:      entry.ID,
+			OrderID: order.Data.ID,
+		}
+		// The order exists even though something went wrong afterwards
+		if err != nil {
+			record.Err = err.Error()
+		}
+
+		err = b.record(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the outbox journal.
+func (b *Outbox) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.file == nil {
+		return nil
+	}
+
+	err := b.file.Close()
+	b.file = nil
+
+	return err
+}
+
+// isOrderRejected checks if an error means the order will never be accepted as is.
+// Errors that could go away on their own, or by fixing the account rather than the order, don't count.
+func isOrderRejected(err error) bool {
+	var invalidErr *ErrOrderInvalid
+	if errors.As(err, &invalidErr) {
+		return true
+	}
+	if errors.Is(err, ErrAddressUndeliverable) || errors.Is(err, ErrCheckNumberUsed) {
+		return true
+	}
+
+	var suppressedErr *ErrRecipientSuppressed
+	if errors.As(err, &suppressedErr) {
+		return true
+	}
+
+	// The order input is waiting on approval, submitting it again would only request approval again
+	var approvalErr *ErrApprovalRequired
+	if errors.As(err, &approvalErr) || errors.Is(err, ErrRequesterRequired) {
+		return true
+	}
+
+	var mailformErr *ErrMailform
+	if !errors.As(err, &mailformErr) {
+		return false
+	}
+
+	return !mailformErr.Retryable() &&
+		!errors.Is(mailformErr, ErrUnauthorized) &&
+		!errors.Is(mailformErr, ErrInsufficientFunds)
+}
+
+// isOrderCreated checks if an order was created, even if an error was returned alongside it.
+func isOrderCreated(order *Order) bool {
+	return order != nil && order.Data.ID != ""
+}
+
+// newID generates a random ID for locally stored records.
+func newID() (string, error) {
+	b := make([]byte, 8)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
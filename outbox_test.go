@@ -0,0 +1,131 @@
+package mailform
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxRun(t *testing.T) {
+	fakeEndpoint := fmt.Sprintf("%s%s", DefaultBaseURL, ordersEndpoint)
+	journal := filepath.Join(t.TempDir(), "outbox.jsonl")
+	mailformClient, err := New(&Config{})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	calls := 0
+	httpmock.RegisterResponder(http.MethodPost, fakeEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			calls++
+			resp := httpmock.NewStringResponse(200, fmt.Sprintf(`{"success":true,"data":{"id":"order%d"}}`, calls))
+			resp.Header.Set("Content-Type", "application/json")
+			return resp, nil
+		})
+
+	outbox, err := OpenOutbox(journal)
+	assert.NoError(t, err)
+
+	first, err := outbox.Enqueue(testOrderInput())
+	assert.NoError(t, err)
+	invalid := testOrderInput()
+	invalid.Service = "FAKE"
+	rejected, err := outbox.Enqueue(invalid)
+	assert.NoError(t, err)
+	assert.Len(t, outbox.Pending(), 2)
+
+	err = outbox.Run(context.Background(), mailformClient)
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.Close())
+
+	// Reopen to ensure state survives a restart
+	outbox, err = OpenOutbox(journal)
+	assert.NoError(t, err)
+	defer outbox.Close()
+
+	entry, ok := outbox.Entry(first)
+	assert.True(t, ok)
+	assert.Equal(t, "order1", entry.OrderID)
+	assert.Equal(t, 1, entry.Attempts)
+	assert.Equal(t, "some_name", entry.Input.ToName)
+
+	entry, ok = outbox.Entry(rejected)
+	assert.True(t, ok)
+	assert.True(t, entry.Failed)
+	assert.Contains(t, entry.Err, "service code")
+
+	// Nothing left to send so nothing is resent
+	assert.Empty(t, outbox.Pending())
+	err = outbox.Run(context.Background(), mailformClient)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestOutboxRunStopsOnTransportError(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "outbox.jsonl")
+	mailformClient, err := New(&Config{})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+	// No responders registered so every request fails in transport
+
+	outbox, err := OpenOutbox(journal)
+	assert.NoError(t, err)
+	defer outbox.Close()
+
+	id, err := outbox.Enqueue(testOrderInput())
+	assert.NoError(t, err)
+
+	err = outbox.Run(context.Background(), mailformClient)
+	assert.Error(t, err)
+
+	entry, _ := outbox.Entry(id)
+	assert.True(t, entry.Pending())
+	assert.NotEmpty(t, entry.Err)
+}
+
+func TestOpenOutboxTruncatesPartialWrite(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	outbox, err := OpenOutbox(journal)
+	assert.NoError(t, err)
+	id, err := outbox.Enqueue(testOrderInput())
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.Close())
+
+	// Simulate being killed mid write
+	f, err := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0o600)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"op":"sent","id":"` + id)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	outbox, err = OpenOutbox(journal)
+	assert.NoError(t, err)
+	_, err = outbox.Enqueue(testOrderInput())
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.Close())
+
+	// Journal is readable again after further writes
+	outbox, err = OpenOutbox(journal)
+	assert.NoError(t, err)
+	defer outbox.Close()
+	assert.Len(t, outbox.Pending(), 2)
+}
+
+func TestOpenOutboxCorruptJournal(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "outbox.jsonl")
+	err := os.WriteFile(journal, []byte("not json\n"), 0o600)
+	assert.NoError(t, err)
+
+	_, err = OpenOutbox(journal)
+	assert.ErrorContains(t, err, "outbox journal line 1")
+}
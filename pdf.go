@@ -0,0 +1,276 @@
+package mailform
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	// Register the image formats that can be added to documents
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// pointsPerInch is the number of PDF points in an inch
+	pointsPerInch = 72
+	// US letter page size in points
+	letterPageWidth  = 8.5 * pointsPerInch
+	letterPageHeight = 11 * pointsPerInch
+)
+
+// pdfDocument builds a minimal PDF 1.4 file.
+// Output only depends on what is added to it, so the same document always produces the same bytes.
+type pdfDocument struct {
+	// objects are the bodies of the indirect objects, object n is at index n-1
+	objects [][]byte
+	// pageTree is the object number of the page tree
+	pageTree int
+	// pages are the object numbers of the pages in order
+	pages []int
+}
+
+// newPDFDocument returns an empty PDF document.
+func newPDFDocument() *pdfDocument {
+	d := &pdfDocument{}
+	// Reserve the page tree, it's written once all the pages are known
+	d.pageTree = d.add(nil)
+
+	return d
+}
+
+// add adds an indirect object and returns its object number.
+func (d *pdfDocument) add(body []byte) int {
+	d.objects = append(d.objects, body)
+
+	return len(d.objects)
+}
+
+// addStream adds a compressed stream object. dict holds any extra dictionary entries.
+func (d *pdfDocument) addStream(dict string, data []byte) (int, error) {
+	compressed := &bytes.Buffer{}
+	w := zlib.NewWriter(compressed)
+	_, err := w.Write(data)
+	if err != nil {
+		return 0, err
+	}
+	err = w.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	return d.addRawStream(dict+" /Filter /FlateDecode", compressed.Bytes()), nil
+}
+
+// addRawStream adds a stream object that is already encoded. dict holds any extra dictionary entries, including the filter.
+func (d *pdfDocument) addRawStream(dict string, data []byte) int {
+	body := &bytes.Buffer{}
+	fmt.Fprintf(body, "<< %s /Length %d >>\nstream\n", strings.TrimSpace(dict), len(data))
+	body.Write(data)
+	body.WriteString("\nendstream")
+
+	return d.add(body.Bytes())
+}
+
+// addImage adds a PNG or JPEG image object and returns its object number.
+// Baseline RGB and grayscale JPEGs are embedded as is, anything else is decoded and stored as RGB
+// with transparency flattened onto white, since printers don't do transparency.
+func (d *pdfDocument) addImage(data []byte) (int, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /BitsPerComponent 8", config.Width, config.Height)
+	if format == "jpeg" {
+		switch config.ColorModel {
+		case color.YCbCrModel:
+			return d.addRawStream(dict+" /ColorSpace /DeviceRGB /Filter /DCTDecode", data), nil
+		case color.GrayModel:
+			return d.addRawStream(dict+" /ColorSpace /DeviceGray /Filter /DCTDecode", data), nil
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+
+	bounds := img.Bounds()
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Colors are alpha premultiplied, so adding the missing alpha puts them on white
+			r, g, b, a := img.At(x, y).RGBA()
+			pixels = append(pixels, byte((r+0xffff-a)>>8), byte((g+0xffff-a)>>8), byte((b+0xffff-a)>>8))
+		}
+	}
+
+	return d.addStream(dict+" /ColorSpace /DeviceRGB", pixels)
+}
+
+// addPage adds a page of the given size in points with its resource dictionary and content stream.
+func (d *pdfDocument) addPage(width, height float64, resources string, content []byte) error {
+	contents, err := d.addStream("", content)
+	if err != nil {
+		return err
+	}
+
+	page := d.add([]byte(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %s /Contents %d 0 R >>",
+		d.pageTree, pdfNumber(width), pdfNumber(height), resources, contents)))
+	d.pages = append(d.pages, page)
+
+	return nil
+}
+
+// bytes writes out the document.
+func (d *pdfDocument) bytes() []byte {
+	kids := make([]string, 0, len(d.pages))
+	for _, page := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	d.objects[d.pageTree-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	catalog := d.add([]byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", d.pageTree)))
+
+	out := &bytes.Buffer{}
+	// The comment with high bytes tells tools the file is binary
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(d.objects))
+	for i, body := range d.objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(out, "%d 0 obj\n", i+1)
+		out.Write(body)
+		out.WriteString("\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, catalog, xref)
+
+	// Drop the catalog so bytes can be called again
+	d.objects = d.objects[:len(d.objects)-1]
+
+	return out.Bytes()
+}
+
+// pdfNumber formats a number for a PDF, rounded to 2 decimal places.
+func pdfNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// winAnsiSpecial maps the characters outside of Latin-1 that WinAnsiEncoding supports to their codes.
+var winAnsiSpecial = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b,
+	'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// winAnsi encodes a rune in WinAnsiEncoding, the encoding used for the standard fonts.
+// Characters that can't be encoded become a question mark.
+func winAnsi(r rune) byte {
+	if b, ok := winAnsiSpecial[r]; ok {
+		return b
+	}
+	if (r >= 0x20 && r <= 0x7e) || (r >= 0xa0 && r <= 0xff) {
+		return byte(r)
+	}
+
+	return '?'
+}
+
+// pdfString encodes text as a PDF literal string in WinAnsiEncoding.
+func pdfString(s string) string {
+	out := &strings.Builder{}
+	out.WriteByte('(')
+	for _, r := range s {
+		b := winAnsi(r)
+		switch {
+		case b == '(' || b == ')' || b == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(b)
+		case b >= 0x20 && b <= 0x7e:
+			out.WriteByte(b)
+		default:
+			fmt.Fprintf(out, "\\%03o", b)
+		}
+	}
+	out.WriteByte(')')
+
+	return out.String()
+}
+
+// pdfFont is one of the standard 14 fonts every PDF reader has, so nothing needs to be embedded.
+type pdfFont struct {
+	// name is the resource name the font is referred to by in content streams
+	name     string
+	baseFont string
+	// widths are the widths of the printable ASCII characters in thousandths of the font size
+	widths [95]int
+	// defaultWidth is used for every other character
+	defaultWidth int
+}
+
+var (
+	// helvetica is the regular body font
+	helvetica = &pdfFont{
+		name:     "F1",
+		baseFont: "Helvetica",
+		widths: [95]int{
+			278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+			556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+			1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+			667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+			333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+			556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+		},
+		defaultWidth: 556,
+	}
+	// helveticaBold is used for headings and bold text
+	helveticaBold = &pdfFont{
+		name:     "F2",
+		baseFont: "Helvetica-Bold",
+		widths: [95]int{
+			278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+			556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+			975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+			667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+			333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+			611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+		},
+		defaultWidth: 611,
+	}
+)
+
+// width returns the width of text in points at the font size.
+func (f *pdfFont) width(text string, size float64) float64 {
+	total := 0
+	for _, r := range text {
+		b := winAnsi(r)
+		if b >= 0x20 && b <= 0x7e {
+			total += f.widths[b-0x20]
+			continue
+		}
+		total += f.defaultWidth
+	}
+
+	return float64(total) * size / 1000
+}
+
+// addFonts adds font objects and returns the font entry of a resource dictionary that refers to them.
+func (d *pdfDocument) addFonts(fonts ...*pdfFont) string {
+	refs := make([]string, 0, len(fonts))
+	for _, f := range fonts {
+		n := d.add([]byte(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.baseFont)))
+		refs = append(refs, fmt.Sprintf("/%s %d 0 R", f.name, n))
+	}
+
+	return fmt.Sprintf("/Font << %s >>", strings.Join(refs, " "))
+}
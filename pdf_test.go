@@ -0,0 +1,120 @@
+package mailform
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// pdfStreamPattern matches the dictionary and start of stream objects
+	pdfStreamPattern = regexp.MustCompile(`<< ([^\n]*)/Length (\d+) >>\nstream\n`)
+	// pdfStartXrefPattern matches the offset of the cross reference table
+	pdfStartXrefPattern = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+)
+
+// pdfStreams returns the decompressed data of every flate stream in a PDF, in order.
+func pdfStreams(t *testing.T, pdf []byte) []string {
+	t.Helper()
+
+	streams := []string{}
+	for _, match := range pdfStreamPattern.FindAllSubmatchIndex(pdf, -1) {
+		if !bytes.Contains(pdf[match[2]:match[3]], []byte("/FlateDecode")) {
+			continue
+		}
+		length, err := strconv.Atoi(string(pdf[match[4]:match[5]]))
+		require.NoError(t, err)
+
+		r, err := zlib.NewReader(bytes.NewReader(pdf[match[1] : match[1]+length]))
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+
+		streams = append(streams, string(data))
+	}
+
+	return streams
+}
+
+// assertPDFXref checks every entry of the cross reference table points at its object.
+func assertPDFXref(t *testing.T, pdf []byte) {
+	t.Helper()
+
+	match := pdfStartXrefPattern.FindSubmatch(pdf)
+	require.NotNil(t, match)
+	xref, err := strconv.Atoi(string(match[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n0 ")))
+
+	var count int
+	_, err = fmt.Sscanf(string(pdf[xref:]), "xref\n0 %d\n", &count)
+	require.NoError(t, err)
+
+	entries := pdf[bytes.Index(pdf[xref:], []byte("0000000000 65535 f \n"))+xref+20:]
+	for n := 1; n < count; n++ {
+		offset, err := strconv.Atoi(string(entries[(n-1)*20 : (n-1)*20+10]))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", n))), "object %d", n)
+	}
+}
+
+func TestPDFDocument(t *testing.T) {
+	doc := newPDFDocument()
+	resources := "<< " + doc.addFonts(helvetica) + " >>"
+	require.NoError(t, doc.addPage(letterPageWidth, letterPageHeight, resources, []byte("BT /F1 12 Tf 72 720 Td (one) Tj ET")))
+	require.NoError(t, doc.addPage(letterPageWidth, letterPageHeight, resources, []byte("BT /F1 12 Tf 72 720 Td (two) Tj ET")))
+
+	pdf := doc.bytes()
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	assert.Contains(t, string(pdf), "/Type /Pages /Kids [4 0 R 6 0 R] /Count 2")
+	assert.Contains(t, string(pdf), "/MediaBox [0 0 612 792]")
+	assert.Contains(t, string(pdf), "/Root 7 0 R")
+	assertPDFXref(t, pdf)
+	assert.Equal(t, []string{"BT /F1 12 Tf 72 720 Td (one) Tj ET", "BT /F1 12 Tf 72 720 Td (two) Tj ET"}, pdfStreams(t, pdf))
+
+	// Writing the document out again gives the same bytes
+	assert.Equal(t, pdf, doc.bytes())
+}
+
+func TestPDFString(t *testing.T) {
+	type test struct {
+		input    string
+		expected string
+	}
+
+	tests := []test{
+		{
+			input:    "some text",
+			expected: "(some text)",
+		},
+		{
+			input:    `(some) \text`,
+			expected: `(\(some\) \\text)`,
+		},
+		{
+			input:    "café “quoted” €5 ✓",
+			expected: `(caf\351 \223quoted\224 \2005 ?)`,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, pdfString(test.input))
+	}
+}
+
+func TestPDFFontWidth(t *testing.T) {
+	assert.Equal(t, 0.0, helvetica.width("", 10))
+	// space, W and ~ from the font metrics
+	assert.Equal(t, 2.78, helvetica.width(" ", 10))
+	assert.Equal(t, 9.44, helvetica.width("W", 10))
+	assert.Equal(t, 5.84, helvetica.width("~", 10))
+	assert.Equal(t, 5.84, helveticaBold.width("~", 10))
+	assert.Greater(t, helveticaBold.width("some text", 10), helvetica.width("some text", 10))
+}
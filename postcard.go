@@ -0,0 +1,222 @@
+package mailform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+const (
+	// PostcardService is the service code of postcard orders
+	PostcardService = "USPS_POSTCARD"
+	// postcardBleed is how far the picture must extend past the trim on every side, in inches
+	postcardBleed = 0.125
+	// postcardSafeMargin is how far inside the trim the message is kept, in inches
+	postcardSafeMargin = 0.1875
+	// postcardGutter is the space between the message and the address zone, in inches
+	postcardGutter = 0.25
+	// postcardAspectTolerance is how far the picture's aspect ratio can be from the postcard's
+	postcardAspectTolerance = 0.005
+	// defaultPostcardMinDPI is the lowest resolution pictures print sharply at
+	defaultPostcardMinDPI = 300
+	// defaultPostcardFontSize is the largest message font size in points
+	defaultPostcardFontSize = 10
+	// minPostcardFontSize is the smallest font size a message is shrunk to so it fits
+	minPostcardFontSize = 6
+	// postcardFileName is the name postcards are uploaded as
+	postcardFileName = "postcard.pdf"
+)
+
+var (
+	// ErrInvalidPostcardImage is returned when a postcard picture is the wrong format, shape or resolution.
+	ErrInvalidPostcardImage = errors.New("invalid postcard image")
+	// ErrMessageTooLong is returned when a postcard message doesn't fit on the postcard even at the smallest font size.
+	ErrMessageTooLong = errors.New("message is too long to fit on the postcard")
+)
+
+// PostcardSize is a standard postcard format. Sizes are in inches, landscape.
+type PostcardSize struct {
+	Name string
+	// Width and Height are the trim size, the size of the finished postcard
+	Width  float64
+	Height float64
+	// AddressWidth is the width of the area on the right of the message side that is kept clear for the address and postage
+	AddressWidth float64
+}
+
+var (
+	// PostcardSize4x6 is the standard 4x6 postcard, the only size that qualifies for postcard rates.
+	PostcardSize4x6 = PostcardSize{Name: "4x6", Width: 6, Height: 4, AddressWidth: 3.25}
+	// PostcardSize6x9 is the standard 6x9 postcard.
+	PostcardSize6x9 = PostcardSize{Name: "6x9", Width: 9, Height: 6, AddressWidth: 4}
+	// PostcardSize6x11 is the standard 6x11 postcard.
+	PostcardSize6x11 = PostcardSize{Name: "6x11", Width: 11, Height: 6, AddressWidth: 4}
+)
+
+// bleedWidth returns the width of the postcard including bleed, in inches.
+func (s PostcardSize) bleedWidth() float64 {
+	return s.Width + 2*postcardBleed
+}
+
+// bleedHeight returns the height of the postcard including bleed, in inches.
+func (s PostcardSize) bleedHeight() float64 {
+	return s.Height + 2*postcardBleed
+}
+
+// PostcardBuilder composes postcards from a picture and a message.
+// The picture side is the picture scaled to the full page. The message side has the message on the left
+// and the area on the right kept clear, mailform prints the address and postage there.
+// Both pages are the trim size plus a 1/8 inch bleed on every side.
+type PostcardBuilder struct {
+	// Size is the postcard format, defaults to PostcardSize4x6
+	Size PostcardSize
+	// MinDPI is the lowest picture resolution accepted, defaults to 300
+	MinDPI float64
+	// FontSize is the message font size in points, defaults to 10. Long messages are shrunk down to 6 points to fit.
+	FontSize float64
+}
+
+// size returns the postcard format.
+func (b *PostcardBuilder) size() PostcardSize {
+	if b.Size.Width == 0 || b.Size.Height == 0 {
+		return PostcardSize4x6
+	}
+
+	return b.Size
+}
+
+// ValidateImage checks a picture is a PNG or JPEG with the shape of the postcard including bleed
+// and enough resolution to print sharply.
+func (b *PostcardBuilder) ValidateImage(picture []byte) error {
+	config, format, err := image.DecodeConfig(bytes.NewReader(picture))
+	if err != nil {
+		return fmt.Errorf("%w: must be a PNG or JPEG: %s", ErrInvalidPostcardImage, err)
+	}
+	if format != "png" && format != "jpeg" {
+		return fmt.Errorf("%w: must be a PNG or JPEG, not %s", ErrInvalidPostcardImage, format)
+	}
+
+	size := b.size()
+	if config.Height > config.Width {
+		return fmt.Errorf("%w: %dx%d pixels is portrait, %s postcards are landscape", ErrInvalidPostcardImage, config.Width, config.Height, size.Name)
+	}
+
+	aspect := float64(config.Width) / float64(config.Height)
+	bleedAspect := size.bleedWidth() / size.bleedHeight()
+	if math.Abs(aspect-bleedAspect)/bleedAspect > postcardAspectTolerance {
+		trimAspect := size.Width / size.Height
+		if math.Abs(aspect-trimAspect)/trimAspect <= postcardAspectTolerance {
+			return fmt.Errorf("%w: %dx%d pixels is the trim size with no bleed, the picture must be %vx%v inches to include a %v inch bleed on every side",
+				ErrInvalidPostcardImage, config.Width, config.Height, size.bleedWidth(), size.bleedHeight(), postcardBleed)
+		}
+		return fmt.Errorf("%w: %dx%d pixels is the wrong shape, the picture must be %vx%v inches including bleed",
+			ErrInvalidPostcardImage, config.Width, config.Height, size.bleedWidth(), size.bleedHeight())
+	}
+
+	minDPI := b.MinDPI
+	if minDPI <= 0 {
+		minDPI = defaultPostcardMinDPI
+	}
+	dpi := float64(config.Width) / size.bleedWidth()
+	if dpi < minDPI {
+		return fmt.Errorf("%w: %dx%d pixels is %.0f DPI, at least %.0f DPI (%.0fx%.0f pixels) is needed",
+			ErrInvalidPostcardImage, config.Width, config.Height, dpi, minDPI, math.Ceil(size.bleedWidth()*minDPI), math.Ceil(size.bleedHeight()*minDPI))
+	}
+
+	return nil
+}
+
+// Render composes a two page postcard PDF, the picture side then the message side.
+// The message is laid out as simple markdown, the same as letters.
+func (b *PostcardBuilder) Render(picture io.Reader, message string) (io.Reader, error) {
+	data, err := io.ReadAll(picture)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.ValidateImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	size := b.size()
+	width := size.bleedWidth() * pointsPerInch
+	height := size.bleedHeight() * pointsPerInch
+
+	back, err := b.layoutMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := newPDFDocument()
+	img, err := doc.addImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	front := fmt.Sprintf("q %s 0 0 %s 0 0 cm /Im1 Do Q\n", pdfNumber(width), pdfNumber(height))
+	err = doc.addPage(width, height, fmt.Sprintf("<< /XObject << /Im1 %d 0 R >> >>", img), []byte(front))
+	if err != nil {
+		return nil, err
+	}
+
+	resources := "<< " + doc.addFonts(helvetica, helveticaBold) + " >>"
+	err = doc.addPage(width, height, resources, back)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(doc.bytes()), nil
+}
+
+// layoutMessage lays out the message side, shrinking the font until the message fits.
+func (b *PostcardBuilder) layoutMessage(message string) ([]byte, error) {
+	size := b.size()
+	left := (postcardBleed + postcardSafeMargin) * pointsPerInch
+	right := (postcardBleed + size.Width - size.AddressWidth - postcardGutter) * pointsPerInch
+
+	fontSize := b.FontSize
+	if fontSize <= 0 {
+		fontSize = defaultPostcardFontSize
+	}
+
+	for {
+		l := &letterLayout{
+			left:   left,
+			top:    (postcardBleed + size.Height - postcardSafeMargin) * pointsPerInch,
+			bottom: (postcardBleed + postcardSafeMargin) * pointsPerInch,
+			width:  right - left,
+		}
+		l.newPage()
+		l.markdown(message, fontSize)
+
+		if len(l.pages) == 1 {
+			return l.pages[0].Bytes(), nil
+		}
+		if fontSize <= minPostcardFontSize {
+			return nil, ErrMessageTooLong
+		}
+		fontSize = math.Max(fontSize-0.5, minPostcardFontSize)
+	}
+}
+
+// Build composes a postcard and returns the order input with the postcard as its File and the service set to postcard.
+// Message is cleared since the message is already printed on the postcard.
+func (b *PostcardBuilder) Build(o OrderInput, picture io.Reader, message string) (OrderInput, error) {
+	postcard, err := b.Render(picture, message)
+	if err != nil {
+		return o, err
+	}
+
+	o.Service = PostcardService
+	o.Message = ""
+	o.File = postcard
+	if o.FileName == "" {
+		o.FileName = postcardFileName
+	}
+
+	return o, nil
+}
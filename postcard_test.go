@@ -0,0 +1,179 @@
+package mailform
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPicture returns an encoded picture of the given size and format.
+func testPicture(t *testing.T, width, height int, format string) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: uint8(255 * x / width)})
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(buf, img)
+	case "jpeg":
+		err = jpeg.Encode(buf, img, nil)
+	default:
+		_, err = buf.WriteString("GIF89a not really")
+	}
+	require.NoError(t, err)
+
+	return buf.Bytes()
+}
+
+func TestPostcardBuilderValidateImage(t *testing.T) {
+	type test struct {
+		description string
+		builder     *PostcardBuilder
+		picture     []byte
+		expectedErr string
+	}
+
+	lowDPI := &PostcardBuilder{MinDPI: 20}
+
+	tests := []test{
+		{
+			description: "PNG with bleed",
+			builder:     lowDPI,
+			picture:     testPicture(t, 125, 85, "png"),
+		},
+		{
+			description: "JPEG with bleed",
+			builder:     lowDPI,
+			picture:     testPicture(t, 125, 85, "jpeg"),
+		},
+		{
+			description: "6x11 with bleed",
+			builder:     &PostcardBuilder{Size: PostcardSize6x11, MinDPI: 20},
+			picture:     testPicture(t, 225, 125, "png"),
+		},
+		{
+			description: "Not an image",
+			builder:     lowDPI,
+			picture:     testPicture(t, 125, 85, "gif"),
+			expectedErr: "invalid postcard image: must be a PNG or JPEG",
+		},
+		{
+			description: "Portrait",
+			builder:     lowDPI,
+			picture:     testPicture(t, 85, 125, "png"),
+			expectedErr: "invalid postcard image: 85x125 pixels is portrait, 4x6 postcards are landscape",
+		},
+		{
+			description: "No bleed",
+			builder:     lowDPI,
+			picture:     testPicture(t, 120, 80, "png"),
+			expectedErr: "invalid postcard image: 120x80 pixels is the trim size with no bleed, the picture must be 6.25x4.25 inches to include a 0.125 inch bleed on every side",
+		},
+		{
+			description: "Wrong shape",
+			builder:     lowDPI,
+			picture:     testPicture(t, 200, 85, "png"),
+			expectedErr: "invalid postcard image: 200x85 pixels is the wrong shape, the picture must be 6.25x4.25 inches including bleed",
+		},
+		{
+			description: "Resolution too low",
+			builder:     &PostcardBuilder{},
+			picture:     testPicture(t, 125, 85, "png"),
+			expectedErr: "invalid postcard image: 125x85 pixels is 20 DPI, at least 300 DPI (1875x1275 pixels) is needed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := test.builder.ValidateImage(test.picture)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidPostcardImage)
+			assert.ErrorContains(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestPostcardBuilderBuild(t *testing.T) {
+	builder := &PostcardBuilder{MinDPI: 20}
+
+	for _, format := range []string{"png", "jpeg"} {
+		t.Run(format, func(t *testing.T) {
+			input := testOrderInput()
+			input.Message = "some_message"
+			input, err := builder.Build(input, bytes.NewReader(testPicture(t, 125, 85, format)), "Greetings from **somewhere**!\n\nWish you were here")
+			require.NoError(t, err)
+
+			assert.Equal(t, PostcardService, input.Service)
+			assert.Equal(t, "", input.Message)
+			assert.Equal(t, postcardFileName, input.FileName)
+			assert.NoError(t, input.Validate())
+
+			pdf, err := io.ReadAll(input.File)
+			require.NoError(t, err)
+			assertPDFXref(t, pdf)
+			assert.Contains(t, string(pdf), "/Count 2")
+			// Both pages are 4x6 plus bleed
+			assert.Equal(t, 2, strings.Count(string(pdf), "/MediaBox [0 0 450 306]"))
+			assert.Contains(t, string(pdf), "/Subtype /Image /Width 125 /Height 85")
+
+			if format == "jpeg" {
+				// JPEGs are embedded as is
+				assert.Contains(t, string(pdf), "/Filter /DCTDecode")
+			}
+
+			streams := pdfStreams(t, pdf)
+			front := streams[len(streams)-2]
+			back := streams[len(streams)-1]
+			assert.Equal(t, "q 450 0 0 306 0 0 cm /Im1 Do Q\n", front)
+			assert.Contains(t, back, "/F1 10 Tf 22.5 273.5 Td (Greetings from) Tj")
+			assert.Contains(t, back, "/F2 10 Tf 88.63 273.5 Td ( somewhere) Tj")
+			assert.Contains(t, back, "(Wish you were here) Tj")
+		})
+	}
+}
+
+func TestPostcardBuilderPNGOnWhite(t *testing.T) {
+	doc := newPDFDocument()
+	_, err := doc.addImage(testPicture(t, 4, 1, "png"))
+	require.NoError(t, err)
+
+	streams := pdfStreams(t, doc.bytes())
+	require.Len(t, streams, 1)
+	// Transparent red fades to white
+	assert.Equal(t, []byte{255, 255, 255, 255, 192, 192, 255, 128, 128, 255, 64, 64}, []byte(streams[0]))
+}
+
+func TestPostcardBuilderMessageFit(t *testing.T) {
+	builder := &PostcardBuilder{MinDPI: 20}
+	picture := testPicture(t, 125, 85, "png")
+
+	// Long messages are shrunk to fit
+	postcard, err := builder.Render(bytes.NewReader(picture), strings.Repeat("A longer message to fit. ", 40))
+	require.NoError(t, err)
+	pdf, err := io.ReadAll(postcard)
+	require.NoError(t, err)
+	streams := pdfStreams(t, pdf)
+	assert.NotContains(t, streams[len(streams)-1], "/F1 10 Tf")
+
+	// Until they can't be
+	_, err = builder.Render(bytes.NewReader(picture), strings.Repeat("A much too long message to fit. ", 200))
+	assert.ErrorIs(t, err, ErrMessageTooLong)
+}
@@ -0,0 +1,248 @@
+package mailform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// Price types used in price breakdowns
+	PriceTypePostage    = "postage"
+	PriceTypePrinting   = "printing"
+	PriceTypeColor      = "color"
+	PriceTypeExtraPages = "extra_pages"
+	PriceTypeCheck      = "check"
+	PriceTypeCertified  = "certified"
+)
+
+var (
+	// DefaultPriceTable is the price table used by EstimatePrice.
+	// Prices are approximate and in cents. Replace it, or use PriceTable.Estimate, with prices from your own account
+	// and use PriceTable.Calibrate to find where it has drifted from what mailform actually charges.
+	DefaultPriceTable = &PriceTable{
+		Version: "2022-09",
+		Postage: map[string]int{
+			"FEDEX_OVERNIGHT":                 3500,
+			"USPS_PRIORITY_EXPRESS":           3000,
+			"USPS_PRIORITY":                   1000,
+			"USPS_CERTIFIED_PHYSICAL_RECEIPT": 60,
+			"USPS_CERTIFIED_RECEIPT":          60,
+			"USPS_CERTIFIED":                  60,
+			"USPS_FIRST_CLASS":                60,
+			"USPS_STANDARD":                   45,
+			"USPS_POSTCARD":                   44,
+		},
+		Printing:      100,
+		IncludedPages: 5,
+		ExtraPage:     20,
+		ColorPerPage:  30,
+		Check:         200,
+		Certified: map[string]int{
+			"USPS_CERTIFIED_PHYSICAL_RECEIPT": 700,
+			"USPS_CERTIFIED_RECEIPT":          500,
+			"USPS_CERTIFIED":                  400,
+		},
+	}
+)
+
+// Price is a single line of a price breakdown, the same shape as Order line item pricing.
+type Price struct {
+	Type  string `json:"type"`
+	Value int    `json:"value"`
+}
+
+// PriceEstimate is the estimated cost of an order.
+type PriceEstimate struct {
+	// TableVersion is the version of the price table used for the estimate
+	TableVersion string  `json:"table_version"`
+	Pricing      []Price `json:"pricing"`
+	Total        int     `json:"total"`
+}
+
+// PriceTable is the set of prices used to estimate what an order will cost. All prices are in cents.
+type PriceTable struct {
+	Version string `json:"version"`
+	// Postage is the price of postage per service code for up to IncludedPages pages
+	Postage map[string]int `json:"postage"`
+	// Printing is the base price for printing a letter
+	Printing int `json:"printing"`
+	// IncludedPages is the number of pages covered by the postage and printing prices
+	IncludedPages int `json:"included_pages"`
+	// ExtraPage is the price of every page over IncludedPages
+	ExtraPage int `json:"extra_page"`
+	// ColorPerPage is the extra price of each page printed in color
+	ColorPerPage int `json:"color_per_page"`
+	// Check is the price of including a check
+	Check int `json:"check"`
+	// Certified is the price of certified mail fees per service code
+	Certified map[string]int `json:"certified"`
+}
+
+// LoadPriceTable reads a JSON price table.
+func LoadPriceTable(r io.Reader) (*PriceTable, error) {
+	table := &PriceTable{}
+
+	err := json.NewDecoder(r).Decode(table)
+	if err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// EstimatePrice estimates the cost of an order with pageCount pages using the DefaultPriceTable.
+func EstimatePrice(o OrderInput, pageCount int) (*PriceEstimate, error) {
+	return DefaultPriceTable.Estimate(o, pageCount)
+}
+
+// Estimate estimates the cost of an order with pageCount pages.
+func (t *PriceTable) Estimate(o OrderInput, pageCount int) (*PriceEstimate, error) {
+	return t.estimate(o.Service, pageCount, o.Color, o.isCheck())
+}
+
+// estimate builds a price breakdown from the parts of an order that affect the price.
+func (t *PriceTable) estimate(service string, pageCount int, color bool, check bool) (*PriceEstimate, error) {
+	postage, ok := t.Postage[service]
+	if !ok {
+		return nil, fmt.Errorf("price table %s has no postage for service '%s'", t.Version, service)
+	}
+
+	if pageCount < 1 {
+		return nil, fmt.Errorf("page count must be at least 1, got %d", pageCount)
+	}
+
+	estimate := &PriceEstimate{
+		TableVersion: t.Version,
+		Pricing: []Price{
+			{Type: PriceTypePostage, Value: postage},
+		},
+	}
+
+	// Postcards are printed as part of the postage price
+	if service != PostcardService {
+		estimate.Pricing = append(estimate.Pricing, Price{Type: PriceTypePrinting, Value: t.Printing})
+
+		if extra := pageCount - t.IncludedPages; t.IncludedPages > 0 && extra > 0 {
+			estimate.Pricing = append(estimate.Pricing, Price{Type: PriceTypeExtraPages, Value: extra * t.ExtraPage})
+		}
+	}
+
+	if color {
+		estimate.Pricing = append(estimate.Pricing, Price{Type: PriceTypeColor, Value: pageCount * t.ColorPerPage})
+	}
+
+	if check {
+		estimate.Pricing = append(estimate.Pricing, Price{Type: PriceTypeCheck, Value: t.Check})
+	}
+
+	if fee, ok := t.Certified[service]; ok {
+		estimate.Pricing = append(estimate.Pricing, Price{Type: PriceTypeCertified, Value: fee})
+	}
+
+	for _, price := range estimate.Pricing {
+		estimate.Total += price.Value
+	}
+
+	return estimate, nil
+}
+
+// isCheck checks if the order input includes a check.
+func (o *OrderInput) isCheck() bool {
+	return o.BankAccount != "" || o.Amount != 0 || o.CheckName != "" || o.CheckNumber != 0
+}
+
+// PriceDrift is the difference between the estimated and actual price of one price type on an order line item.
+type PriceDrift struct {
+	OrderID    string `json:"order_id"`
+	LineItemID string `json:"lineitem_id"`
+	Type       string `json:"type"`
+	Estimated  int    `json:"estimated"`
+	Actual     int    `json:"actual"`
+	// Difference is actual minus estimated, so positive means mailform charged more than estimated
+	Difference int `json:"difference"`
+}
+
+// CalibrationReport compares a price table to what mailform actually charged.
+type CalibrationReport struct {
+	TableVersion   string `json:"table_version"`
+	LineItems      int    `json:"lineitems"`
+	TotalEstimated int    `json:"total_estimated"`
+	TotalActual    int    `json:"total_actual"`
+	// Drift is every price that didn't match the estimate
+	Drift []PriceDrift `json:"drift"`
+	// Errors are line items that couldn't be estimated, e.g. because of an unknown service
+	Errors []string `json:"errors"`
+}
+
+// Calibrate estimates each line item of the orders given and reports where the estimates differ from the actual pricing.
+func (t *PriceTable) Calibrate(orders []*Order) *CalibrationReport {
+	report := &CalibrationReport{
+		TableVersion: t.Version,
+		Drift:        []PriceDrift{},
+		Errors:       []string{},
+	}
+
+	for _, order := range orders {
+		for _, lineitem := range order.Data.Lineitems {
+			actual := map[string]int{}
+			for _, price := range lineitem.Pricing {
+				actual[price.Type] += price.Value
+			}
+
+			// Line items don't say if they had a check, but their pricing does
+			_, check := actual[PriceTypeCheck]
+
+			estimate, err := t.estimate(lineitem.Service, lineitem.Pagecount, lineitem.Color, check)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("order %s lineitem %s: %s", order.Data.ID, lineitem.ID, err))
+				continue
+			}
+			report.LineItems++
+
+			estimated := map[string]int{}
+			for _, price := range estimate.Pricing {
+				estimated[price.Type] += price.Value
+			}
+
+			for _, priceType := range priceTypes(estimated, actual) {
+				report.TotalEstimated += estimated[priceType]
+				report.TotalActual += actual[priceType]
+
+				if estimated[priceType] == actual[priceType] {
+					continue
+				}
+
+				report.Drift = append(report.Drift, PriceDrift{
+					OrderID:    order.Data.ID,
+					LineItemID: lineitem.ID,
+					Type:       priceType,
+					Estimated:  estimated[priceType],
+					Actual:     actual[priceType],
+					Difference: actual[priceType] - estimated[priceType],
+				})
+			}
+		}
+	}
+
+	return report
+}
+
+// priceTypes returns the sorted union of price types in the breakdowns given.
+func priceTypes(breakdowns ...map[string]int) []string {
+	seen := map[string]bool{}
+	types := []string{}
+
+	for _, breakdown := range breakdowns {
+		for priceType := range breakdown {
+			if !seen[priceType] {
+				seen[priceType] = true
+				types = append(types, priceType)
+			}
+		}
+	}
+	sort.Strings(types)
+
+	return types
+}
@@ -0,0 +1,161 @@
+package mailform
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimatePrice(t *testing.T) {
+	table := &PriceTable{
+		Version: "test",
+		Postage: map[string]int{
+			"USPS_CERTIFIED": 60,
+			"USPS_POSTCARD":  40,
+		},
+		Printing:      100,
+		IncludedPages: 2,
+		ExtraPage:     10,
+		ColorPerPage:  5,
+		Check:         200,
+		Certified: map[string]int{
+			"USPS_CERTIFIED": 400,
+		},
+	}
+
+	tests := []struct {
+		name      string
+		input     OrderInput
+		pageCount int
+		expectErr bool
+		expected  *PriceEstimate
+	}{
+		{
+			name:      "EnsureUnknownServiceErrors",
+			input:     OrderInput{Service: "FAKE"},
+			pageCount: 1,
+			expectErr: true,
+		},
+		{
+			name:      "EnsureZeroPagesErrors",
+			input:     OrderInput{Service: "USPS_CERTIFIED"},
+			pageCount: 0,
+			expectErr: true,
+		},
+		{
+			name:      "EnsureCertifiedLetterBreakdown",
+			input:     OrderInput{Service: "USPS_CERTIFIED"},
+			pageCount: 1,
+			expected: &PriceEstimate{
+				TableVersion: "test",
+				Pricing: []Price{
+					{Type: PriceTypePostage, Value: 60},
+					{Type: PriceTypePrinting, Value: 100},
+					{Type: PriceTypeCertified, Value: 400},
+				},
+				Total: 560,
+			},
+		},
+		{
+			name: "EnsureExtraPagesColorAndCheckBreakdown",
+			input: OrderInput{
+				Service:     "USPS_CERTIFIED",
+				Color:       true,
+				BankAccount: "some_bank_account",
+			},
+			pageCount: 4,
+			expected: &PriceEstimate{
+				TableVersion: "test",
+				Pricing: []Price{
+					{Type: PriceTypePostage, Value: 60},
+					{Type: PriceTypePrinting, Value: 100},
+					{Type: PriceTypeExtraPages, Value: 20},
+					{Type: PriceTypeColor, Value: 20},
+					{Type: PriceTypeCheck, Value: 200},
+					{Type: PriceTypeCertified, Value: 400},
+				},
+				Total: 800,
+			},
+		},
+		{
+			name:      "EnsurePostcardHasNoPrinting",
+			input:     OrderInput{Service: "USPS_POSTCARD"},
+			pageCount: 2,
+			expected: &PriceEstimate{
+				TableVersion: "test",
+				Pricing: []Price{
+					{Type: PriceTypePostage, Value: 40},
+				},
+				Total: 40,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := table.Estimate(test.input, test.pageCount)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDefaultPriceTableCoversServices(t *testing.T) {
+	for _, service := range ServiceCodes {
+		_, err := EstimatePrice(OrderInput{Service: service}, 1)
+		assert.NoError(t, err, service)
+	}
+}
+
+func TestLoadPriceTable(t *testing.T) {
+	table, err := LoadPriceTable(strings.NewReader(`{"version":"custom","postage":{"USPS_STANDARD":10},"printing":5}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "custom", table.Version)
+
+	estimate, err := table.Estimate(OrderInput{Service: "USPS_STANDARD"}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 15, estimate.Total)
+
+	_, err = LoadPriceTable(strings.NewReader(`not json`))
+	assert.Error(t, err)
+}
+
+func TestCalibrate(t *testing.T) {
+	table := &PriceTable{
+		Version: "test",
+		Postage: map[string]int{
+			"USPS_FIRST_CLASS": 60,
+		},
+		Printing: 100,
+		Check:    200,
+	}
+
+	order := &Order{}
+	err := json.Unmarshal([]byte(`{"data":{"id":"someID","lineitems":[
+		{"id":"line1","pagecount":1,"service":"USPS_FIRST_CLASS","pricing":[{"type":"postage","value":60},{"type":"printing","value":120},{"type":"check","value":200}]},
+		{"id":"line2","pagecount":1,"service":"FAKE","pricing":[]}
+	]}}`), order)
+	assert.NoError(t, err)
+
+	report := table.Calibrate([]*Order{order})
+	assert.Equal(t, 1, report.LineItems)
+	assert.Equal(t, 360, report.TotalEstimated)
+	assert.Equal(t, 380, report.TotalActual)
+	assert.Equal(t, []PriceDrift{
+		{
+			OrderID:    "someID",
+			LineItemID: "line1",
+			Type:       PriceTypePrinting,
+			Estimated:  100,
+			Actual:     120,
+			Difference: 20,
+		},
+	}, report.Drift)
+	assert.Len(t, report.Errors, 1)
+}
@@ -0,0 +1,182 @@
+package mailform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// DefaultRetryAfter is how long the rate limiter pauses when mailform responds with a 429 but no Retry-After header.
+	DefaultRetryAfter = time.Second
+)
+
+var (
+	// ErrInvalidRateLimit is returned by New when the rate limit doesn't refill, which would stop limiting after the first burst.
+	ErrInvalidRateLimit = errors.New("rate limit RequestsPerSecond must be greater than 0")
+)
+
+// RateLimit configures the client side token bucket that every request must pass through before being sent.
+type RateLimit struct {
+	// RequestsPerSecond is the rate at which tokens are added back to the bucket, must be greater than 0
+	RequestsPerSecond float64
+	// Burst is the maximum number of requests that can be sent at once
+	Burst int
+}
+
+// rateLimiter is a token bucket that can also be paused when mailform asks us to back off.
+// Tokens are allowed to go negative so that waiters are queued up in order of arrival.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	// last is the time tokens were last refilled.
+	// When paused, last is pushed into the future so no tokens are added until the pause is over.
+	last time.Time
+	now  func() time.Time
+}
+
+// newRateLimiter returns a rate limiter with a full bucket.
+func newRateLimiter(r *RateLimit) *rateLimiter {
+	burst := r.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:   r.RequestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// advance refills the bucket based on time elapsed since the last refill.
+func (l *rateLimiter) advance(now time.Time) {
+	if !now.After(l.last) {
+		return
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// reserve takes a token and returns how long the caller has to wait before using it.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.advance(now)
+	l.tokens--
+
+	// Time left on a pause, if any
+	wait := l.last.Sub(now)
+	if l.tokens < 0 && l.rate > 0 {
+		wait += time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+
+	if wait < 0 {
+		return 0
+	}
+
+	return wait
+}
+
+// release gives back a token that was reserved but never used.
+func (l *rateLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// pause drains the bucket and stops it from refilling for the duration given.
+func (l *rateLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.advance(now)
+
+	until := now.Add(d)
+	if until.After(l.last) {
+		l.last = until
+	}
+	if l.tokens > 0 {
+		l.tokens = 0
+	}
+}
+
+// wait blocks until a token is available.
+// If the context deadline would pass before then, it returns straight away instead of sleeping for nothing.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	delay := l.reserve()
+	if delay == 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && l.now().Add(delay).After(deadline) {
+		l.release()
+		return fmt.Errorf("rate limit wait of %s would exceed context deadline: %w", delay, context.DeadlineExceeded)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.release()
+		return ctx.Err()
+	}
+}
+
+// beforeRequest is the resty middleware that enforces the rate limit.
+func (l *rateLimiter) beforeRequest(_ *resty.Client, r *resty.Request) error {
+	return l.wait(r.Context())
+}
+
+// afterResponse is the resty middleware that pauses the rate limiter when mailform throttles us.
+func (l *rateLimiter) afterResponse(_ *resty.Client, r *resty.Response) error {
+	if r.StatusCode() == http.StatusTooManyRequests {
+		l.pause(parseRetryAfter(r.Header().Get("Retry-After"), l.now()))
+	}
+
+	return nil
+}
+
+// parseRetryAfter parses a Retry-After header which can be either a number of seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return DefaultRetryAfter
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(v); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+
+	return DefaultRetryAfter
+}
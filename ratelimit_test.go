@@ -0,0 +1,142 @@
+package mailform
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(&RateLimit{
+		RequestsPerSecond: 2,
+		Burst:             2,
+	})
+	limiter.last = now
+	limiter.now = func() time.Time { return now }
+
+	// Burst is available straight away
+	assert.Equal(t, time.Duration(0), limiter.reserve())
+	assert.Equal(t, time.Duration(0), limiter.reserve())
+	// Then we queue up at the refill rate
+	assert.Equal(t, 500*time.Millisecond, limiter.reserve())
+	assert.Equal(t, time.Second, limiter.reserve())
+
+	// Refill after time passes
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, time.Duration(0), limiter.reserve())
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(&RateLimit{
+		RequestsPerSecond: 1,
+		Burst:             5,
+	})
+	limiter.last = now
+	limiter.now = func() time.Time { return now }
+
+	limiter.pause(10 * time.Second)
+	// Full bucket is drained and we wait for the pause plus a refill
+	assert.Equal(t, 11*time.Second, limiter.reserve())
+
+	now = now.Add(20 * time.Second)
+	assert.Equal(t, time.Duration(0), limiter.reserve())
+}
+
+func TestRateLimiterWaitDeadline(t *testing.T) {
+	limiter := newRateLimiter(&RateLimit{
+		RequestsPerSecond: 1,
+		Burst:             1,
+	})
+	limiter.pause(time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	start := time.Now()
+	err := limiter.wait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// Ensure we didn't sleep until the deadline
+	assert.Less(t, time.Since(start), time.Millisecond*10)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Duration
+	}{
+		{
+			name:     "EnsureDefaultWhenEmpty",
+			input:    "",
+			expected: DefaultRetryAfter,
+		},
+		{
+			name:     "EnsureSecondsAreParsed",
+			input:    "30",
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "EnsureHTTPDateIsParsed",
+			input:    now.Add(time.Minute).Format(http.TimeFormat),
+			expected: time.Minute,
+		},
+		{
+			name:     "EnsurePastHTTPDateIsZero",
+			input:    now.Add(-time.Minute).Format(http.TimeFormat),
+			expected: 0,
+		},
+		{
+			name:     "EnsureDefaultWhenInvalid",
+			input:    "soon",
+			expected: DefaultRetryAfter,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, parseRetryAfter(test.input, now))
+		})
+	}
+}
+
+func TestRateLimitHonorsTooManyRequests(t *testing.T) {
+	fakeOrderID := "someID"
+	fakeEndpoint := fmt.Sprintf("%s%s/%s", DefaultBaseURL, ordersEndpoint, fakeOrderID)
+	mailformClient, err := New(&Config{
+		RateLimit: &RateLimit{
+			RequestsPerSecond: 100,
+			Burst:             10,
+		},
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder(http.MethodGet, fakeEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			resp := httpmock.NewStringResponse(http.StatusTooManyRequests, `{"error":{"code":"429","message":"too_many_requests"}}`)
+			resp.Header.Set("Retry-After", "60")
+			return resp, nil
+		})
+
+	_, err = mailformClient.GetOrder(fakeOrderID)
+	assert.Error(t, err)
+
+	// Next request should not be sent since we're paused for a minute
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = mailformClient.GetOrderWithContext(ctx, fakeOrderID)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(t, 1, info[fmt.Sprintf("%s %s", http.MethodGet, fakeEndpoint)])
+}
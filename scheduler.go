@@ -0,0 +1,315 @@
+package mailform
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Schedule statuses
+	ScheduleStatusPending = "pending"
+	// ScheduleStatusSubmitting is a schedule being sent to mailform, it can no longer be cancelled or rescheduled.
+	// A schedule left submitting by a crash may or may not have been sent, so it's never retried automatically.
+	ScheduleStatusSubmitting = "submitting"
+	ScheduleStatusSubmitted  = "submitted"
+	ScheduleStatusCancelled  = "cancelled"
+	ScheduleStatusFailed     = "failed"
+	// DefaultSchedulerInterval is how often Scheduler.Run checks for due schedules.
+	DefaultSchedulerInterval = time.Minute
+)
+
+var (
+	// ErrScheduleNotFound is returned when a schedule ID doesn't exist.
+	ErrScheduleNotFound = errors.New("schedule not found")
+	// ErrScheduleNotPending is returned when cancelling or rescheduling a schedule that is being or has already been submitted,
+	// or has been cancelled.
+	ErrScheduleNotPending = errors.New("schedule is no longer pending")
+)
+
+// Schedule is an order input that will be submitted to mailform at a later time.
+type Schedule struct {
+	ID      string     `json:"id"`
+	Input   OrderInput `json:"input"`
+	SendAt  time.Time  `json:"send_at"`
+	Status  string     `json:"status"`
+	Created time.Time  `json:"created"`
+	// Submitted is when the order was submitted to mailform
+	Submitted time.Time `json:"submitted,omitempty"`
+	// OrderID is the mailform order ID once submitted
+	OrderID string `json:"order_id,omitempty"`
+	// Err is the last error returned when submitting
+	Err string `json:"error,omitempty"`
+}
+
+// Scheduler submits orders to mailform when they are due.
+// Schedules are persisted to a local JSON file after every change.
+type Scheduler struct {
+	client *Client
+	path   string
+	clock  Clock
+
+	mu        sync.Mutex
+	schedules map[string]*Schedule
+	// runMu stops concurrent runs from submitting the same schedule twice
+	runMu sync.Mutex
+}
+
+// NewScheduler returns a scheduler that persists schedules to the file at path, loading any that already exist.
+// If clock is nil, the system clock is used.
+func NewScheduler(c *Client, path string, clock Clock) (*Scheduler, error) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	s := &Scheduler{
+		client:    c,
+		path:      path,
+		clock:     clock,
+		schedules: map[string]*Schedule{},
+	}
+
+	schedules := []*Schedule{}
+	err := readJSONFile(path, &schedules)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schedule := range schedules {
+		s.schedules[schedule.ID] = schedule
+	}
+
+	return s, nil
+}
+
+// save persists all schedules. Callers must hold the lock.
+func (s *Scheduler) save() error {
+	schedules := make([]*Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		schedules = append(schedules, schedule)
+	}
+	sortSchedules(schedules)
+
+	return writeJSONFile(s.path, schedules)
+}
+
+// Schedule validates an order input and schedules it to be submitted at sendAt.
+// It returns the ID of the schedule.
+func (s *Scheduler) Schedule(o OrderInput, sendAt time.Time) (string, error) {
+	if o.File != nil {
+		return "", ErrFileNotPersisted
+	}
+
+	err := o.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.schedules[id] = &Schedule{
+		ID:      id,
+		Input:   o,
+		SendAt:  sendAt,
+		Status:  ScheduleStatusPending,
+		Created: s.clock.Now(),
+	}
+
+	err = s.save()
+	if err != nil {
+		delete(s.schedules, id)
+		return "", err
+	}
+
+	return id, nil
+}
+
+// update applies fn to a schedule with the status given and persists the result.
+func (s *Scheduler) update(id string, status string, fn func(*Schedule)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+
+	if schedule.Status != status {
+		return ErrScheduleNotPending
+	}
+
+	previous := *schedule
+	fn(schedule)
+
+	err := s.save()
+	if err != nil {
+		*schedule = previous
+		return err
+	}
+
+	return nil
+}
+
+// Cancel cancels a schedule that hasn't started being submitted yet.
+func (s *Scheduler) Cancel(id string) error {
+	return s.update(id, ScheduleStatusPending, func(schedule *Schedule) {
+		schedule.Status = ScheduleStatusCancelled
+	})
+}
+
+// Reschedule changes when a schedule that hasn't started being submitted yet will be sent.
+func (s *Scheduler) Reschedule(id string, sendAt time.Time) error {
+	return s.update(id, ScheduleStatusPending, func(schedule *Schedule) {
+		schedule.SendAt = sendAt
+	})
+}
+
+// Get returns a copy of the schedule with the given ID.
+func (s *Scheduler) Get(id string) (Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return Schedule{}, false
+	}
+
+	return *schedule, true
+}
+
+// List returns a copy of all schedules ordered by when they are due.
+func (s *Scheduler) List() []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := make([]*Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		schedules = append(schedules, schedule)
+	}
+	sortSchedules(schedules)
+
+	list := make([]Schedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		list = append(list, *schedule)
+	}
+
+	return list
+}
+
+// due returns the IDs of pending schedules that should be submitted now.
+func (s *Scheduler) due() []string {
+	now := s.clock.Now()
+	ids := []string{}
+
+	for _, schedule := range s.List() {
+		if schedule.Status == ScheduleStatusPending && !schedule.SendAt.After(now) {
+			ids = append(ids, schedule.ID)
+		}
+	}
+
+	return ids
+}
+
+// RunDue submits every pending schedule that is due via CreateOrder.
+// Each schedule is marked submitting before it's sent so it can't be cancelled once it may have been mailed.
+// Schedules mailform rejects are marked as failed.
+// Any other error stops the run and leaves the schedule pending so it is retried on the next run.
+func (s *Scheduler) RunDue(ctx context.Context) error {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	for _, id := range s.due() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Claim the schedule first so it can't be cancelled while it's being sent
+		err := s.update(id, ScheduleStatusPending, func(schedule *Schedule) {
+			schedule.Status = ScheduleStatusSubmitting
+		})
+		// Cancelled while we were busy with another one
+		if errors.Is(err, ErrScheduleNotPending) || errors.Is(err, ErrScheduleNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		schedule, _ := s.Get(id)
+		order, err := s.client.CreateOrderWithContext(ctx, schedule.Input)
+		created := err == nil || isOrderCreated(order)
+		if err != nil && !created && !isOrderRejected(err) {
+			updateErr := s.update(id, ScheduleStatusSubmitting, func(schedule *Schedule) {
+				schedule.Status = ScheduleStatusPending
+				schedule.Err = err.Error()
+			})
+			if updateErr != nil {
+				return updateErr
+			}
+			return err
+		}
+
+		updateErr := s.update(id, ScheduleStatusSubmitting, func(schedule *Schedule) {
+			schedule.Submitted = s.clock.Now()
+			schedule.Err = ""
+			if err != nil {
+				schedule.Err = err.Error()
+			}
+			if !created {
+				schedule.Status = ScheduleStatusFailed
+				return
+			}
+			// The order exists even if something went wrong afterwards
+			schedule.Status = ScheduleStatusSubmitted
+			schedule.OrderID = order.Data.ID
+		})
+		if updateErr != nil {
+			return updateErr
+		}
+	}
+
+	return nil
+}
+
+// Run calls RunDue every interval until the context is cancelled.
+// If interval isn't positive, DefaultSchedulerInterval is used.
+// Errors from RunDue are passed to onErr if it's not nil and don't stop the scheduler.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration, onErr func(error)) error {
+	if interval <= 0 {
+		interval = DefaultSchedulerInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.RunDue(ctx)
+		if err != nil && ctx.Err() == nil && onErr != nil {
+			onErr(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// sortSchedules sorts schedules by when they are due, then by when they were created.
+func sortSchedules(schedules []*Schedule) {
+	sort.Slice(schedules, func(i, j int) bool {
+		if schedules[i].SendAt.Equal(schedules[j].SendAt) {
+			return schedules[i].Created.Before(schedules[j].Created)
+		}
+		return schedules[i].SendAt.Before(schedules[j].SendAt)
+	})
+}
@@ -0,0 +1,144 @@
+package mailform
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a Clock that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	fakeEndpoint := fmt.Sprintf("%s%s", DefaultBaseURL, ordersEndpoint)
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	mailformClient, err := New(&Config{})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder(http.MethodPost, fakeEndpoint,
+		jsonResponder(200, `{"success":true,"data":{"id":"someID"}}`))
+
+	scheduler, err := NewScheduler(mailformClient, path, clock)
+	assert.NoError(t, err)
+
+	renewal, err := scheduler.Schedule(testOrderInput(), clock.Now().Add(30*24*time.Hour))
+	assert.NoError(t, err)
+	cancelled, err := scheduler.Schedule(testOrderInput(), clock.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.NoError(t, scheduler.Cancel(cancelled))
+
+	// Nothing due yet
+	assert.NoError(t, scheduler.RunDue(context.Background()))
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+
+	// Bring it forward and move time past it
+	assert.NoError(t, scheduler.Reschedule(renewal, clock.Now().Add(2*time.Hour)))
+	clock.Add(3 * time.Hour)
+	assert.NoError(t, scheduler.RunDue(context.Background()))
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+
+	// Ensure state is persisted
+	scheduler, err = NewScheduler(mailformClient, path, clock)
+	assert.NoError(t, err)
+
+	schedule, ok := scheduler.Get(renewal)
+	assert.True(t, ok)
+	assert.Equal(t, ScheduleStatusSubmitted, schedule.Status)
+	assert.Equal(t, "someID", schedule.OrderID)
+	assert.Equal(t, clock.Now(), schedule.Submitted.UTC())
+
+	schedule, ok = scheduler.Get(cancelled)
+	assert.True(t, ok)
+	assert.Equal(t, ScheduleStatusCancelled, schedule.Status)
+	assert.Len(t, scheduler.List(), 2)
+
+	// Submitted schedules can't be changed
+	assert.ErrorIs(t, scheduler.Cancel(renewal), ErrScheduleNotPending)
+	assert.ErrorIs(t, scheduler.Reschedule("missing", clock.Now()), ErrScheduleNotFound)
+
+	// Nothing is submitted twice
+	assert.NoError(t, scheduler.RunDue(context.Background()))
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
+
+func TestSchedulerCancelWhileSubmitting(t *testing.T) {
+	fakeEndpoint := fmt.Sprintf("%s%s", DefaultBaseURL, ordersEndpoint)
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	mailformClient, err := New(&Config{})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Hold the request open until the test has tried to cancel
+	received := make(chan struct{})
+	release := make(chan struct{})
+	httpmock.RegisterResponder(http.MethodPost, fakeEndpoint, func(req *http.Request) (*http.Response, error) {
+		close(received)
+		<-release
+		return jsonResponder(200, `{"success":true,"data":{"id":"someID"}}`)(req)
+	})
+
+	scheduler, err := NewScheduler(mailformClient, filepath.Join(t.TempDir(), "schedules.json"), clock)
+	assert.NoError(t, err)
+	id, err := scheduler.Schedule(testOrderInput(), clock.Now())
+	assert.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- scheduler.RunDue(context.Background())
+	}()
+
+	<-received
+	schedule, _ := scheduler.Get(id)
+	assert.Equal(t, ScheduleStatusSubmitting, schedule.Status)
+	assert.ErrorIs(t, scheduler.Cancel(id), ErrScheduleNotPending)
+	assert.ErrorIs(t, scheduler.Reschedule(id, clock.Now().Add(time.Hour)), ErrScheduleNotPending)
+
+	close(release)
+	assert.NoError(t, <-done)
+
+	schedule, _ = scheduler.Get(id)
+	assert.Equal(t, ScheduleStatusSubmitted, schedule.Status)
+	assert.Equal(t, "someID", schedule.OrderID)
+}
+
+func TestSchedulerScheduleInvalid(t *testing.T) {
+	scheduler, err := NewScheduler(nil, filepath.Join(t.TempDir(), "schedules.json"), nil)
+	assert.NoError(t, err)
+
+	_, err = scheduler.Schedule(OrderInput{}, time.Now())
+	invalidErr := &ErrOrderInvalid{}
+	assert.ErrorAs(t, err, &invalidErr)
+	assert.Empty(t, scheduler.List())
+}
+
+func TestSchedulerRunNegativeInterval(t *testing.T) {
+	scheduler, err := NewScheduler(nil, filepath.Join(t.TempDir(), "schedules.json"), nil)
+	assert.NoError(t, err)
+
+	// Falls back to the default interval instead of panicking
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, scheduler.Run(ctx, -time.Second, nil), context.DeadlineExceeded)
+}
@@ -0,0 +1,93 @@
+package mailform
+
+import (
+	"strings"
+	"unicode"
+)
+
+// similarityKey normalizes text for comparison, ignoring case, punctuation and extra whitespace.
+func similarityKey(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		if r == '\'' || r == '’' || r == '.' {
+			return -1
+		}
+		return ' '
+	}, s)
+
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// similarity returns how similar two strings are from 0 to 1, by their edit distance once normalized.
+func similarity(a, b string) float64 {
+	a, b = similarityKey(a), similarityKey(b)
+	if a == b {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// matchScore returns how well a search query matches text from 0 to 1. Each word of the query is scored by the
+// most similar word of the text, with words the query word is a prefix of scoring 1, so jan matches Jane Doe.
+func matchScore(query, text string) float64 {
+	queryWords := strings.Fields(similarityKey(query))
+	textWords := strings.Fields(similarityKey(text))
+	if len(queryWords) == 0 || len(textWords) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, queryWord := range queryWords {
+		best := 0.0
+		for _, textWord := range textWords {
+			score := similarity(queryWord, textWord)
+			if strings.HasPrefix(textWord, queryWord) {
+				score = 1
+			}
+			if score > best {
+				best = score
+			}
+		}
+		total += best
+	}
+
+	return total / float64(len(queryWords))
+}
+
+// levenshtein returns the number of single character insertions, deletions and substitutions that turn a into b.
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
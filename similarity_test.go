@@ -0,0 +1,53 @@
+package mailform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected float64
+	}{
+		{a: "Jane Doe", b: "jane  doe", expected: 1},
+		{a: "O'Brien", b: "OBRIEN", expected: 1},
+		{a: "Smith", b: "Smyth", expected: 0.8},
+		{a: "abc", b: "xyz", expected: 0},
+		{a: "", b: "", expected: 1},
+		{a: "abcd", b: "", expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+"/"+test.b, func(t *testing.T) {
+			assert.InDelta(t, test.expected, similarity(test.a, test.b), 0.001)
+		})
+	}
+}
+
+func TestMatchScore(t *testing.T) {
+	tests := []struct {
+		query, text string
+		expected    float64
+	}{
+		{query: "jane", text: "Jane Doe", expected: 1},
+		{query: "jan", text: "Jane Doe", expected: 1},
+		{query: "doe jane", text: "Jane Doe", expected: 1},
+		{query: "jane smyth", text: "Jane Smith", expected: 0.9},
+		{query: "bob", text: "Jane Doe", expected: 0.333},
+		{query: "", text: "Jane Doe", expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			assert.InDelta(t, test.expected, matchScore(test.query, test.text), 0.001)
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 3, levenshtein([]rune("kitten"), []rune("sitting")))
+	assert.Equal(t, 4, levenshtein([]rune(""), []rune("abcd")))
+	assert.Equal(t, 0, levenshtein([]rune("same"), []rune("same")))
+}
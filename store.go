@@ -0,0 +1,103 @@
+package mailform
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// readJSONFile reads the JSON file at path into v.
+// A file that doesn't exist yet is not an error and leaves v untouched.
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// writeJSONFile writes v to path as JSON.
+// It writes to a temporary file first and renames it over path so a crash never leaves a half written file behind.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Cleanup if anything goes wrong, this is a no-op after the rename
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// replayJournal calls fn with each line of an append-only JSON lines journal.
+// A final line without a newline was cut short by the process dying mid write, so it is truncated away.
+func replayJournal(file *os.File, fn func(line int, data []byte) error) error {
+	reader := bufio.NewReader(file)
+
+	var offset int64
+	line := 0
+	for {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(data) > 0 {
+				return file.Truncate(offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line++
+
+		err = fn(line, data)
+		if err != nil {
+			return err
+		}
+		offset += int64(len(data))
+	}
+}
+
+// appendJournal appends v to a journal as a line of JSON and syncs it to disk.
+func appendJournal(file *os.File, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
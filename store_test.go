@@ -0,0 +1,29 @@
+package mailform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	// Missing file leaves the value untouched
+	actual := map[string]int{"untouched": 1}
+	assert.NoError(t, readJSONFile(path, &actual))
+	assert.Equal(t, map[string]int{"untouched": 1}, actual)
+
+	assert.NoError(t, writeJSONFile(path, map[string]int{"some_key": 2}))
+	actual = map[string]int{}
+	assert.NoError(t, readJSONFile(path, &actual))
+	assert.Equal(t, map[string]int{"some_key": 2}, actual)
+
+	// Ensure no temp files are left behind
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,280 @@
+package mailform
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSuppressionNotFound is returned when removing an address that isn't on the suppression list.
+	ErrSuppressionNotFound = errors.New("suppression not found")
+	// ErrSuppressionAddressRequired is returned when suppressing an address without an Address1.
+	ErrSuppressionAddressRequired = errors.New("address1 is required to suppress an address")
+)
+
+// ErrRecipientSuppressed is returned when an order is addressed to a recipient on the suppression list.
+type ErrRecipientSuppressed struct {
+	// Suppression is the suppression list entry the recipient matched
+	Suppression Suppression
+}
+
+func (e *ErrRecipientSuppressed) Error() string {
+	if e.Suppression.Reason == "" {
+		return "recipient is on the suppression list"
+	}
+
+	return fmt.Sprintf("recipient is on the suppression list: %s", e.Suppression.Reason)
+}
+
+// Suppression is a recipient that must not be mailed.
+// Suppressions without a name suppress everyone at the address.
+type Suppression struct {
+	// ID identifies the normalized name and address, adding the same recipient twice gives the same ID
+	ID      string    `json:"id"`
+	Address Address   `json:"address"`
+	Reason  string    `json:"reason,omitempty"`
+	Added   time.Time `json:"added"`
+}
+
+// SuppressionList is a do not mail list that is checked before orders are sent.
+// Recipients match on their normalized name and address, so casing, punctuation, abbreviations and ZIP+4 don't matter.
+// Suppressions are kept in memory and persisted to a local JSON file after every change.
+type SuppressionList struct {
+	path  string
+	clock Clock
+
+	mu           sync.Mutex
+	suppressions map[string]*Suppression
+}
+
+// NewSuppressionList returns a suppression list that persists to the file at path, loading any suppressions that already exist.
+// If clock is nil, the system clock is used.
+func NewSuppressionList(path string, clock Clock) (*SuppressionList, error) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	l := &SuppressionList{
+		path:         path,
+		clock:        clock,
+		suppressions: map[string]*Suppression{},
+	}
+
+	suppressions := []*Suppression{}
+	err := readJSONFile(path, &suppressions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, suppression := range suppressions {
+		l.suppressions[suppression.ID] = suppression
+	}
+
+	return l, nil
+}
+
+// save persists all suppressions. Callers must hold the lock.
+func (l *SuppressionList) save() error {
+	return writeJSONFile(l.path, l.entries())
+}
+
+// entries returns copies of the suppressions sorted by when they were added. Callers must hold the lock.
+func (l *SuppressionList) entries() []Suppression {
+	suppressions := make([]Suppression, 0, len(l.suppressions))
+	for _, suppression := range l.suppressions {
+		suppressions = append(suppressions, *suppression)
+	}
+	sort.Slice(suppressions, func(i, j int) bool {
+		if !suppressions[i].Added.Equal(suppressions[j].Added) {
+			return suppressions[i].Added.Before(suppressions[j].Added)
+		}
+		return suppressions[i].ID < suppressions[j].ID
+	})
+
+	return suppressions
+}
+
+// Add suppresses a recipient, or everyone at an address if it has no name.
+// Adding a recipient that is already suppressed keeps the original entry.
+func (l *SuppressionList) Add(a Address, reason string) (Suppression, error) {
+	if strings.TrimSpace(a.Address1) == "" {
+		return Suppression{}, ErrSuppressionAddressRequired
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	suppressions, err := l.add([]Suppression{{Address: a, Reason: reason}})
+	if err != nil {
+		return Suppression{}, err
+	}
+
+	return suppressions[0], nil
+}
+
+// add adds suppressions and saves once. Callers must hold the lock.
+func (l *SuppressionList) add(suppressions []Suppression) ([]Suppression, error) {
+	added := []string{}
+	for i := range suppressions {
+		suppression := suppressions[i]
+		suppression.ID = addressKey(suppression.Address)
+		if existing, ok := l.suppressions[suppression.ID]; ok {
+			suppressions[i] = *existing
+			continue
+		}
+
+		suppression.Added = l.clock.Now()
+		l.suppressions[suppression.ID] = &suppression
+		suppressions[i] = suppression
+		added = append(added, suppression.ID)
+	}
+
+	err := l.save()
+	if err != nil {
+		// Keep memory consistent with what's on disk
+		for _, id := range added {
+			delete(l.suppressions, id)
+		}
+		return nil, err
+	}
+
+	return suppressions, nil
+}
+
+// Remove stops suppressing a recipient, matching the same way as Check.
+func (l *SuppressionList) Remove(a Address) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id := addressKey(a)
+	suppression, ok := l.suppressions[id]
+	if !ok {
+		return ErrSuppressionNotFound
+	}
+
+	delete(l.suppressions, id)
+	err := l.save()
+	if err != nil {
+		l.suppressions[id] = suppression
+		return err
+	}
+
+	return nil
+}
+
+// Check returns the suppression an address matches, either the recipient at the address or the whole address.
+func (l *SuppressionList) Check(a Address) (Suppression, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range []string{addressKey(a), addressKey(addressWithoutName(a))} {
+		if suppression, ok := l.suppressions[id]; ok {
+			return *suppression, true
+		}
+	}
+
+	return Suppression{}, false
+}
+
+// Entries returns every suppression in the order they were added.
+func (l *SuppressionList) Entries() []Suppression {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.entries()
+}
+
+// Import adds the recipients in CSV with a header row. Columns are named after the address fields,
+// such as name and address1, and an optional reason column. Rows without a reason use the reason given.
+// It returns the number of rows imported, and ImportErrors for rows without an address1 or the wrong number of columns.
+// Other errors, such as an unknown column or malformed CSV, stop the import before anything is added.
+func (l *SuppressionList) Import(r io.Reader, reason string) (int, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	columns := map[string]int{}
+	known := append([]string{"reason"}, addressFieldColumns()...)
+	for n, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !containsString(known, column) {
+			return 0, fmt.Errorf("column '%s' is not an address field or reason", column)
+		}
+		columns[column] = n
+	}
+
+	suppressions := []Suppression{}
+	rowErrs := ImportErrors{}
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		parseErr := &csv.ParseError{}
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			rowErrs = append(rowErrs, &ImportRowError{Row: parseErr.StartLine, Err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		value := func(column string) string {
+			n, ok := columns[column]
+			if !ok {
+				return ""
+			}
+			return strings.TrimSpace(values[n])
+		}
+
+		suppression := Suppression{Reason: value("reason")}
+		if suppression.Reason == "" {
+			suppression.Reason = reason
+		}
+		fields := suppression.Address.fields()
+		for i, column := range addressFieldColumns() {
+			*fields[i] = value(column)
+		}
+
+		if suppression.Address.Address1 == "" {
+			row, _ := reader.FieldPos(0)
+			rowErrs = append(rowErrs, &ImportRowError{Row: row, Err: ErrSuppressionAddressRequired})
+			continue
+		}
+		suppressions = append(suppressions, suppression)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err = l.add(suppressions)
+	if err != nil {
+		return 0, err
+	}
+	if len(rowErrs) > 0 {
+		return len(suppressions), rowErrs
+	}
+
+	return len(suppressions), nil
+}
+
+// addressFieldColumns returns the import column names of the address fields, in the same order as addressFields.
+func addressFieldColumns() []string {
+	columns := make([]string, len(addressFields))
+	for i, field := range addressFields {
+		columns[i] = strings.ToLower(field)
+	}
+
+	return columns
+}
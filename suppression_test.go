@@ -0,0 +1,151 @@
+package mailform
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuppressionList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suppressions.json")
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	list, err := NewSuppressionList(path, clock)
+	assert.NoError(t, err)
+
+	jane := Address{Name: "Jane Doe", Address1: "123 North Main Street", Address2: "Apt. 4", City: "Austin", State: "TX", Postcode: "78701-1234", Country: "US"}
+	suppression, err := list.Add(jane, "asked not to be mailed")
+	assert.NoError(t, err)
+	assert.Equal(t, clock.now, suppression.Added)
+
+	// Adding again keeps the original
+	clock.Add(time.Hour)
+	again, err := list.Add(jane, "again")
+	assert.NoError(t, err)
+	assert.Equal(t, suppression, again)
+
+	_, err = list.Add(Address{Name: "Jane Doe"}, "")
+	assert.ErrorIs(t, err, ErrSuppressionAddressRequired)
+
+	// Everyone at an address
+	_, err = list.Add(Address{Address1: "1 Elm St", City: "Austin", State: "TX", Postcode: "78702", Country: "US"}, "vacant")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		input          Address
+		expectedReason string
+	}{
+		{
+			name:           "same",
+			input:          jane,
+			expectedReason: "asked not to be mailed",
+		},
+		{
+			name:           "normalized",
+			input:          Address{Name: "JANE DOE", Organization: "Acme", Address1: "123 N Main St", Address2: "APT 4", City: "austin", State: "Texas", Postcode: "78701", Country: "United States"},
+			expectedReason: "asked not to be mailed",
+		},
+		{
+			name:  "someone else at the address",
+			input: Address{Name: "John Doe", Address1: "123 N Main St", Address2: "Apt 4", City: "Austin", State: "TX", Postcode: "78701", Country: "US"},
+		},
+		{
+			name:  "another unit",
+			input: Address{Name: "Jane Doe", Address1: "123 N Main St", Address2: "Apt 5", City: "Austin", State: "TX", Postcode: "78701", Country: "US"},
+		},
+		{
+			name:           "anyone at a suppressed address",
+			input:          Address{Name: "Resident", Address1: "1 Elm Street", City: "Austin", State: "TX", Postcode: "78702", Country: "USA"},
+			expectedReason: "vacant",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			suppression, ok := list.Check(test.input)
+			assert.Equal(t, test.expectedReason != "", ok)
+			assert.Equal(t, test.expectedReason, suppression.Reason)
+		})
+	}
+
+	// Suppressions are persisted
+	reloaded, err := NewSuppressionList(path, clock)
+	assert.NoError(t, err)
+	assert.Equal(t, list.Entries(), reloaded.Entries())
+	assert.Len(t, reloaded.Entries(), 2)
+
+	assert.NoError(t, reloaded.Remove(Address{Name: "jane doe", Address1: "123 N Main St", Address2: "Apt 4", City: "Austin", State: "TX", Postcode: "78701", Country: "US"}))
+	_, ok := reloaded.Check(jane)
+	assert.False(t, ok)
+	assert.ErrorIs(t, reloaded.Remove(jane), ErrSuppressionNotFound)
+
+	reloaded, err = NewSuppressionList(path, clock)
+	assert.NoError(t, err)
+	assert.Len(t, reloaded.Entries(), 1)
+}
+
+func TestSuppressionListImport(t *testing.T) {
+	list, err := NewSuppressionList(filepath.Join(t.TempDir(), "suppressions.json"), nil)
+	assert.NoError(t, err)
+
+	csv := "\ufeffName,Address1,City,State,Postcode,Country,Reason\n" +
+		"Jane Doe,1 Main St,Austin,TX,78701,US,\n" +
+		"John Doe,2 Main St,Austin,TX,78701,US,legal hold\n" +
+		"No Address,,Austin,TX,78701,US,\n" +
+		"Short,row\n"
+
+	count, err := list.Import(strings.NewReader(csv), "do not mail request")
+	assert.Equal(t, 2, count)
+	importErrs := ImportErrors{}
+	assert.True(t, errors.As(err, &importErrs))
+	assert.Len(t, importErrs, 2)
+	assert.Equal(t, 4, importErrs[0].Row)
+	assert.ErrorIs(t, importErrs[0], ErrSuppressionAddressRequired)
+	assert.Equal(t, 5, importErrs[1].Row)
+
+	suppression, ok := list.Check(Address{Name: "Jane Doe", Address1: "1 Main St", City: "Austin", State: "TX", Postcode: "78701", Country: "US"})
+	assert.True(t, ok)
+	assert.Equal(t, "do not mail request", suppression.Reason)
+	suppression, ok = list.Check(Address{Name: "John Doe", Address1: "2 Main St", City: "Austin", State: "TX", Postcode: "78701", Country: "US"})
+	assert.True(t, ok)
+	assert.Equal(t, "legal hold", suppression.Reason)
+
+	_, err = list.Import(strings.NewReader("name,street\n"), "")
+	assert.ErrorContains(t, err, "column 'street'")
+}
+
+func TestCreateOrderSuppressed(t *testing.T) {
+	list, err := NewSuppressionList(filepath.Join(t.TempDir(), "suppressions.json"), nil)
+	assert.NoError(t, err)
+
+	mailformClient, err := New(&Config{
+		Token:       "someToken",
+		Suppression: list,
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", DefaultBaseURL+ordersEndpoint,
+		jsonResponder(http.StatusOK, `{"success": true, "data": {"id": "some_id"}}`))
+
+	input := testOrderInput()
+	_, err = mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+
+	_, err = list.Add(input.To(), "asked not to be mailed")
+	assert.NoError(t, err)
+
+	_, err = mailformClient.CreateOrder(input)
+	suppressedErr := &ErrRecipientSuppressed{}
+	assert.True(t, errors.As(err, &suppressedErr))
+	assert.Equal(t, "recipient is on the suppression list: asked not to be mailed", err.Error())
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
@@ -0,0 +1,194 @@
+package mailform
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AddressStrictness is how problems found by the offline US address checks are handled.
+type AddressStrictness int
+
+const (
+	// AddressStrictnessOff skips the US address checks
+	AddressStrictnessOff AddressStrictness = iota
+	// AddressStrictnessWarn reports problems as warnings and sends the order anyway
+	AddressStrictnessWarn
+	// AddressStrictnessStrict refuses to send orders with problems
+	AddressStrictnessStrict
+)
+
+var (
+	// usStates maps the USPS codes of states, territories and military "states" to their names
+	usStates = map[string]string{
+		"AL": "ALABAMA", "AK": "ALASKA", "AZ": "ARIZONA", "AR": "ARKANSAS", "CA": "CALIFORNIA",
+		"CO": "COLORADO", "CT": "CONNECTICUT", "DE": "DELAWARE", "DC": "DISTRICT OF COLUMBIA", "FL": "FLORIDA",
+		"GA": "GEORGIA", "HI": "HAWAII", "ID": "IDAHO", "IL": "ILLINOIS", "IN": "INDIANA",
+		"IA": "IOWA", "KS": "KANSAS", "KY": "KENTUCKY", "LA": "LOUISIANA", "ME": "MAINE",
+		"MD": "MARYLAND", "MA": "MASSACHUSETTS", "MI": "MICHIGAN", "MN": "MINNESOTA", "MS": "MISSISSIPPI",
+		"MO": "MISSOURI", "MT": "MONTANA", "NE": "NEBRASKA", "NV": "NEVADA", "NH": "NEW HAMPSHIRE",
+		"NJ": "NEW JERSEY", "NM": "NEW MEXICO", "NY": "NEW YORK", "NC": "NORTH CAROLINA", "ND": "NORTH DAKOTA",
+		"OH": "OHIO", "OK": "OKLAHOMA", "OR": "OREGON", "PA": "PENNSYLVANIA", "RI": "RHODE ISLAND",
+		"SC": "SOUTH CAROLINA", "SD": "SOUTH DAKOTA", "TN": "TENNESSEE", "TX": "TEXAS", "UT": "UTAH",
+		"VT": "VERMONT", "VA": "VIRGINIA", "WA": "WASHINGTON", "WV": "WEST VIRGINIA", "WI": "WISCONSIN",
+		"WY": "WYOMING",
+		// Territories and freely associated states
+		"AS": "AMERICAN SAMOA", "GU": "GUAM", "MP": "NORTHERN MARIANA ISLANDS", "PR": "PUERTO RICO",
+		"VI": "VIRGIN ISLANDS", "FM": "FEDERATED STATES OF MICRONESIA", "MH": "MARSHALL ISLANDS", "PW": "PALAU",
+		// Military mail
+		"AA": "ARMED FORCES AMERICAS", "AE": "ARMED FORCES EUROPE", "AP": "ARMED FORCES PACIFIC",
+	}
+	// usStateCodes maps state names to their codes, built from usStates
+	usStateCodes = map[string]string{}
+	// usZIPCode is the ZIP code grammar shared by Validate and ValidateUSAddresses,
+	// 5 digits optionally followed by 4 more after a dash, a space or nothing
+	usZIPCode = regexp.MustCompile(`^\d{5}([ -]?\d{4})?$`)
+	// usZIPPrefixes are the ranges of 3 digit ZIP code prefixes each state uses, from the USPS prefix list.
+	// A prefix can belong to more than one state, 969 is shared by the Pacific territories.
+	usZIPPrefixes = []struct {
+		low, high int
+		state     string
+	}{
+		{5, 5, "NY"}, {6, 7, "PR"}, {8, 8, "VI"}, {9, 9, "PR"},
+		{10, 27, "MA"}, {28, 29, "RI"}, {30, 38, "NH"}, {39, 49, "ME"},
+		{50, 54, "VT"}, {55, 55, "MA"}, {56, 59, "VT"}, {60, 69, "CT"},
+		{70, 89, "NJ"}, {90, 98, "AE"}, {100, 149, "NY"}, {150, 196, "PA"},
+		{197, 199, "DE"}, {200, 200, "DC"}, {201, 201, "VA"}, {202, 205, "DC"},
+		{206, 219, "MD"}, {220, 246, "VA"}, {247, 268, "WV"}, {270, 289, "NC"},
+		{290, 299, "SC"}, {300, 319, "GA"}, {320, 339, "FL"}, {340, 340, "AA"},
+		{341, 349, "FL"}, {350, 369, "AL"}, {370, 385, "TN"}, {386, 397, "MS"},
+		{398, 399, "GA"}, {400, 427, "KY"}, {430, 459, "OH"}, {460, 479, "IN"},
+		{480, 499, "MI"}, {500, 528, "IA"}, {530, 549, "WI"}, {550, 567, "MN"},
+		{569, 569, "DC"}, {570, 577, "SD"}, {580, 588, "ND"}, {590, 599, "MT"},
+		{600, 629, "IL"}, {630, 658, "MO"}, {660, 679, "KS"}, {680, 693, "NE"},
+		{700, 714, "LA"}, {716, 729, "AR"}, {730, 731, "OK"}, {733, 733, "TX"},
+		{734, 749, "OK"}, {750, 799, "TX"}, {800, 816, "CO"}, {820, 831, "WY"},
+		{832, 838, "ID"}, {840, 847, "UT"}, {850, 865, "AZ"}, {870, 884, "NM"},
+		{885, 885, "TX"}, {889, 898, "NV"}, {900, 961, "CA"}, {962, 966, "AP"},
+		{967, 968, "HI"}, {969, 969, "GU"}, {969, 969, "AS"}, {969, 969, "MP"},
+		{969, 969, "FM"}, {969, 969, "MH"}, {969, 969, "PW"}, {970, 979, "OR"},
+		{980, 994, "WA"}, {995, 999, "AK"},
+	}
+)
+
+func init() {
+	for code, name := range usStates {
+		usStateCodes[name] = code
+	}
+}
+
+// ValidateUSAddresses checks the states and ZIP codes of US recipient and sender addresses against an offline dataset.
+// It flags unknown states, malformed ZIP codes and ZIP codes that belong to a different state.
+// At AddressStrictnessWarn problems are returned as warning diagnostics, at AddressStrictnessStrict the first problem
+// is returned as an *ErrOrderInvalid. Addresses outside the US and empty fields are left to Validate.
+func (o *OrderInput) ValidateUSAddresses(strictness AddressStrictness) (Diagnostics, error) {
+	warnings := Diagnostics{}
+	if strictness == AddressStrictnessOff {
+		return warnings, nil
+	}
+
+	problems := append(usAddressProblems("To", o.To()), usAddressProblems("From", o.From())...)
+	if strictness == AddressStrictnessStrict && len(problems) > 0 {
+		return warnings, problems[0]
+	}
+
+	for _, problem := range problems {
+		for _, diag := range problem.Diagnostics() {
+			diag.Severity = DiagnosticSeverityWarning
+			diag.Summary = "Suspicious address"
+			warnings = append(warnings, diag)
+		}
+	}
+
+	return warnings, nil
+}
+
+// usAddressProblems checks a US address, prefix is the OrderInput field prefix of the address.
+func usAddressProblems(prefix string, a Address) []*ErrOrderInvalid {
+	problems := []*ErrOrderInvalid{}
+	if !isUnitedStates(a.Country) {
+		return problems
+	}
+
+	state, stateOK := usStateCode(a.State)
+	if a.State != "" && !stateOK {
+		problems = append(problems, &ErrOrderInvalid{
+			field:   prefix + "State",
+			message: fmt.Sprintf("state: '%s' is not a US state, territory or military state code", a.State),
+		})
+	}
+
+	zip, zipOK := parseZIP(a.Postcode)
+	if a.Postcode != "" && !zipOK {
+		problems = append(problems, &ErrOrderInvalid{
+			field:   prefix + "Postcode",
+			message: fmt.Sprintf("postcode: '%s' is not a ZIP code, must be 12345 or 12345-6789", a.Postcode),
+		})
+	}
+
+	if stateOK && zipOK {
+		states := zipStates(zip)
+		if len(states) == 0 {
+			problems = append(problems, &ErrOrderInvalid{
+				field:   prefix + "Postcode",
+				message: fmt.Sprintf("postcode: '%s' is not in use by any state", a.Postcode),
+			})
+		} else if !containsString(states, state) {
+			problems = append(problems, &ErrOrderInvalid{
+				field:   prefix + "Postcode",
+				message: fmt.Sprintf("postcode: '%s' belongs to %s, not %s", a.Postcode, strings.Join(states, " or "), state),
+			})
+		}
+	}
+
+	return problems
+}
+
+// usStateCode returns the code of a state code or name.
+func usStateCode(state string) (string, bool) {
+	state = strings.ToUpper(strings.Join(strings.Fields(strings.ReplaceAll(state, ".", "")), " "))
+	if _, ok := usStates[state]; ok {
+		return state, true
+	}
+	code, ok := usStateCodes[state]
+
+	return code, ok
+}
+
+// parseZIP returns the 3 digit prefix of a 5 digit or ZIP+4 code.
+func parseZIP(postcode string) (int, bool) {
+	postcode = strings.TrimSpace(postcode)
+	if !usZIPCode.MatchString(postcode) {
+		return 0, false
+	}
+
+	prefix, err := strconv.Atoi(postcode[:3])
+
+	return prefix, err == nil
+}
+
+// zipStates returns the states a 3 digit ZIP code prefix belongs to.
+func zipStates(prefix int) []string {
+	states := []string{}
+	for _, r := range usZIPPrefixes {
+		if prefix >= r.low && prefix <= r.high {
+			states = append(states, r.state)
+		}
+	}
+	sort.Strings(states)
+
+	return states
+}
+
+// containsString returns true if s is in values.
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
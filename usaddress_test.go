@@ -0,0 +1,172 @@
+package mailform
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUSAddresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		to       Address
+		expected map[string]string
+	}{
+		{
+			name:     "valid",
+			to:       Address{State: "TX", Postcode: "78701", Country: "US"},
+			expected: map[string]string{},
+		},
+		{
+			name:     "valid zip+4 and state name",
+			to:       Address{State: "new  york", Postcode: "10001-1234", Country: "USA"},
+			expected: map[string]string{},
+		},
+		{
+			name:     "territory",
+			to:       Address{State: "GU", Postcode: "96910", Country: "US"},
+			expected: map[string]string{},
+		},
+		{
+			name:     "military",
+			to:       Address{State: "AP", Postcode: "96201", Country: "US"},
+			expected: map[string]string{},
+		},
+		{
+			name:     "mismatched state",
+			to:       Address{State: "WA", Postcode: "78701", Country: "US"},
+			expected: map[string]string{"ToPostcode": "postcode: '78701' belongs to TX, not WA"},
+		},
+		{
+			name:     "shared prefix",
+			to:       Address{State: "HI", Postcode: "96910", Country: "US"},
+			expected: map[string]string{"ToPostcode": "postcode: '96910' belongs to AS or FM or GU or MH or MP or PW, not HI"},
+		},
+		{
+			name:     "unused prefix",
+			to:       Address{State: "NY", Postcode: "00100", Country: "US"},
+			expected: map[string]string{"ToPostcode": "postcode: '00100' is not in use by any state"},
+		},
+		{
+			name: "invalid state and malformed zip",
+			to:   Address{State: "XX", Postcode: "7870", Country: "US"},
+			expected: map[string]string{
+				"ToState":    "state: 'XX' is not a US state, territory or military state code",
+				"ToPostcode": "postcode: '7870' is not a ZIP code, must be 12345 or 12345-6789",
+			},
+		},
+		{
+			name:     "zip without dash",
+			to:       Address{State: "TX", Postcode: "787011234", Country: "US"},
+			expected: map[string]string{},
+		},
+		{
+			name:     "zip with a space",
+			to:       Address{State: "TX", Postcode: "78701 1234", Country: "US"},
+			expected: map[string]string{},
+		},
+		{
+			name:     "zip with letters",
+			to:       Address{State: "TX", Postcode: "78701-12AB", Country: "US"},
+			expected: map[string]string{"ToPostcode": "postcode: '78701-12AB' is not a ZIP code, must be 12345 or 12345-6789"},
+		},
+		{
+			name:     "outside the US",
+			to:       Address{State: "XX", Postcode: "SW1A 2AA", Country: "GB"},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := testOrderInput()
+			input.SetTo(test.to)
+			input.FromState = "IL"
+			input.FromPostcode = "60601"
+			input.FromCountry = "US"
+
+			warnings, err := input.ValidateUSAddresses(AddressStrictnessWarn)
+			assert.NoError(t, err)
+			actual := map[string]string{}
+			for _, warning := range warnings {
+				assert.Equal(t, DiagnosticSeverityWarning, warning.Severity)
+				field := ""
+				for name, path := range attributePaths {
+					if path == warning.AttributePath {
+						field = name
+					}
+				}
+				actual[field] = warning.Detail
+			}
+			assert.Equal(t, test.expected, actual)
+
+			_, err = input.ValidateUSAddresses(AddressStrictnessStrict)
+			if len(test.expected) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			invalidErr := &ErrOrderInvalid{}
+			assert.True(t, errors.As(err, &invalidErr))
+			assert.Contains(t, test.expected, invalidErr.Field())
+		})
+	}
+}
+
+func TestValidateUSAddressesOff(t *testing.T) {
+	input := testOrderInput()
+	input.ToState = "XX"
+	input.ToCountry = "US"
+
+	warnings, err := input.ValidateUSAddresses(AddressStrictnessOff)
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func TestCreateOrderAddressStrictness(t *testing.T) {
+	mailformClient, err := New(&Config{
+		Token:             "someToken",
+		AddressStrictness: AddressStrictnessWarn,
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", DefaultBaseURL+ordersEndpoint,
+		jsonResponder(http.StatusOK, `{"success": true, "data": {"id": "some_id"}}`))
+
+	input := testOrderInput()
+	input.ToState = "WA"
+	input.ToPostcode = "78701"
+	input.ToCountry = "US"
+
+	order, err := mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "some_id", order.Data.ID)
+	assert.Len(t, order.Warnings, 1)
+	assert.Equal(t, "to.postcode", order.Warnings[0].AttributePath)
+
+	mailformClient.strictness = AddressStrictnessStrict
+	_, err = mailformClient.CreateOrder(input)
+	invalidErr := &ErrOrderInvalid{}
+	assert.True(t, errors.As(err, &invalidErr))
+	assert.Equal(t, "ToPostcode", invalidErr.Field())
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
+
+func TestParseZIPMatchesValidate(t *testing.T) {
+	for _, postcode := range []string{"12345", "12345-6789", "12345 6789", "123456789", "1234", "12345-678", "12345--6789", "ABCDE"} {
+		t.Run(postcode, func(t *testing.T) {
+			input := testOrderInput()
+			input.ToState = "TX"
+			input.ToPostcode = postcode
+			input.ToCountry = "US"
+
+			_, ok := parseZIP(postcode)
+			assert.Equal(t, input.Validate() == nil, ok)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package mailform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Address deliverability verdicts
+	DeliverabilityDeliverable   = "deliverable"
+	DeliverabilityUndeliverable = "undeliverable"
+	DeliverabilityUnknown       = "unknown"
+	// offlineConfidence is the most confidence the offline verifier has, it can't know an address exists
+	offlineConfidence = 0.6
+)
+
+// ErrAddressUndeliverable is returned when address verification finds an address undeliverable and the policy is to reject.
+var ErrAddressUndeliverable = errors.New("address is undeliverable")
+
+// VerificationMode is what is done with address verification results.
+type VerificationMode int
+
+const (
+	// VerificationWarn reports corrections and undeliverable addresses as warnings and sends orders unchanged
+	VerificationWarn VerificationMode = iota
+	// VerificationAutoCorrect applies corrected addresses and reports undeliverable addresses as warnings
+	VerificationAutoCorrect
+	// VerificationReject refuses to send orders to undeliverable addresses and reports corrections as warnings
+	VerificationReject
+)
+
+// AddressVerification is the result of verifying an address.
+type AddressVerification struct {
+	// Deliverability is one of the Deliverability verdicts
+	Deliverability string `json:"deliverability"`
+	// Confidence is how sure the verifier is of the verdict, from 0 to 1
+	Confidence float64 `json:"confidence"`
+	// Corrected is the corrected address, nil if the address needs no correction
+	Corrected *Address `json:"corrected,omitempty"`
+	// Notes explain the verdict and corrections
+	Notes []string `json:"notes,omitempty"`
+}
+
+// AddressVerifier verifies addresses are deliverable, such as a third party address verification service.
+type AddressVerifier interface {
+	VerifyAddress(ctx context.Context, a Address) (*AddressVerification, error)
+}
+
+// AddressVerifierFunc adapts a function to an AddressVerifier, for plugging in third party services.
+type AddressVerifierFunc func(ctx context.Context, a Address) (*AddressVerification, error)
+
+// VerifyAddress calls f.
+func (f AddressVerifierFunc) VerifyAddress(ctx context.Context, a Address) (*AddressVerification, error) {
+	return f(ctx, a)
+}
+
+// VerificationPolicy configures how order addresses are verified.
+type VerificationPolicy struct {
+	// Verifier verifies the addresses, defaults to OfflineVerifier
+	Verifier AddressVerifier
+	// Mode is what is done with the results, defaults to VerificationWarn
+	Mode VerificationMode
+	// MinConfidence is the lowest confidence results are acted on. Corrections and undeliverable verdicts
+	// with less confidence are only reported as warnings.
+	MinConfidence float64
+	// VerifySender verifies the sender address as well as the recipient
+	VerifySender bool
+}
+
+// OfflineVerifier verifies addresses with the offline rules in this package, without calling any service.
+// It corrects addresses to postal standards, including US state names to codes, and finds addresses undeliverable
+// when they're missing required fields, have an unknown country or a US ZIP code in another state.
+// It can't know whether an address exists, so its confidence is never more than 0.6.
+type OfflineVerifier struct{}
+
+// VerifyAddress verifies an address with the offline rules.
+func (OfflineVerifier) VerifyAddress(ctx context.Context, a Address) (*AddressVerification, error) {
+	result := &AddressVerification{
+		Deliverability: DeliverabilityDeliverable,
+		Confidence:     offlineConfidence,
+	}
+
+	corrected, changes := NormalizeAddress(a)
+	if state, ok := usStateCode(corrected.State); ok && isUnitedStates(corrected.Country) && state != corrected.State {
+		changes = append(changes, AddressChange{Field: "State", Before: a.State, After: state, Reasons: []string{"replaced state name with its code"}})
+		corrected.State = state
+	}
+	if len(changes) > 0 {
+		result.Corrected = &corrected
+		for _, change := range changes {
+			result.Notes = append(result.Notes, change.String())
+		}
+	}
+
+	undeliverable := func(note string) {
+		result.Deliverability = DeliverabilityUndeliverable
+		result.Notes = append(result.Notes, note)
+	}
+
+	if strings.TrimSpace(corrected.Address1) == "" {
+		undeliverable("no street address")
+	}
+	if strings.TrimSpace(corrected.City) == "" {
+		undeliverable("no city")
+	}
+	if _, err := ParseCountry(corrected.Country); err != nil {
+		undeliverable(err.Error())
+	}
+	if err := validateAddressRegion("", corrected); err != nil {
+		undeliverable(err.Error())
+	}
+	for _, problem := range usAddressProblems("", corrected) {
+		undeliverable(problem.Error())
+	}
+
+	// Street addresses and PO boxes have numbers, lines without one are often missing the house number
+	if result.Deliverability == DeliverabilityDeliverable && !strings.ContainsAny(corrected.Address1+corrected.Address2, "0123456789") {
+		result.Confidence = offlineConfidence / 2
+		result.Notes = append(result.Notes, "no house or box number")
+	}
+
+	return result, nil
+}
+
+// verifier returns the verifier of the policy.
+func (p *VerificationPolicy) verifier() AddressVerifier {
+	if p.Verifier == nil {
+		return OfflineVerifier{}
+	}
+
+	return p.Verifier
+}
+
+// orderAddress is one of the addresses of an order input.
+type orderAddress struct {
+	// prefix is the OrderInput field prefix of the address
+	prefix string
+	// name is what the address is called in messages
+	name string
+	get  func() Address
+	set  func(Address)
+}
+
+// VerifyOrderAddresses verifies the recipient address of an order input, and the sender if the policy says so,
+// then acts on the results by the policy's mode. Corrections are applied to o with VerificationAutoCorrect.
+// It returns warnings for corrections made and anything found that wasn't acted on, and an error wrapping ErrAddressUndeliverable
+// when an address is rejected. Verifier errors are returned with VerificationReject and are warnings otherwise.
+func VerifyOrderAddresses(ctx context.Context, o *OrderInput, policy *VerificationPolicy) (Diagnostics, error) {
+	warnings := Diagnostics{}
+	if policy == nil {
+		policy = &VerificationPolicy{}
+	}
+
+	addresses := []orderAddress{{prefix: "To", name: "recipient", get: o.To, set: o.SetTo}}
+	if policy.VerifySender {
+		addresses = append(addresses, orderAddress{prefix: "From", name: "sender", get: o.From, set: o.SetFrom})
+	}
+
+	for _, address := range addresses {
+		attributePath := AttributePath(address.prefix + "Address1")
+		result, err := policy.verifier().VerifyAddress(ctx, address.get())
+		if err != nil {
+			if policy.Mode == VerificationReject {
+				return warnings, fmt.Errorf("could not verify %s address: %w", address.name, err)
+			}
+			warnings = append(warnings, Diagnostic{
+				Severity:      DiagnosticSeverityWarning,
+				Summary:       "Address not verified",
+				Detail:        fmt.Sprintf("could not verify %s address: %s", address.name, err),
+				AttributePath: attributePath,
+			})
+			continue
+		}
+
+		confident := result.Confidence >= policy.MinConfidence
+		notes := strings.Join(result.Notes, "; ")
+
+		if result.Deliverability == DeliverabilityUndeliverable {
+			if policy.Mode == VerificationReject && confident {
+				return warnings, fmt.Errorf("%w: %s address: %s", ErrAddressUndeliverable, address.name, notes)
+			}
+			warnings = append(warnings, Diagnostic{
+				Severity:      DiagnosticSeverityWarning,
+				Summary:       "Address may be undeliverable",
+				Detail:        fmt.Sprintf("%s address is %s (%.0f%% confidence): %s", address.name, result.Deliverability, result.Confidence*100, notes),
+				AttributePath: attributePath,
+			})
+		}
+
+		if result.Corrected == nil {
+			continue
+		}
+		if policy.Mode == VerificationAutoCorrect && confident {
+			address.set(*result.Corrected)
+			warnings = append(warnings, Diagnostic{
+				Severity:      DiagnosticSeverityWarning,
+				Summary:       "Address corrected",
+				Detail:        fmt.Sprintf("%s address corrected to %s", address.name, strings.Join(result.Corrected.Lines(), ", ")),
+				AttributePath: attributePath,
+			})
+			continue
+		}
+		warnings = append(warnings, Diagnostic{
+			Severity:      DiagnosticSeverityWarning,
+			Summary:       "Address could be corrected",
+			Detail:        fmt.Sprintf("%s address could be corrected to %s", address.name, strings.Join(result.Corrected.Lines(), ", ")),
+			AttributePath: attributePath,
+		})
+	}
+
+	return warnings, nil
+}
@@ -0,0 +1,240 @@
+package mailform
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOfflineVerifier(t *testing.T) {
+	tests := []struct {
+		name               string
+		input              Address
+		expectedVerdict    string
+		expectedConfidence float64
+		expectedCorrected  *Address
+	}{
+		{
+			name:               "deliverable",
+			input:              Address{Name: "JANE DOE", Address1: "1 MAIN ST", City: "AUSTIN", State: "TX", Postcode: "78701", Country: "US"},
+			expectedVerdict:    DeliverabilityDeliverable,
+			expectedConfidence: 0.6,
+		},
+		{
+			name:               "corrected",
+			input:              Address{Name: "Jane Doe", Address1: "1 Main Street", City: "Austin", State: "Texas", Postcode: "787011234", Country: "US"},
+			expectedVerdict:    DeliverabilityDeliverable,
+			expectedConfidence: 0.6,
+			expectedCorrected:  &Address{Name: "JANE DOE", Address1: "1 MAIN ST", City: "AUSTIN", State: "TX", Postcode: "78701-1234", Country: "US"},
+		},
+		{
+			name:               "no house number",
+			input:              Address{Name: "JANE DOE", Address1: "MAIN ST", City: "AUSTIN", State: "TX", Postcode: "78701", Country: "US"},
+			expectedVerdict:    DeliverabilityDeliverable,
+			expectedConfidence: 0.3,
+		},
+		{
+			name:               "ZIP in another state",
+			input:              Address{Name: "JANE DOE", Address1: "1 MAIN ST", City: "AUSTIN", State: "WA", Postcode: "78701", Country: "US"},
+			expectedVerdict:    DeliverabilityUndeliverable,
+			expectedConfidence: 0.6,
+		},
+		{
+			name:               "unknown country",
+			input:              Address{Name: "JANE DOE", Address1: "1 MAIN ST", City: "AUSTIN", State: "TX", Postcode: "78701", Country: "NARNIA"},
+			expectedVerdict:    DeliverabilityUndeliverable,
+			expectedConfidence: 0.6,
+		},
+		{
+			name:               "missing postcode",
+			input:              Address{Name: "JANE DOE", Address1: "10 DOWNING ST", City: "LONDON", Country: "GB"},
+			expectedVerdict:    DeliverabilityUndeliverable,
+			expectedConfidence: 0.6,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := OfflineVerifier{}.VerifyAddress(context.Background(), test.input)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedVerdict, result.Deliverability)
+			assert.Equal(t, test.expectedConfidence, result.Confidence)
+			assert.Equal(t, test.expectedCorrected, result.Corrected)
+			if test.expectedVerdict == DeliverabilityUndeliverable {
+				assert.NotEmpty(t, result.Notes)
+			}
+		})
+	}
+}
+
+func TestVerifyOrderAddresses(t *testing.T) {
+	corrected := Address{Name: "SOME_NAME", Address1: "1 MAIN ST", City: "AUSTIN", State: "TX", Postcode: "78701", Country: "US"}
+	errVendor := errors.New("vendor is down")
+
+	tests := []struct {
+		name             string
+		result           *AddressVerification
+		verifyErr        error
+		policy           VerificationPolicy
+		expectedErr      error
+		expectedWarnings []string
+		expectCorrected  bool
+	}{
+		{
+			name:   "deliverable",
+			result: &AddressVerification{Deliverability: DeliverabilityDeliverable, Confidence: 1},
+			policy: VerificationPolicy{Mode: VerificationReject},
+		},
+		{
+			name:             "warn about undeliverable",
+			result:           &AddressVerification{Deliverability: DeliverabilityUndeliverable, Confidence: 1, Notes: []string{"no such street"}},
+			policy:           VerificationPolicy{Mode: VerificationWarn},
+			expectedWarnings: []string{"recipient address is undeliverable (100% confidence): no such street"},
+		},
+		{
+			name:        "reject undeliverable",
+			result:      &AddressVerification{Deliverability: DeliverabilityUndeliverable, Confidence: 0.9, Notes: []string{"no such street"}},
+			policy:      VerificationPolicy{Mode: VerificationReject, MinConfidence: 0.8},
+			expectedErr: ErrAddressUndeliverable,
+		},
+		{
+			name:             "undeliverable below min confidence",
+			result:           &AddressVerification{Deliverability: DeliverabilityUndeliverable, Confidence: 0.5, Notes: []string{"no such street"}},
+			policy:           VerificationPolicy{Mode: VerificationReject, MinConfidence: 0.8},
+			expectedWarnings: []string{"recipient address is undeliverable (50% confidence): no such street"},
+		},
+		{
+			name:             "auto correct",
+			result:           &AddressVerification{Deliverability: DeliverabilityDeliverable, Confidence: 1, Corrected: &corrected},
+			policy:           VerificationPolicy{Mode: VerificationAutoCorrect},
+			expectedWarnings: []string{"recipient address corrected to SOME_NAME, 1 MAIN ST, AUSTIN, TX 78701, US"},
+			expectCorrected:  true,
+		},
+		{
+			name:             "correction below min confidence",
+			result:           &AddressVerification{Deliverability: DeliverabilityDeliverable, Confidence: 0.5, Corrected: &corrected},
+			policy:           VerificationPolicy{Mode: VerificationAutoCorrect, MinConfidence: 0.8},
+			expectedWarnings: []string{"recipient address could be corrected to SOME_NAME, 1 MAIN ST, AUSTIN, TX 78701, US"},
+		},
+		{
+			name:             "warn about correction",
+			result:           &AddressVerification{Deliverability: DeliverabilityDeliverable, Confidence: 1, Corrected: &corrected},
+			policy:           VerificationPolicy{Mode: VerificationWarn},
+			expectedWarnings: []string{"recipient address could be corrected to SOME_NAME, 1 MAIN ST, AUSTIN, TX 78701, US"},
+		},
+		{
+			name:             "verifier error warns",
+			verifyErr:        errVendor,
+			policy:           VerificationPolicy{Mode: VerificationAutoCorrect},
+			expectedWarnings: []string{"could not verify recipient address: vendor is down"},
+		},
+		{
+			name:        "verifier error rejects",
+			verifyErr:   errVendor,
+			policy:      VerificationPolicy{Mode: VerificationReject},
+			expectedErr: errVendor,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := testOrderInput()
+			original := input
+
+			verified := []Address{}
+			test.policy.Verifier = AddressVerifierFunc(func(ctx context.Context, a Address) (*AddressVerification, error) {
+				verified = append(verified, a)
+				return test.result, test.verifyErr
+			})
+
+			warnings, err := VerifyOrderAddresses(context.Background(), &input, &test.policy)
+			assert.Equal(t, []Address{original.To()}, verified)
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			details := []string{}
+			for _, warning := range warnings {
+				assert.Equal(t, DiagnosticSeverityWarning, warning.Severity)
+				assert.Equal(t, "to.address1", warning.AttributePath)
+				details = append(details, warning.Detail)
+			}
+			if test.expectedWarnings == nil {
+				test.expectedWarnings = []string{}
+			}
+			assert.Equal(t, test.expectedWarnings, details)
+
+			if test.expectCorrected {
+				assert.Equal(t, corrected, input.To())
+				return
+			}
+			assert.Equal(t, original, input)
+		})
+	}
+}
+
+func TestVerifyOrderAddressesSender(t *testing.T) {
+	input := testOrderInput()
+	verified := []Address{}
+	policy := &VerificationPolicy{
+		Verifier: AddressVerifierFunc(func(ctx context.Context, a Address) (*AddressVerification, error) {
+			verified = append(verified, a)
+			return &AddressVerification{Deliverability: DeliverabilityDeliverable, Confidence: 1}, nil
+		}),
+		VerifySender: true,
+	}
+
+	_, err := VerifyOrderAddresses(context.Background(), &input, policy)
+	assert.NoError(t, err)
+	assert.Equal(t, []Address{input.To(), input.From()}, verified)
+}
+
+func TestCreateOrderVerification(t *testing.T) {
+	mailformClient, err := New(&Config{
+		Token: "someToken",
+		Verification: &VerificationPolicy{
+			Mode: VerificationAutoCorrect,
+		},
+	})
+	assert.NoError(t, err)
+
+	httpmock.ActivateNonDefault(mailformClient.restClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	var sent url.Values
+	httpmock.RegisterResponder("POST", DefaultBaseURL+ordersEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			err := req.ParseForm()
+			assert.NoError(t, err)
+			sent = req.PostForm
+			return jsonResponder(http.StatusOK, `{"success": true, "data": {"id": "some_id"}}`)(req)
+		})
+
+	input := testOrderInput()
+	input.ToAddress1 = "1 Main Street"
+	input.ToCity = "Austin"
+	input.ToState = "Texas"
+	input.ToPostcode = "78701"
+	input.ToCountry = "US"
+
+	order, err := mailformClient.CreateOrder(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "1 MAIN ST", sent.Get("to.address1"))
+	assert.Equal(t, "TX", sent.Get("to.state"))
+	assert.Len(t, order.Warnings, 1)
+	assert.Equal(t, "Address corrected", order.Warnings[0].Summary)
+
+	// Undeliverable addresses are rejected before anything is sent
+	mailformClient.verify.Mode = VerificationReject
+	input.ToState = "WA"
+	_, err = mailformClient.CreateOrder(input)
+	assert.ErrorIs(t, err, ErrAddressUndeliverable)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}